@@ -50,10 +50,14 @@ func Init(databaseURL string) (*sql.DB, error) {
 //   - users: User accounts with phone numbers
 //   - oauth_clients: Registered OAuth2 client applications
 //   - auth_codes: Short-lived authorization codes
-//   - access_tokens: Access token records (for audit)
+//   - access_tokens: Opaque reference access tokens (stored hashed)
 //   - refresh_tokens: Long-lived refresh tokens
+//   - initial_access_tokens: Tokens authorizing dynamic client registration
+//   - api_resources: Protected APIs that tokens can be requested for
+//   - scopes: Catalogue of the scopes clients can request
 //
-// It also inserts a default OAuth2 client with ID "default-client" for development.
+// It also inserts a default OAuth2 client with ID "default-client" for development
+// and registers the standard OpenID Connect scopes.
 //
 // Parameters:
 //   - db: Database connection
@@ -170,6 +174,57 @@ func Migrate(db *sql.DB) error {
 		FOREIGN KEY (app_id) REFERENCES external_apps(id)
 	);`
 
+	// 客户端注册初始访问令牌表
+	createInitialAccessTokensTable := `
+	CREATE TABLE IF NOT EXISTS initial_access_tokens (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		developer_id VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (developer_id) REFERENCES developers(id)
+	);`
+
+	// API资源表
+	createAPIResourcesTable := `
+	CREATE TABLE IF NOT EXISTS api_resources (
+		identifier VARCHAR(512) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		scopes TEXT[] NOT NULL,
+		access_token_lifetime INTEGER NOT NULL DEFAULT 3600,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// scope注册表
+	createScopesTable := `
+	CREATE TABLE IF NOT EXISTS scopes (
+		name VARCHAR(255) PRIMARY KEY,
+		description VARCHAR(512) NOT NULL DEFAULT '',
+		sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+		claims TEXT[],
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 授权详情类型注册表（RFC 9396）
+	createAuthorizationDetailsTypesTable := `
+	CREATE TABLE IF NOT EXISTS authorization_details_types (
+		type VARCHAR(255) PRIMARY KEY,
+		description VARCHAR(512) NOT NULL DEFAULT '',
+		fields TEXT[],
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// 成对主体标识符表，用于将成对sub解析回用户
+	createPairwiseSubjectsTable := `
+	CREATE TABLE IF NOT EXISTS pairwise_subjects (
+		sector_identifier VARCHAR(255) NOT NULL,
+		subject VARCHAR(64) NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (sector_identifier, subject),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
 	// 执行所有表创建语句
 	tables := []string{
 		createUsersTable,
@@ -180,6 +235,11 @@ func Migrate(db *sql.DB) error {
 		createDevelopersTable,
 		createExternalAppsTable,
 		createAppKeyPairsTable,
+		createInitialAccessTokensTable,
+		createAPIResourcesTable,
+		createScopesTable,
+		createPairwiseSubjectsTable,
+		createAuthorizationDetailsTypesTable,
 	}
 
 	for _, table := range tables {
@@ -211,6 +271,151 @@ func Migrate(db *sql.DB) error {
 		return err
 	}
 
+	// 为OAuth2客户端表添加新字段（如果不存在）
+	alterOAuthClientsTable := []string{
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS require_signed_request_object BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS app_id VARCHAR(255) REFERENCES external_apps(id);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS jwks_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS token_endpoint_auth_method VARCHAR(64) DEFAULT 'client_secret_basic';`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_auth_subject_dn VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_auth_san_dns VARCHAR(255);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_auth_san_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_auth_san_ip VARCHAR(64);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_auth_san_email VARCHAR(255);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tls_client_certificate_bound_access_tokens BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS developer_id VARCHAR(255) REFERENCES developers(id);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS client_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS logo_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS contacts TEXT[];`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS registration_access_token_hash VARCHAR(64);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS token_exchange_audiences TEXT[];`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS token_exchange_scopes TEXT[];`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS access_token_format VARCHAR(20) DEFAULT 'jwt';`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS access_token_lifetime INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS id_token_lifetime INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS refresh_token_lifetime INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS auth_code_lifetime INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS refresh_token_idle_timeout INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS session_lifetime INTEGER;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS issue_refresh_tokens BOOLEAN;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS userinfo_signed_response_alg VARCHAR(20);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS jwks TEXT;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS id_token_encrypted_response_alg VARCHAR(20);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS id_token_encrypted_response_enc VARCHAR(20);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS subject_type VARCHAR(20) DEFAULT 'public';`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS sector_identifier_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[];`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS backchannel_logout_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS backchannel_logout_session_required BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS frontchannel_logout_uri VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS frontchannel_logout_session_required BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS backchannel_token_delivery_mode VARCHAR(10);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS backchannel_client_notification_endpoint VARCHAR(512);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS minimum_acr VARCHAR(255);`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS application_type VARCHAR(10) DEFAULT 'web';`,
+		`ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS request_uris TEXT[];`,
+	}
+
+	for _, alter := range alterOAuthClientsTable {
+		if _, err := db.Exec(alter); err != nil {
+			return err
+		}
+	}
+
+	// 为授权码和刷新令牌表添加资源指示、用户认证、使用时间、会话、声明请求和授权详情字段（如果不存在）
+	alterGrantTables := []string{
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS resources TEXT[];`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS resources TEXT[];`,
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;`,
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS acr VARCHAR(255);`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP;`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS acr VARCHAR(255);`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP;`,
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS session_id VARCHAR(64);`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id VARCHAR(64);`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS offline BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS claims TEXT;`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS claims TEXT;`,
+		`ALTER TABLE auth_codes ADD COLUMN IF NOT EXISTS authorization_details TEXT;`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS authorization_details TEXT;`,
+	}
+
+	for _, alter := range alterGrantTables {
+		if _, err := db.Exec(alter); err != nil {
+			return err
+		}
+	}
+
+	// 为访问令牌表添加引用令牌对应的JWT字段（如果不存在）
+	addAccessTokenJWTColumn := `
+	ALTER TABLE access_tokens
+	ADD COLUMN IF NOT EXISTS jwt TEXT;`
+
+	if _, err := db.Exec(addAccessTokenJWTColumn); err != nil {
+		return err
+	}
+
+	// 为API资源表添加令牌内省凭据字段（如果不存在）
+	addResourceSecretColumn := `
+	ALTER TABLE api_resources
+	ADD COLUMN IF NOT EXISTS secret_hash VARCHAR(64);`
+
+	if _, err := db.Exec(addResourceSecretColumn); err != nil {
+		return err
+	}
+
+	// 为用户表添加OpenID Connect资料字段（如果不存在）
+	alterUsersTable := []string{
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS name VARCHAR(255);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS nickname VARCHAR(255);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS picture VARCHAR(512);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(35);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_number_verified BOOLEAN DEFAULT FALSE;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);`,
+	}
+
+	for _, alter := range alterUsersTable {
+		if _, err := db.Exec(alter); err != nil {
+			return err
+		}
+	}
+
+	// 为外部应用表添加JWT断言主体策略字段（如果不存在）
+	addAssertionSubjectsColumn := `
+	ALTER TABLE external_apps
+	ADD COLUMN IF NOT EXISTS assertion_subjects TEXT[];`
+
+	if _, err := db.Exec(addAssertionSubjectsColumn); err != nil {
+		return err
+	}
+
+	// 为scope注册表添加最低认证等级字段（如果不存在）
+	addScopeMinimumACRColumn := `
+	ALTER TABLE scopes
+	ADD COLUMN IF NOT EXISTS minimum_acr VARCHAR(255);`
+
+	if _, err := db.Exec(addScopeMinimumACRColumn); err != nil {
+		return err
+	}
+
+	// 注册标准OpenID Connect scope（OpenID Connect Core 1.0 Section 5.4）
+	insertDefaultScopes := `
+	INSERT INTO scopes (name, description, sensitive, claims) VALUES
+		('openid', '使用您的账号登录', FALSE, ARRAY['sub']),
+		('profile', '读取您的基本资料', FALSE, ARRAY['name', 'family_name', 'given_name', 'middle_name', 'nickname',
+			'preferred_username', 'profile', 'picture', 'website', 'gender', 'birthdate', 'zoneinfo', 'locale', 'updated_at']),
+		('email', '读取您的邮箱地址', FALSE, ARRAY['email', 'email_verified']),
+		('phone', '读取您的手机号', FALSE, ARRAY['phone_number', 'phone_number_verified']),
+		('offline_access', '在您退出登录后继续访问您的数据（离线访问）', TRUE, ARRAY[]::TEXT[])
+	ON CONFLICT (name) DO NOTHING;`
+
+	if _, err := db.Exec(insertDefaultScopes); err != nil {
+		return err
+	}
+
 	// 插入默认管理员用户
 	insertDefaultAdmin := `
 	INSERT INTO users (phone, role) 
@@ -212,6 +212,35 @@ func (h *AppManagementHandler) RevokeKey(c *gin.Context) {
 	})
 }
 
+// UpdateAssertionSubjects sets the users an application may assert in JWT bearer grants
+func (h *AppManagementHandler) UpdateAssertionSubjects(c *gin.Context) {
+	appID := c.Param("app_id")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "App ID is required"})
+		return
+	}
+
+	var req struct {
+		Subjects []string `json:"subjects"` // User IDs, or "*" for any user
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	err := h.appService.UpdateAssertionSubjects(appID, req.Subjects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update assertion subjects", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Assertion subjects updated successfully",
+		"subjects": req.Subjects,
+	})
+}
+
 // GetDeveloperApps retrieves all applications for a developer
 func (h *AppManagementHandler) GetDeveloperApps(c *gin.Context) {
 	developerID := c.Param("developer_id")
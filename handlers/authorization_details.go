@@ -0,0 +1,184 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"encoding/json"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizationDetailView describes an authorization detail on the consent screen.
+type authorizationDetailView struct {
+	Type        string   // Authorization details type
+	Description string   // Description registered for the type
+	Fields      []string // Members of the authorization detail, as "name: value"
+}
+
+// resolveAuthorizationDetails validates the authorization_details parameter of an authorization
+// request (RFC 9396 Section 2) against the registry of authorization details types.
+// The normalized value replaces req.AuthorizationDetails.
+func (h *Handler) resolveAuthorizationDetails(req *AuthorizeRequest) error {
+	details, err := services.ParseAuthorizationDetails(req.AuthorizationDetails)
+	if err != nil || details == nil {
+		return err
+	}
+
+	if err := h.authorizationDetailsService.ValidateAuthorizationDetails(details); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	req.AuthorizationDetails = string(encoded)
+	return nil
+}
+
+// grantAuthorizationDetails returns the authorization details stored with a grant, nil when none.
+func grantAuthorizationDetails(raw string) []models.AuthorizationDetail {
+	details, err := services.ParseAuthorizationDetails(raw)
+	if err != nil {
+		return nil
+	}
+	return details
+}
+
+// tokenAuthorizationDetails returns the authorization details of an access token issued from a
+// grant. A token request may narrow them with its own authorization_details parameter, but every
+// requested authorization detail must be part of the grant (RFC 9396 Section 6.1).
+// Errors are written to the response.
+func tokenAuthorizationDetails(c *gin.Context, granted, requested string) ([]models.AuthorizationDetail, bool) {
+	details := grantAuthorizationDetails(granted)
+	if requested == "" {
+		return details, true
+	}
+
+	requestedDetails, err := services.ParseAuthorizationDetails(requested)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_authorization_details", "error_description": err.Error()})
+		return nil, false
+	}
+	if !services.ContainsAuthorizationDetails(details, requestedDetails) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_authorization_details", "error_description": "authorization details were not part of the authorization"})
+		return nil, false
+	}
+	return requestedDetails, true
+}
+
+// authorizationDetailViews describes the authorization details of a request for the consent screen.
+func (h *Handler) authorizationDetailViews(raw string) ([]authorizationDetailView, error) {
+	details := grantAuthorizationDetails(raw)
+	if len(details) == 0 {
+		return nil, nil
+	}
+
+	catalogue, err := h.authorizationDetailsService.Catalogue()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]authorizationDetailView, 0, len(details))
+	for _, detail := range details {
+		view := authorizationDetailView{}
+		view.Type, _ = detail["type"].(string)
+		if detailsType, ok := catalogue[view.Type]; ok {
+			view.Description = detailsType.Description
+		}
+
+		names := make([]string, 0, len(detail))
+		for name := range detail {
+			if name != "type" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, ok := detail[name].(string)
+			if !ok {
+				encoded, _ := json.Marshal(detail[name])
+				value = string(encoded)
+			}
+			view.Fields = append(view.Fields, fmt.Sprintf("%s: %s", name, value))
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// CreateAuthorizationDetailsType registers an authorization details type, or replaces an
+// existing one (admin endpoint). Without fields, authorization details of the type may carry
+// any members.
+//
+// Example:
+//
+//	POST /api/admin/authorization-details-types
+//	Content-Type: application/json
+//	{"type": "payment_initiation", "description": "发起付款", "fields": ["instructedAmount", "creditorName", "creditorAccount"]}
+func (h *Handler) CreateAuthorizationDetailsType(c *gin.Context) {
+	var req struct {
+		Type        string   `json:"type" binding:"required"` // Value of the "type" member
+		Description string   `json:"description"`             // Explanation shown on the consent screen
+		Fields      []string `json:"fields"`                  // Type-specific members allowed besides the common ones
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	detailsType := &models.AuthorizationDetailsType{
+		Type:        req.Type,
+		Description: req.Description,
+		Fields:      req.Fields,
+	}
+	if err := h.authorizationDetailsService.CreateType(detailsType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to register authorization details type", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":                    "Authorization details type registered successfully",
+		"authorization_details_type": detailsType,
+	})
+}
+
+// ListAuthorizationDetailsTypes returns the registered authorization details types (admin endpoint).
+func (h *Handler) ListAuthorizationDetailsTypes(c *gin.Context) {
+	types, err := h.authorizationDetailsService.ListTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve authorization details types", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_details_types": types,
+	})
+}
+
+// DeleteAuthorizationDetailsType removes an authorization details type from the registry (admin endpoint).
+// The type is passed as a query parameter since type values may be URIs.
+//
+// Example:
+//
+//	DELETE /api/admin/authorization-details-types?type=payment_initiation
+func (h *Handler) DeleteAuthorizationDetailsType(c *gin.Context) {
+	name := c.Query("type")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization details type is required"})
+		return
+	}
+
+	if err := h.authorizationDetailsService.DeleteType(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete authorization details type", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Authorization details type deleted successfully",
+	})
+}
@@ -0,0 +1,251 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"encoding/json"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authorizeError describes an invalid authorization request (RFC 6749 Section 4.1.2.1).
+type authorizeError struct {
+	Code        string // OAuth2 error code
+	Description string // Human-readable error description
+	Redirect    bool   // Whether the error may be sent to the (validated) redirect URI
+}
+
+// resolveAuthorizeRequest loads the client of an authorization request, replaces the request
+// with the pushed one or the signed request object when one is referenced, and validates it.
+// The scope is resolved to the scopes granted to the client, the claims request to the
+// claims the client may request, and authorization details are checked against their registry.
+// Errors are written to the response: as JSON when the redirect URI cannot be trusted,
+// otherwise to the redirect URI.
+//
+// Returns the client, the resolved response mode and whether processing may continue.
+func (h *Handler) resolveAuthorizeRequest(c *gin.Context, req *AuthorizeRequest) (*models.OAuthClient, string, bool) {
+	// 验证客户端
+	client, err := h.oauthService.GetClient(req.ClientID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client"})
+		return nil, "", false
+	}
+
+	// 解析推送的授权请求
+	params := c.Request.Form
+	if strings.HasPrefix(req.RequestURI, services.PARRequestURIPrefix) {
+		params, err = h.parService.Get(client.ID, req.RequestURI)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request_uri", "error_description": err.Error()})
+			return nil, "", false
+		}
+
+		pushed := AuthorizeRequest{RequestURI: req.RequestURI}
+		if err := binding.MapFormWithTag(&pushed, params, "form"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request_uri", "error_description": err.Error()})
+			return nil, "", false
+		}
+		*req = pushed
+	} else if client.RequirePAR {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client requires pushed authorization requests"})
+		return nil, "", false
+	}
+
+	// 解析签名的请求对象
+	if req.Request != "" || (req.RequestURI != "" && !strings.HasPrefix(req.RequestURI, services.PARRequestURIPrefix)) {
+		if aerr := h.resolveRequestObject(client, req, params); aerr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": aerr.Code, "error_description": aerr.Description})
+			return nil, "", false
+		}
+	} else if client.RequireSignedRequestObject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client requires signed request objects"})
+		return nil, "", false
+	}
+
+	responseMode, aerr := validateAuthorizeRequest(client, req)
+	if aerr != nil {
+		if !aerr.Redirect {
+			c.JSON(http.StatusBadRequest, gin.H{"error": aerr.Code, "error_description": aerr.Description})
+			return nil, "", false
+		}
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams(aerr.Code, aerr.Description, req.State))
+		return nil, "", false
+	}
+
+	// 验证资源指示
+	if err := h.resourceService.ValidateResources(req.Resource); err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("invalid_target", err.Error(), req.State))
+		return nil, "", false
+	}
+
+	// 按scope注册表及客户端、应用允许的scope验证并收窄请求
+	if err := h.resolveAuthorizeScope(client, req); err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("invalid_scope", err.Error(), req.State))
+		return nil, "", false
+	}
+
+	// 验证声明请求
+	if err := h.resolveClaimsRequest(client, req); err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("invalid_request", err.Error(), req.State))
+		return nil, "", false
+	}
+
+	// 按注册的类型验证授权详情
+	if err := h.resolveAuthorizationDetails(req); err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("invalid_authorization_details", err.Error(), req.State))
+		return nil, "", false
+	}
+
+	return client, responseMode, true
+}
+
+// requestObjectRegisteredClaims are JWT claims of a request object that are not authorization parameters.
+var requestObjectRegisteredClaims = map[string]bool{"iss": true, "aud": true, "exp": true, "iat": true, "nbf": true, "jti": true}
+
+// maxRequestObjectLifetime limits how far in the future request objects may expire, so a
+// captured request object cannot be replayed for long.
+const maxRequestObjectLifetime = time.Hour
+
+// resolveRequestObject verifies the signed request object (RFC 9101) passed by value in the
+// request parameter or by reference in one of the client's registered request_uris, and
+// replaces the authorization request with its claims. Request objects must expire within
+// maxRequestObjectLifetime. Parameters sent outside the object must match the signed values.
+func (h *Handler) resolveRequestObject(client *models.OAuthClient, req *AuthorizeRequest, outside url.Values) *authorizeError {
+	requestObject := req.Request
+	if requestObject == "" {
+		// 仅获取客户端预先注册的地址
+		if !containsString(client.RequestURIs, req.RequestURI) {
+			return &authorizeError{Code: "invalid_request_uri", Description: "request_uri is not registered for the client"}
+		}
+
+		var err error
+		if requestObject, err = h.clientJWTService.FetchRequestObject(req.RequestURI); err != nil {
+			return &authorizeError{Code: "invalid_request_uri", Description: err.Error()}
+		}
+	} else if req.RequestURI != "" && !strings.HasPrefix(req.RequestURI, services.PARRequestURIPrefix) {
+		return &authorizeError{Code: "invalid_request", Description: "request and request_uri must not both be present"}
+	}
+
+	// 验证请求对象签名及签发者
+	claims, err := h.clientJWTService.ParseClientJWT(client, requestObject,
+		jwt.WithIssuer(client.ID), jwt.WithAudience(h.config.Issuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return &authorizeError{Code: "invalid_request_object", Description: err.Error()}
+	}
+	if exp, err := claims.GetExpirationTime(); err != nil || exp.After(time.Now().Add(maxRequestObjectLifetime)) {
+		return &authorizeError{Code: "invalid_request_object", Description: "request object must expire within an hour"}
+	}
+	if clientID, ok := claims["client_id"]; ok && clientID != client.ID {
+		return &authorizeError{Code: "invalid_request_object", Description: "client_id does not match the request"}
+	}
+	if _, ok := claims["request"]; ok {
+		return &authorizeError{Code: "invalid_request_object", Description: "request objects must not be nested"}
+	}
+	if _, ok := claims["request_uri"]; ok {
+		return &authorizeError{Code: "invalid_request_object", Description: "request objects must not be nested"}
+	}
+
+	// 将声明转换为授权参数，字符串数组转换为多值参数，其他非字符串值按JSON编码
+	params := url.Values{}
+	for name, value := range claims {
+		if requestObjectRegisteredClaims[name] {
+			continue
+		}
+		if str, ok := value.(string); ok {
+			params.Set(name, str)
+			continue
+		}
+		if values, ok := stringValues(value); ok {
+			params[name] = values
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return &authorizeError{Code: "invalid_request_object", Description: err.Error()}
+		}
+		params.Set(name, string(encoded))
+	}
+
+	// 请求对象外的参数必须与签名的值一致
+	for name := range outside {
+		switch name {
+		case "client_id", "request", "request_uri", "phone", "code":
+			continue
+		}
+		value := outside.Get(name)
+		if signed, ok := params[name]; ok && value != "" && value != signed[0] {
+			return &authorizeError{Code: "invalid_request", Description: fmt.Sprintf("%s does not match the request object", name)}
+		}
+	}
+
+	resolved := AuthorizeRequest{ClientID: client.ID, Request: req.Request, RequestURI: req.RequestURI}
+	if err := binding.MapFormWithTag(&resolved, params, "form"); err != nil {
+		return &authorizeError{Code: "invalid_request_object", Description: err.Error()}
+	}
+	*req = resolved
+
+	return nil
+}
+
+// stringValues converts a JSON array of strings into parameter values.
+func stringValues(value any) ([]string, bool) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, str)
+	}
+	return values, true
+}
+
+// validateAuthorizeRequest checks the authorization request parameters against the client
+// registration and resolves the response mode. When the requested response mode is invalid,
+// the default mode is returned so the error can still be delivered.
+func validateAuthorizeRequest(client *models.OAuthClient, req *AuthorizeRequest) (string, *authorizeError) {
+	// 验证重定向URI
+	if req.RedirectURI == "" || !isRegisteredRedirectURI(client, req.RedirectURI) {
+		return "", &authorizeError{Code: "invalid_redirect_uri"}
+	}
+
+	// 验证响应模式
+	responseMode, ok := resolveResponseMode(req.ResponseMode)
+	if !ok {
+		return ResponseModeQuery, &authorizeError{Code: "invalid_request", Description: "unsupported response_mode", Redirect: true}
+	}
+
+	// 验证响应类型
+	if req.ResponseType == "" {
+		return responseMode, &authorizeError{Code: "invalid_request", Description: "response_type is required", Redirect: true}
+	}
+	if req.ResponseType != "code" {
+		return responseMode, &authorizeError{Code: "unsupported_response_type", Redirect: true}
+	}
+
+	return responseMode, nil
+}
+
+// isRegisteredRedirectURI reports whether the redirect URI is one registered by the client.
+// Native clients may pick any port for a registered loopback redirect URI (RFC 8252 Section 7.3).
+func isRegisteredRedirectURI(client *models.OAuthClient, redirectURI string) bool {
+	return services.MatchRedirectURI(client.ApplicationType, client.RedirectURIs, redirectURI)
+}
@@ -0,0 +1,144 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response modes accepted in the response_mode authorization request parameter.
+// The ".jwt" variants wrap the response parameters in a signed JWT (JARM).
+const (
+	ResponseModeQuery       = "query"
+	ResponseModeFragment    = "fragment"
+	ResponseModeFormPost    = "form_post"
+	ResponseModeJWT         = "jwt"
+	ResponseModeQueryJWT    = "query.jwt"
+	ResponseModeFragmentJWT = "fragment.jwt"
+	ResponseModeFormPostJWT = "form_post.jwt"
+)
+
+// supportedResponseModes lists the response modes advertised in the discovery document.
+var supportedResponseModes = []string{
+	ResponseModeQuery,
+	ResponseModeFragment,
+	ResponseModeFormPost,
+	ResponseModeJWT,
+	ResponseModeQueryJWT,
+	ResponseModeFragmentJWT,
+	ResponseModeFormPostJWT,
+}
+
+// formPostTemplate renders the auto-submitting HTML form used by the form_post response modes
+// (OAuth 2.0 Form Post Response Mode).
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Submit This Form</title></head>
+<body onload="javascript:document.forms[0].submit()">
+  <form method="post" action="{{.Action}}">
+    {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}"/>
+    {{end}}<noscript><button type="submit">Continue</button></noscript>
+  </form>
+</body>
+</html>`))
+
+// resolveResponseMode validates the requested response mode and applies the default of the
+// authorization code flow. The generic "jwt" mode resolves to "query.jwt".
+func resolveResponseMode(responseMode string) (string, bool) {
+	switch responseMode {
+	case "":
+		return ResponseModeQuery, true
+	case ResponseModeJWT:
+		return ResponseModeQueryJWT, true
+	case ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost,
+		ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT:
+		return responseMode, true
+	}
+	return "", false
+}
+
+// authorizationErrorParams builds the parameters of an authorization error response.
+func authorizationErrorParams(errorCode, description, state string) url.Values {
+	params := url.Values{"error": {errorCode}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return params
+}
+
+// buildRedirectURL adds the response parameters to the redirect URI, either to its query
+// component (keeping any query the client registered) or to its fragment component.
+func buildRedirectURL(redirectURI string, params url.Values, useFragment bool) (string, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if useFragment {
+		return u.String() + "#" + params.Encode(), nil
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + params.Encode()
+	} else {
+		u.RawQuery = params.Encode()
+	}
+	return u.String(), nil
+}
+
+// sendAuthorizationResponse returns the authorization response parameters to the client's
+// redirect URI using the given (already resolved) response mode. For the ".jwt" modes the
+// parameters are first wrapped in a JWT signed with the server key.
+func (h *Handler) sendAuthorizationResponse(c *gin.Context, clientID, redirectURI, responseMode string, params url.Values) {
+	if strings.HasSuffix(responseMode, ".jwt") {
+		claims := make(map[string]string, len(params))
+		for name := range params {
+			claims[name] = params.Get(name)
+		}
+
+		response, err := h.jwtService.GenerateAuthorizationResponse(clientID, claims)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+
+		params = url.Values{"response": {response}}
+		responseMode = strings.TrimSuffix(responseMode, ".jwt")
+	}
+
+	if responseMode == ResponseModeFormPost {
+		fields := make(map[string]string, len(params))
+		for name := range params {
+			fields[name] = params.Get(name)
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		formPostTemplate.Execute(c.Writer, gin.H{"Action": redirectURI, "Params": fields})
+		return
+	}
+
+	location, err := buildRedirectURL(redirectURI, params, responseMode == ResponseModeFragment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_redirect_uri"})
+		return
+	}
+
+	// 登录页通过fetch提交表单，无法读取重定向地址中的fragment，因此以JSON返回跳转地址
+	if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
+		c.JSON(http.StatusOK, gin.H{"redirect_to": location})
+		return
+	}
+
+	c.Redirect(http.StatusFound, location)
+}
@@ -0,0 +1,339 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"errors"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GrantTypeCIBA is the grant type of token requests for backchannel authentication requests
+// (OpenID Connect CIBA Core 1.0 Section 10.1).
+const GrantTypeCIBA = "urn:openid:params:grant-type:ciba"
+
+// Lifetimes of backchannel authentication requests: the default, and the longest a client may
+// ask for with requested_expiry.
+const (
+	cibaRequestLifetime    = 5 * time.Minute
+	cibaMaxRequestLifetime = 30 * time.Minute
+)
+
+// maxBindingMessageLength is the longest binding_message accepted, in characters, so that it
+// fits in the SMS and on the approval page.
+const maxBindingMessageLength = 64
+
+// BackchannelAuthenticationRequest represents the parameters of a backchannel authentication
+// request (OpenID Connect CIBA Core 1.0 Section 7.1).
+type BackchannelAuthenticationRequest struct {
+	Scope                   string `form:"scope"`                     // Requested scopes, must include openid
+	LoginHint               string `form:"login_hint"`                // Phone number of the user to authenticate
+	BindingMessage          string `form:"binding_message"`           // Message shown on both the client's and the user's device (optional)
+	ClientNotificationToken string `form:"client_notification_token"` // Bearer token of the ping callback (ping mode)
+	RequestedExpiry         int    `form:"requested_expiry"`          // Requested lifetime of the request in seconds (optional)
+}
+
+// BackchannelAuthentication handles backchannel authentication requests (OpenID Connect CIBA
+// Core 1.0). A client such as a call-centre application asks for a user identified by their
+// phone number, without redirecting a browser. The user receives an SMS with a link to approve
+// or deny the request on their phone, and the client obtains the tokens at the token endpoint
+// with grant_type=urn:openid:params:grant-type:ciba and the returned auth_req_id:
+//   - poll mode: the client polls the token endpoint at the returned interval
+//   - ping mode: the client's backchannel_client_notification_endpoint receives the auth_req_id
+//     with the client_notification_token as bearer token once the user answered
+//
+// Parameters:
+//   - scope: Requested scopes, must include openid
+//   - login_hint: Phone number of a registered user
+//   - binding_message: Short message shown to the user, e.g. a code read out on the phone (optional)
+//   - client_notification_token: Required in ping mode
+//   - requested_expiry: Seconds the user has to answer (optional, default 300)
+//
+// Example:
+//
+//	POST /bc-authorize
+//	Authorization: Basic base64(client_id:client_secret)
+//	Content-Type: application/x-www-form-urlencoded
+//	scope=openid%20profile&login_hint=13800138000&binding_message=W4SCT
+//
+// Response:
+//
+//	{
+//	  "auth_req_id": "1c266114-a1be-4252-8ad1-04986c5b9ac1",
+//	  "expires_in": 300,
+//	  "interval": 5
+//	}
+func (h *Handler) BackchannelAuthentication(c *gin.Context) {
+	// 验证客户端
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	if !containsString(client.GrantTypes, GrantTypeCIBA) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": "client is not allowed to use backchannel authentication"})
+		return
+	}
+
+	var req BackchannelAuthenticationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	if !hasScope(req.Scope, "openid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "scope must include openid"})
+		return
+	}
+	allowed, err := h.allowedScopes(client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	scope, err := h.scopeService.ResolveScope(strings.Fields(req.Scope), allowed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+		return
+	}
+
+	// 短信确认链接只能达到短信认证等级
+	scopes, err := h.scopeService.GetScopes(strings.Fields(scope))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if mandatory, _ := authenticationRequirements(client, scopes, ""); !services.ACRSatisfies(services.ACRPhoneSMS, mandatory) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unmet_authentication_requirements", "error_description": "the requested scopes require a stronger authentication than backchannel authentication provides"})
+		return
+	}
+
+	if req.LoginHint == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "login_hint is required"})
+		return
+	}
+	if utf8.RuneCountInString(req.BindingMessage) > maxBindingMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_binding_message", "error_description": "binding_message is too long"})
+		return
+	}
+	if client.BackchannelTokenDeliveryMode == services.CIBADeliveryModePing && req.ClientNotificationToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client_notification_token is required in ping mode"})
+		return
+	}
+
+	lifetime := cibaRequestLifetime
+	if req.RequestedExpiry > 0 {
+		lifetime = min(time.Duration(req.RequestedExpiry)*time.Second, cibaMaxRequestLifetime)
+	}
+
+	// 仅对已注册用户发起认证，不自动注册
+	user, err := h.userService.GetUserByPhone(req.LoginHint)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown_user_id", "error_description": "no user is registered with the login_hint"})
+		return
+	}
+
+	authReq := &models.BackchannelAuthenticationRequest{
+		ClientID:                client.ID,
+		UserID:                  user.ID,
+		Scope:                   scope,
+		BindingMessage:          req.BindingMessage,
+		ClientNotificationToken: req.ClientNotificationToken,
+	}
+	approvalToken, err := h.cibaService.CreateRequest(authReq, lifetime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	// 通过短信向用户发送确认链接
+	link := strings.TrimSuffix(h.config.BaseURL, "/") + "/bc-authorize/approve?" + url.Values{"token": {approvalToken}}.Encode()
+	if err := h.smsService.SendApprovalRequest(user.Phone, link, req.BindingMessage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to notify the user"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"auth_req_id": authReq.AuthReqID,
+		"expires_in":  int(lifetime.Seconds()),
+		"interval":    authReq.Interval,
+	})
+}
+
+// ShowBackchannelApproval shows the page of the link sent to the user by SMS, asking the user
+// to approve or deny a backchannel authentication request.
+//
+// Example:
+//
+//	GET /bc-authorize/approve?token=...
+func (h *Handler) ShowBackchannelApproval(c *gin.Context) {
+	token := c.Query("token")
+	authReq, err := h.cibaService.GetPendingRequest(token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	data, err := h.backchannelApprovalPageData(authReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	data["token"] = token
+	c.HTML(http.StatusOK, "ciba_approval.gohtml", data)
+}
+
+// BackchannelApproval handles the user's answer on the approval page. Opening the link sent
+// to the user's phone authenticates the user by SMS. Clients in ping mode are notified that the
+// request was answered.
+//
+// Parameters:
+//   - token: The approval token from the link
+//   - decision: "allow" or "deny"
+//
+// Example:
+//
+//	POST /bc-authorize/approve
+//	Content-Type: application/x-www-form-urlencoded
+//	token=...&decision=allow
+func (h *Handler) BackchannelApproval(c *gin.Context) {
+	approved := c.PostForm("decision") == "allow"
+	authn := models.UserAuthentication{Time: time.Now(), ACR: services.ACRPhoneSMS}
+
+	authReq, err := h.cibaService.Answer(c.PostForm("token"), approved, authn)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	client, err := h.oauthService.GetClient(authReq.ClientID)
+	if err == nil && client.BackchannelTokenDeliveryMode == services.CIBADeliveryModePing && client.BackchannelClientNotificationEndpoint != "" {
+		h.cibaService.Ping(client.BackchannelClientNotificationEndpoint, authReq)
+	}
+
+	data, err := h.backchannelApprovalPageData(authReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	c.HTML(http.StatusOK, "ciba_approval.gohtml", data)
+}
+
+// backchannelApprovalPageData returns the data of the approval page: the client, the binding
+// message, the scopes it asks for with their explanations and the status of the request.
+func (h *Handler) backchannelApprovalPageData(authReq *models.BackchannelAuthenticationRequest) (gin.H, error) {
+	client, err := h.oauthService.GetClient(authReq.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, err := h.scopeService.GetScopes(strings.Fields(authReq.Scope))
+	if err != nil {
+		return nil, err
+	}
+	var scopes []string
+	for _, s := range granted {
+		if s.Description != "" {
+			scopes = append(scopes, s.Description)
+		} else {
+			scopes = append(scopes, s.Name)
+		}
+	}
+
+	return gin.H{
+		"client_name":     client.Name,
+		"binding_message": authReq.BindingMessage,
+		"scopes":          scopes,
+		"status":          authReq.Status,
+	}, nil
+}
+
+// handleCIBAGrant issues tokens for a backchannel authentication request the user approved
+// (OpenID Connect CIBA Core 1.0 Section 10). Until then the client receives
+// authorization_pending, or slow_down when it polls faster than the request's interval.
+func (h *Handler) handleCIBAGrant(c *gin.Context, req TokenRequest, cnf *models.TokenConfirmation) {
+	// 验证客户端
+	client, cnf, ok := h.authenticateTokenClient(c, cnf)
+	if !ok {
+		return
+	}
+
+	if !containsString(client.GrantTypes, GrantTypeCIBA) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": "client is not allowed to use backchannel authentication"})
+		return
+	}
+
+	if req.AuthReqID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "auth_req_id is required"})
+		return
+	}
+
+	authReq, err := h.cibaService.Poll(client.ID, req.AuthReqID)
+	if err != nil {
+		code := "invalid_grant"
+		switch {
+		case errors.Is(err, services.ErrCIBAAuthorizationPending):
+			code = "authorization_pending"
+		case errors.Is(err, services.ErrCIBASlowDown):
+			code = "slow_down"
+		case errors.Is(err, services.ErrCIBAAccessDenied):
+			code = "access_denied"
+		case errors.Is(err, services.ErrCIBAExpiredToken):
+			code = "expired_token"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": code, "error_description": err.Error()})
+		return
+	}
+
+	user, err := h.userService.GetUserByID(authReq.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	grant := &models.GrantContext{
+		ClientID:       client.ID,
+		UserID:         user.ID,
+		Scope:          authReq.Scope,
+		Authentication: authReq.Authentication,
+	}
+	accessToken, ok := h.issueAccessToken(c, client, grant, req.Resource, cnf, nil)
+	if !ok {
+		return
+	}
+
+	response := TokenResponse{
+		AccessToken: accessToken.Token,
+		TokenType:   accessTokenType(cnf),
+		ExpiresIn:   accessToken.ExpiresIn,
+		Scope:       accessToken.Scope,
+	}
+
+	// 用户未在浏览器中登录，刷新令牌不绑定会话
+	policy := h.tokenPolicy(client)
+	if issuesRefreshToken(client, policy, authReq.Scope) {
+		refreshToken, err := h.oauthService.CreateRefreshToken(grant,
+			policy.RefreshTokenExpiry(authReq.Authentication), hasScope(authReq.Scope, ScopeOfflineAccess))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+		response.RefreshToken = refreshToken.Token
+	}
+
+	idToken, err := h.issueIDToken(client, user, authReq.Scope, &authReq.Authentication, grantClaimsRequest(""))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "failed to issue the ID token"})
+		return
+	}
+	response.IDToken = idToken
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,110 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"encoding/json"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"strings"
+)
+
+// userInfoJWTContentType is the media type of signed UserInfo responses.
+const userInfoJWTContentType = "application/jwt"
+
+// supportedUserInfoSigningAlgs lists the userinfo_signed_response_alg values clients can register.
+var supportedUserInfoSigningAlgs = []string{"RS256"}
+
+// resolveClaimsRequest validates the claims parameter of an authorization request
+// (OpenID Connect Core 1.0 Section 5.5) and limits it to the claims unlocked by the scopes the
+// client may be granted; the user grants the scopes behind them (see authorizationScopes). Essential and voluntary claims are released alike when the user has a
+// value for them; missing claims are omitted. The normalized request replaces req.Claims.
+func (h *Handler) resolveClaimsRequest(client *models.OAuthClient, req *AuthorizeRequest) error {
+	request, err := services.ParseClaimsRequest(req.Claims)
+	if err != nil || request == nil {
+		return err
+	}
+
+	allowed, err := h.allowedScopes(client)
+	if err != nil {
+		return err
+	}
+	scopes, err := h.scopeService.GetScopes(allowed)
+	if err != nil {
+		return err
+	}
+	var supported []string
+	for _, scope := range scopes {
+		supported = append(supported, scope.Claims...)
+	}
+	services.RestrictClaimsRequest(request, supported)
+
+	if len(request.UserInfo) == 0 && len(request.IDToken) == 0 {
+		req.Claims = ""
+		return nil
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req.Claims = string(encoded)
+	return nil
+}
+
+// authorizationScopes returns the scopes the user grants with an authorization request: the
+// requested scopes plus, for claims named in the claims parameter that they do not unlock, the
+// client's allowed scopes unlocking those claims. Claims of sensitive scopes thus need the user's
+// consent, and claims of scopes with a minimum_acr a strong enough login, as if the scopes were
+// requested.
+func (h *Handler) authorizationScopes(client *models.OAuthClient, req *AuthorizeRequest) ([]*models.Scope, error) {
+	scopes, err := h.scopeService.GetScopes(strings.Fields(req.Scope))
+	if err != nil {
+		return nil, err
+	}
+
+	request := grantClaimsRequest(req.Claims)
+	claims := append(services.ClaimNames(request.UserInfo), services.ClaimNames(request.IDToken)...)
+	var unlocked []string
+	for _, scope := range scopes {
+		unlocked = append(unlocked, scope.Claims...)
+	}
+	var missing []string
+	for _, claim := range claims {
+		if !containsString(unlocked, claim) {
+			missing = append(missing, claim)
+		}
+	}
+	if len(missing) == 0 {
+		return scopes, nil
+	}
+
+	allowed, err := h.allowedScopes(client)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := h.scopeService.GetScopes(allowed)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range candidates {
+		if hasScope(req.Scope, scope.Name) {
+			continue
+		}
+		for _, claim := range missing {
+			if containsString(scope.Claims, claim) {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes, nil
+}
+
+// grantClaimsRequest returns the claims request stored with a grant, or an empty request.
+func grantClaimsRequest(raw string) *models.ClaimsRequest {
+	request, err := services.ParseClaimsRequest(raw)
+	if err != nil || request == nil {
+		return &models.ClaimsRequest{}
+	}
+	return request
+}
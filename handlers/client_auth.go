@@ -0,0 +1,105 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authenticateClient authenticates the client calling a back-channel endpoint (RFC 6749 Section 2.3.1).
+// Credentials are read from the HTTP Basic Authorization header (client_secret_basic)
+// or from the client_id and client_secret form parameters (client_secret_post).
+// Clients registered for tls_client_auth or self_signed_tls_client_auth send only their
+// client_id and authenticate with their TLS client certificate (RFC 8705).
+//
+// Returns:
+//   - *models.OAuthClient: The authenticated client
+//   - error: An error if no credentials were sent or they are invalid
+func (h *Handler) authenticateClient(c *gin.Context) (*models.OAuthClient, error) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if ok {
+		// Basic认证中的凭据需要先进行表单URL解码
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return nil, fmt.Errorf("malformed client credentials")
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return nil, fmt.Errorf("malformed client credentials")
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	if clientID == "" {
+		return nil, fmt.Errorf("client authentication required")
+	}
+
+	client, err := h.oauthService.GetClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用客户端证书认证
+	switch client.TokenEndpointAuthMethod {
+	case services.AuthMethodTLSClientAuth, services.AuthMethodSelfSignedTLSClientAuth:
+		chain, err := h.mtlsService.ClientCertificates(c.Request, c.RemoteIP())
+		if err != nil {
+			return nil, err
+		}
+		if err := h.mtlsService.AuthenticateClient(client, chain); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	return h.oauthService.ValidateClient(clientID, clientSecret)
+}
+
+// certificateConfirmation binds issued access tokens to the client certificate when the
+// client is registered for certificate-bound access tokens (RFC 8705 Section 3).
+// The confirmation is added to cnf, which may already carry a DPoP key.
+func (h *Handler) certificateConfirmation(c *gin.Context, client *models.OAuthClient, cnf *models.TokenConfirmation) (*models.TokenConfirmation, error) {
+	if !client.TLSClientCertificateBoundAccessTokens {
+		return cnf, nil
+	}
+
+	chain, err := h.mtlsService.ClientCertificates(c.Request, c.RemoteIP())
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("client certificate required for certificate-bound access tokens")
+	}
+
+	if cnf == nil {
+		cnf = &models.TokenConfirmation{}
+	}
+	cnf.X5TS256 = services.CertificateThumbprint(chain[0])
+	return cnf, nil
+}
+
+// authenticateTokenClient authenticates the client at the token endpoint and completes
+// the confirmation of the access tokens to be issued with its certificate binding.
+// Errors are written to the response.
+func (h *Handler) authenticateTokenClient(c *gin.Context, cnf *models.TokenConfirmation) (*models.OAuthClient, *models.TokenConfirmation, bool) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return nil, nil, false
+	}
+
+	// 绑定客户端证书
+	cnf, err = h.certificateConfirmation(c, client, cnf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return nil, nil, false
+	}
+
+	return client, cnf, true
+}
@@ -0,0 +1,702 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportedGrantTypes lists the grant types clients can use at /token.
+var supportedGrantTypes = []string{"authorization_code", "refresh_token", GrantTypeTokenExchange, GrantTypeJWTBearer, GrantTypeCIBA}
+
+// selfRegistrableGrantTypes lists the grant types developers can register clients for.
+// The other grant types act for users without their interaction, so only administrators
+// grant them (PUT /api/admin/clients/:client_id/grant-types).
+var selfRegistrableGrantTypes = []string{"authorization_code", "refresh_token"}
+
+// supportedTokenEndpointAuthMethods lists the client authentication methods clients can register.
+var supportedTokenEndpointAuthMethods = []string{
+	services.AuthMethodClientSecretBasic,
+	services.AuthMethodClientSecretPost,
+	services.AuthMethodTLSClientAuth,
+	services.AuthMethodSelfSignedTLSClientAuth,
+}
+
+// initialAccessTokenLifetime is the default validity of initial access tokens issued by admins.
+const initialAccessTokenLifetime = 7 * 24 * time.Hour
+
+// ClientMetadata represents the client metadata accepted by the registration endpoint
+// (RFC 7591 Section 2, RFC 8705 Section 2.1.2, RFC 9101, RFC 9126, OpenID Connect Dynamic Client Registration 1.0 Section 2).
+type ClientMetadata struct {
+	RedirectURIs                          []string         `json:"redirect_uris"`                                        // Allowed redirect URIs
+	TokenEndpointAuthMethod               string           `json:"token_endpoint_auth_method,omitempty"`                 // Client authentication method
+	GrantTypes                            []string         `json:"grant_types,omitempty"`                                // Grant types the client will use
+	ResponseTypes                         []string         `json:"response_types,omitempty"`                             // Response types the client will use
+	ClientName                            string           `json:"client_name,omitempty"`                                // Human-readable client name
+	ClientURI                             string           `json:"client_uri,omitempty"`                                 // Client home page
+	LogoURI                               string           `json:"logo_uri,omitempty"`                                   // Client logo
+	Scope                                 string           `json:"scope,omitempty"`                                      // Scopes the client may request (space-separated)
+	Contacts                              []string         `json:"contacts,omitempty"`                                   // Contact email addresses
+	JWKSURI                               string           `json:"jwks_uri,omitempty"`                                   // URL of the client's JSON Web Key Set
+	JWKS                                  *services.JWKSet `json:"jwks,omitempty"`                                       // Client's JSON Web Key Set, by value
+	TLSClientAuthSubjectDN                string           `json:"tls_client_auth_subject_dn,omitempty"`                 // Expected certificate subject DN
+	TLSClientAuthSANDNS                   string           `json:"tls_client_auth_san_dns,omitempty"`                    // Expected dNSName SAN
+	TLSClientAuthSANURI                   string           `json:"tls_client_auth_san_uri,omitempty"`                    // Expected uniformResourceIdentifier SAN
+	TLSClientAuthSANIP                    string           `json:"tls_client_auth_san_ip,omitempty"`                     // Expected iPAddress SAN
+	TLSClientAuthSANEmail                 string           `json:"tls_client_auth_san_email,omitempty"`                  // Expected rfc822Name SAN
+	TLSClientCertificateBoundAccessTokens bool             `json:"tls_client_certificate_bound_access_tokens,omitempty"` // Bind access tokens to the client certificate
+	RequirePAR                            bool             `json:"require_pushed_authorization_requests,omitempty"`      // Require pushed authorization requests
+	RequireSignedRequestObject            bool             `json:"require_signed_request_object,omitempty"`              // Require signed request objects
+	RequestURIs                           []string         `json:"request_uris,omitempty"`                               // https URLs of request objects passed by reference
+	UserInfoSignedResponseAlg             string           `json:"userinfo_signed_response_alg,omitempty"`               // Sign UserInfo responses with this algorithm
+	IDTokenEncryptedResponseAlg           string           `json:"id_token_encrypted_response_alg,omitempty"`            // Encrypt ID tokens with this key management algorithm
+	IDTokenEncryptedResponseEnc           string           `json:"id_token_encrypted_response_enc,omitempty"`            // Encrypt ID tokens with this content encryption algorithm
+	SubjectType                           string           `json:"subject_type,omitempty"`                               // Subject identifier type: public or pairwise
+	SectorIdentifierURI                   string           `json:"sector_identifier_uri,omitempty"`                      // URL listing the redirect URIs of the client's sector
+	PostLogoutRedirectURIs                []string         `json:"post_logout_redirect_uris,omitempty"`                  // Allowed redirect URIs after logout
+	BackchannelLogoutURI                  string           `json:"backchannel_logout_uri,omitempty"`                     // URL receiving logout tokens
+	BackchannelLogoutSessionRequired      bool             `json:"backchannel_logout_session_required,omitempty"`        // Require the sid claim in logout tokens
+	FrontchannelLogoutURI                 string           `json:"frontchannel_logout_uri,omitempty"`                    // URL loaded in an iframe at logout
+	FrontchannelLogoutSessionRequired     bool             `json:"frontchannel_logout_session_required,omitempty"`       // Require iss and sid on the front-channel logout URL
+	BackchannelTokenDeliveryMode          string           `json:"backchannel_token_delivery_mode,omitempty"`            // CIBA token delivery mode: poll or ping
+	BackchannelClientNotificationEndpoint string           `json:"backchannel_client_notification_endpoint,omitempty"`   // CIBA ping callback URL
+	ApplicationType                       string           `json:"application_type,omitempty"`                           // Application type: web or native
+}
+
+// ClientRegistrationResponse represents the client information response (RFC 7591 Section 3.2.1).
+type ClientRegistrationResponse struct {
+	ClientID                string `json:"client_id"`                           // Issued client identifier
+	ClientSecret            string `json:"client_secret,omitempty"`             // Issued client secret (secret-based methods only)
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`                 // When the client ID was issued (Unix timestamp)
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`            // Always 0: secrets do not expire
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"` // Token for the client configuration endpoint
+	RegistrationClientURI   string `json:"registration_client_uri"`             // Client configuration endpoint
+	ClientMetadata
+}
+
+// clientUpdateRequest is the body of a client update request (RFC 7592 Section 2.2),
+// which repeats the client credentials alongside the full client metadata.
+type clientUpdateRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	ClientMetadata
+}
+
+// clientMetadataError describes invalid client metadata (RFC 7591 Section 3.2.2).
+type clientMetadataError struct {
+	Code        string // invalid_redirect_uri or invalid_client_metadata
+	Description string // Human-readable error description
+}
+
+// RegisterClient handles dynamic client registration requests (RFC 7591).
+// Registration requires an initial access token issued to a developer from the admin
+// dashboard; the new client is linked to that developer. The response contains the
+// client credentials and a registration access token for managing the client at
+// its registration_client_uri.
+//
+// Example:
+//
+//	POST /register
+//	Authorization: Bearer <initial_access_token>
+//	Content-Type: application/json
+//	{"redirect_uris": ["https://partner.example.com/callback"], "client_name": "Partner App", "scope": "openid profile"}
+//
+// Response:
+//
+//	{
+//	  "client_id": "4f1c0f6e-...",
+//	  "client_secret": "...",
+//	  "client_id_issued_at": 1640991600,
+//	  "client_secret_expires_at": 0,
+//	  "registration_access_token": "...",
+//	  "registration_client_uri": "http://localhost:8080/register/4f1c0f6e-...",
+//	  "redirect_uris": ["https://partner.example.com/callback"],
+//	  ...
+//	}
+func (h *Handler) RegisterClient(c *gin.Context) {
+	initialAccessToken := bearerToken(c)
+	if initialAccessToken == "" {
+		registrationChallenge(c, "initial access token required")
+		return
+	}
+
+	var metadata ClientMetadata
+	if err := c.ShouldBindJSON(&metadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	client := &models.OAuthClient{}
+	if merr := h.applyClientMetadata(client, &metadata); merr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": merr.Code, "error_description": merr.Description})
+		return
+	}
+
+	// 客户端可请求的scope必须在scope注册表中
+	if err := h.scopeService.ValidateScopes(strings.Fields(client.Scope)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	registrationAccessToken, err := h.registrationService.RegisterClient(initialAccessToken, client)
+	if errors.Is(err, services.ErrInvalidInitialAccessToken) {
+		registrationChallenge(c, err.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	response := h.clientRegistrationResponse(client)
+	response.RegistrationAccessToken = registrationAccessToken
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetRegisteredClient handles client read requests (RFC 7592 Section 2.1).
+//
+// Example:
+//
+//	GET /register/4f1c0f6e-...
+//	Authorization: Bearer <registration_access_token>
+func (h *Handler) GetRegisteredClient(c *gin.Context) {
+	client, ok := h.registeredClient(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.clientRegistrationResponse(client))
+}
+
+// UpdateRegisteredClient handles client update requests (RFC 7592 Section 2.2).
+// The request carries the complete new metadata, which replaces the current one;
+// omitted fields are reset to their defaults. The body must repeat the client_id,
+// and the client_secret if one is included must be the current secret.
+//
+// Example:
+//
+//	PUT /register/4f1c0f6e-...
+//	Authorization: Bearer <registration_access_token>
+//	Content-Type: application/json
+//	{"client_id": "4f1c0f6e-...", "redirect_uris": ["https://partner.example.com/callback"], "client_name": "Partner App"}
+func (h *Handler) UpdateRegisteredClient(c *gin.Context) {
+	client, ok := h.registeredClient(c)
+	if !ok {
+		return
+	}
+
+	var req clientUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	if req.ClientID != client.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": "client_id does not match the registration client URI"})
+		return
+	}
+	if req.ClientSecret != "" && req.ClientSecret != client.Secret {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": "client_secret does not match"})
+		return
+	}
+
+	if merr := h.applyClientMetadata(client, &req.ClientMetadata); merr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": merr.Code, "error_description": merr.Description})
+		return
+	}
+
+	// 客户端可请求的scope必须在scope注册表中
+	if err := h.scopeService.ValidateScopes(strings.Fields(client.Scope)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	if err := h.registrationService.UpdateClient(client); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.clientRegistrationResponse(client))
+}
+
+// DeleteRegisteredClient handles client delete requests (RFC 7592 Section 2.3).
+// The client, its authorization codes and its tokens are removed.
+//
+// Example:
+//
+//	DELETE /register/4f1c0f6e-...
+//	Authorization: Bearer <registration_access_token>
+func (h *Handler) DeleteRegisteredClient(c *gin.Context) {
+	client, ok := h.registeredClient(c)
+	if !ok {
+		return
+	}
+
+	if err := h.registrationService.DeleteClient(client.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// IssueInitialAccessToken issues an initial access token for a developer (admin endpoint).
+// The developer hands the token to the registration endpoint to register one client.
+//
+// Example:
+//
+//	POST /api/admin/developers/dev_123/initial-access-tokens
+//	Content-Type: application/json
+//	{"expires_in": "7d"}
+//
+// Response:
+//
+//	{
+//	  "initial_access_token": "...",
+//	  "developer_id": "dev_123",
+//	  "expires_at": "2024-01-08T12:00:00Z"
+//	}
+func (h *Handler) IssueInitialAccessToken(c *gin.Context) {
+	developerID := c.Param("developer_id")
+
+	var req struct {
+		ExpiresIn string `json:"expires_in"` // Duration like "1d", "7d"
+	}
+	// 请求体可选
+	c.ShouldBindJSON(&req)
+
+	lifetime := initialAccessTokenLifetime
+	if req.ExpiresIn != "" {
+		duration, err := parseDuration(req.ExpiresIn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_in format", "details": err.Error()})
+			return
+		}
+		lifetime = duration
+	}
+
+	token, expiresAt, err := h.registrationService.CreateInitialAccessToken(developerID, lifetime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to issue initial access token", "details": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"initial_access_token": token,
+		"developer_id":         developerID,
+		"expires_at":           expiresAt,
+	})
+}
+
+// registeredClient authenticates a request to the client configuration endpoint with the
+// registration access token of the client named in the URL. Errors are written to the response.
+func (h *Handler) registeredClient(c *gin.Context) (*models.OAuthClient, bool) {
+	token := bearerToken(c)
+	if token == "" {
+		registrationChallenge(c, "registration access token required")
+		return nil, false
+	}
+
+	client, err := h.registrationService.GetRegisteredClient(c.Param("client_id"), token)
+	if err != nil {
+		registrationChallenge(c, err.Error())
+		return nil, false
+	}
+
+	return client, true
+}
+
+// clientRegistrationResponse builds the client information response for a registered client.
+func (h *Handler) clientRegistrationResponse(client *models.OAuthClient) *ClientRegistrationResponse {
+	response := &ClientRegistrationResponse{
+		ClientID:              client.ID,
+		ClientIDIssuedAt:      client.CreatedAt.Unix(),
+		ClientSecretExpiresAt: 0,
+		RegistrationClientURI: strings.TrimSuffix(h.config.BaseURL, "/") + "/register/" + client.ID,
+		ClientMetadata: ClientMetadata{
+			RedirectURIs:                          client.RedirectURIs,
+			TokenEndpointAuthMethod:               client.TokenEndpointAuthMethod,
+			GrantTypes:                            client.GrantTypes,
+			ResponseTypes:                         client.ResponseTypes,
+			ClientName:                            client.Name,
+			ClientURI:                             client.ClientURI,
+			LogoURI:                               client.LogoURI,
+			Scope:                                 client.Scope,
+			Contacts:                              client.Contacts,
+			JWKSURI:                               client.JWKSURI,
+			TLSClientAuthSubjectDN:                client.TLSClientAuthSubjectDN,
+			TLSClientAuthSANDNS:                   client.TLSClientAuthSANDNS,
+			TLSClientAuthSANURI:                   client.TLSClientAuthSANURI,
+			TLSClientAuthSANIP:                    client.TLSClientAuthSANIP,
+			TLSClientAuthSANEmail:                 client.TLSClientAuthSANEmail,
+			TLSClientCertificateBoundAccessTokens: client.TLSClientCertificateBoundAccessTokens,
+			RequirePAR:                            client.RequirePAR,
+			RequireSignedRequestObject:            client.RequireSignedRequestObject,
+			RequestURIs:                           client.RequestURIs,
+			UserInfoSignedResponseAlg:             client.UserInfoSignedResponseAlg,
+			IDTokenEncryptedResponseAlg:           client.IDTokenEncryptedResponseAlg,
+			IDTokenEncryptedResponseEnc:           client.IDTokenEncryptedResponseEnc,
+			SubjectType:                           client.SubjectType,
+			SectorIdentifierURI:                   client.SectorIdentifierURI,
+			PostLogoutRedirectURIs:                client.PostLogoutRedirectURIs,
+			BackchannelLogoutURI:                  client.BackchannelLogoutURI,
+			BackchannelLogoutSessionRequired:      client.BackchannelLogoutSessionRequired,
+			FrontchannelLogoutURI:                 client.FrontchannelLogoutURI,
+			FrontchannelLogoutSessionRequired:     client.FrontchannelLogoutSessionRequired,
+			BackchannelTokenDeliveryMode:          client.BackchannelTokenDeliveryMode,
+			BackchannelClientNotificationEndpoint: client.BackchannelClientNotificationEndpoint,
+			ApplicationType:                       client.ApplicationType,
+		},
+	}
+
+	// 按值注册的JWKS
+	if client.JWKS != "" {
+		keySet := &services.JWKSet{}
+		if err := json.Unmarshal([]byte(client.JWKS), keySet); err == nil {
+			response.JWKS = keySet
+		}
+	}
+
+	// 仅基于密钥的认证方式返回客户端密钥
+	switch client.TokenEndpointAuthMethod {
+	case services.AuthMethodClientSecretBasic, services.AuthMethodClientSecretPost:
+		response.ClientSecret = client.Secret
+	}
+
+	return response
+}
+
+// applyClientMetadata validates client metadata, fills in defaults and copies it onto the client.
+func (h *Handler) applyClientMetadata(client *models.OAuthClient, metadata *ClientMetadata) *clientMetadataError {
+	// 填充默认值
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = services.AuthMethodClientSecretBasic
+	}
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{"authorization_code"}
+	}
+	if len(metadata.ResponseTypes) == 0 {
+		metadata.ResponseTypes = []string{"code"}
+	}
+	if metadata.Scope == "" {
+		metadata.Scope = "openid profile"
+	}
+	if metadata.SubjectType == "" {
+		metadata.SubjectType = services.SubjectTypePublic
+	}
+	if metadata.ApplicationType == "" {
+		metadata.ApplicationType = services.ApplicationTypeWeb
+	}
+
+	// 验证授权类型与响应类型
+	for _, grantType := range metadata.GrantTypes {
+		if !containsString(supportedGrantTypes, grantType) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported grant_type %q", grantType)}
+		}
+		// 特权授权类型只能由管理员授予，更新时可保留已授予的类型
+		if !containsString(selfRegistrableGrantTypes, grantType) && !containsString(client.GrantTypes, grantType) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("grant_type %q can only be granted by an administrator", grantType)}
+		}
+	}
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != "code" {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported response_type %q", responseType)}
+		}
+	}
+
+	// 验证重定向URI，原生应用另可使用回环地址与私有URI方案（RFC 8252）
+	if !containsString(services.ApplicationTypes, metadata.ApplicationType) {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported application_type %q", metadata.ApplicationType)}
+	}
+	if containsString(metadata.GrantTypes, "authorization_code") && len(metadata.RedirectURIs) == 0 {
+		return &clientMetadataError{Code: "invalid_redirect_uri", Description: "redirect_uris is required for the authorization_code grant"}
+	}
+	for _, redirectURI := range metadata.RedirectURIs {
+		if err := services.ValidateRedirectURI(metadata.ApplicationType, redirectURI); err != nil {
+			return &clientMetadataError{Code: "invalid_redirect_uri", Description: err.Error()}
+		}
+	}
+	for _, redirectURI := range metadata.PostLogoutRedirectURIs {
+		if err := validateRegisteredLogoutURI(redirectURI); err != nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "post_logout_redirect_uris: " + err.Error()}
+		}
+	}
+
+	// 验证请求对象地址
+	for _, requestURI := range metadata.RequestURIs {
+		if u, err := url.Parse(requestURI); err != nil || u.Scheme != "https" || u.Host == "" {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("request URI %q must be an https URL", requestURI)}
+		}
+	}
+
+	// 验证登出通知地址
+	if metadata.BackchannelLogoutURI != "" {
+		if err := services.ValidateClientEndpoint(metadata.BackchannelLogoutURI); err != nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "backchannel_logout_uri: " + err.Error()}
+		}
+	}
+	if metadata.FrontchannelLogoutURI != "" {
+		if err := validateRegisteredLogoutURI(metadata.FrontchannelLogoutURI); err != nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "frontchannel_logout_uri: " + err.Error()}
+		}
+		// 网页客户端的登出页面在https页面的iframe中加载
+		if metadata.ApplicationType == services.ApplicationTypeWeb && !strings.HasPrefix(metadata.FrontchannelLogoutURI, "https://") {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "frontchannel_logout_uri of a web client must use https"}
+		}
+	}
+
+	// 验证CIBA令牌交付方式
+	if containsString(metadata.GrantTypes, GrantTypeCIBA) {
+		if !containsString(services.CIBADeliveryModes, metadata.BackchannelTokenDeliveryMode) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "backchannel_token_delivery_mode must be poll or ping"}
+		}
+		if metadata.BackchannelTokenDeliveryMode == services.CIBADeliveryModePing {
+			if err := services.ValidateClientEndpoint(metadata.BackchannelClientNotificationEndpoint); err != nil {
+				return &clientMetadataError{Code: "invalid_client_metadata", Description: "backchannel_client_notification_endpoint: " + err.Error()}
+			}
+		}
+	}
+
+	// 验证客户端认证方式
+	if !containsString(supportedTokenEndpointAuthMethods, metadata.TokenEndpointAuthMethod) {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported token_endpoint_auth_method %q", metadata.TokenEndpointAuthMethod)}
+	}
+	if metadata.JWKSURI != "" && !strings.HasPrefix(metadata.JWKSURI, "https://") {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: "jwks_uri must use https"}
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case services.AuthMethodTLSClientAuth:
+		subjects := 0
+		for _, value := range []string{metadata.TLSClientAuthSubjectDN, metadata.TLSClientAuthSANDNS, metadata.TLSClientAuthSANURI,
+			metadata.TLSClientAuthSANIP, metadata.TLSClientAuthSANEmail} {
+			if value != "" {
+				subjects++
+			}
+		}
+		if subjects != 1 {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "tls_client_auth requires exactly one certificate subject parameter"}
+		}
+	case services.AuthMethodSelfSignedTLSClientAuth:
+		if metadata.JWKSURI == "" && metadata.JWKS == nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "self_signed_tls_client_auth requires jwks or jwks_uri"}
+		}
+	}
+
+	// 验证UserInfo响应签名算法
+	if metadata.UserInfoSignedResponseAlg != "" && !containsString(supportedUserInfoSigningAlgs, metadata.UserInfoSignedResponseAlg) {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported userinfo_signed_response_alg %q", metadata.UserInfoSignedResponseAlg)}
+	}
+
+	// 验证按值注册的JWKS
+	var jwks string
+	if metadata.JWKS != nil {
+		if metadata.JWKSURI != "" {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "jwks and jwks_uri must not both be present"}
+		}
+		for i := range metadata.JWKS.Keys {
+			if _, err := metadata.JWKS.Keys[i].PublicKey(); err != nil {
+				return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("invalid key in jwks: %s", err.Error())}
+			}
+		}
+		encoded, err := json.Marshal(metadata.JWKS)
+		if err != nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: err.Error()}
+		}
+		jwks = string(encoded)
+	}
+
+	// 验证ID令牌加密算法，加密密钥来自jwks或jwks_uri
+	if metadata.IDTokenEncryptedResponseEnc != "" && metadata.IDTokenEncryptedResponseAlg == "" {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: "id_token_encrypted_response_enc requires id_token_encrypted_response_alg"}
+	}
+	if metadata.IDTokenEncryptedResponseAlg != "" {
+		if !containsString(services.JWEKeyManagementAlgorithms, metadata.IDTokenEncryptedResponseAlg) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported id_token_encrypted_response_alg %q", metadata.IDTokenEncryptedResponseAlg)}
+		}
+		// 仅支持A256GCM，省略时即采用
+		if metadata.IDTokenEncryptedResponseEnc == "" {
+			metadata.IDTokenEncryptedResponseEnc = services.JWEEncA256GCM
+		}
+		if !containsString(services.JWEContentEncryptionAlgorithms, metadata.IDTokenEncryptedResponseEnc) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("id_token_encrypted_response_enc must be one of %s", strings.Join(services.JWEContentEncryptionAlgorithms, ", "))}
+		}
+		switch {
+		case metadata.JWKS != nil:
+			if _, err := metadata.JWKS.FindEncryptionKey(metadata.IDTokenEncryptedResponseAlg); err != nil {
+				return &clientMetadataError{Code: "invalid_client_metadata", Description: err.Error()}
+			}
+		case metadata.JWKSURI == "":
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "ID token encryption requires jwks or jwks_uri"}
+		}
+	}
+
+	// 验证主体标识符类型，成对标识符按扇区派生
+	if !containsString(h.subjectService.SupportedSubjectTypes(), metadata.SubjectType) {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("unsupported subject_type %q", metadata.SubjectType)}
+	}
+	if metadata.SectorIdentifierURI != "" {
+		sectorURIs, err := services.FetchSectorRedirectURIs(metadata.SectorIdentifierURI)
+		if err != nil {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "sector_identifier_uri: " + err.Error()}
+		}
+		for _, redirectURI := range metadata.RedirectURIs {
+			if !containsString(sectorURIs, redirectURI) {
+				return &clientMetadataError{Code: "invalid_client_metadata", Description: fmt.Sprintf("redirect URI %q is not listed at sector_identifier_uri", redirectURI)}
+			}
+		}
+	} else if metadata.SubjectType == services.SubjectTypePairwise && len(redirectURIHosts(metadata.RedirectURIs)) > 1 {
+		return &clientMetadataError{Code: "invalid_client_metadata", Description: "pairwise subjects for redirect URIs on several hosts require sector_identifier_uri"}
+	}
+
+	// 更新客户端时主体标识符须保持不变，否则已签发令牌中的sub无法解析回用户
+	if client.ID != "" {
+		if metadata.SubjectType != client.SubjectType {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "subject_type cannot be changed"}
+		}
+		updated := *client
+		updated.SectorIdentifierURI = metadata.SectorIdentifierURI
+		updated.RedirectURIs = metadata.RedirectURIs
+		if client.SubjectType == services.SubjectTypePairwise && services.SectorIdentifier(&updated) != services.SectorIdentifier(client) {
+			return &clientMetadataError{Code: "invalid_client_metadata", Description: "the sector of pairwise subjects cannot be changed"}
+		}
+	}
+
+	client.Name = metadata.ClientName
+	client.RedirectURIs = metadata.RedirectURIs
+	client.GrantTypes = metadata.GrantTypes
+	client.ResponseTypes = metadata.ResponseTypes
+	client.Scope = metadata.Scope
+	client.ClientURI = metadata.ClientURI
+	client.LogoURI = metadata.LogoURI
+	client.Contacts = metadata.Contacts
+	client.JWKSURI = metadata.JWKSURI
+	client.TokenEndpointAuthMethod = metadata.TokenEndpointAuthMethod
+	client.TLSClientAuthSubjectDN = metadata.TLSClientAuthSubjectDN
+	client.TLSClientAuthSANDNS = metadata.TLSClientAuthSANDNS
+	client.TLSClientAuthSANURI = metadata.TLSClientAuthSANURI
+	client.TLSClientAuthSANIP = metadata.TLSClientAuthSANIP
+	client.TLSClientAuthSANEmail = metadata.TLSClientAuthSANEmail
+	client.TLSClientCertificateBoundAccessTokens = metadata.TLSClientCertificateBoundAccessTokens
+	client.RequirePAR = metadata.RequirePAR
+	client.RequireSignedRequestObject = metadata.RequireSignedRequestObject
+	client.RequestURIs = metadata.RequestURIs
+	client.UserInfoSignedResponseAlg = metadata.UserInfoSignedResponseAlg
+	client.JWKS = jwks
+	client.IDTokenEncryptedResponseAlg = metadata.IDTokenEncryptedResponseAlg
+	client.IDTokenEncryptedResponseEnc = metadata.IDTokenEncryptedResponseEnc
+	client.SubjectType = metadata.SubjectType
+	client.SectorIdentifierURI = metadata.SectorIdentifierURI
+	client.PostLogoutRedirectURIs = metadata.PostLogoutRedirectURIs
+	client.BackchannelLogoutURI = metadata.BackchannelLogoutURI
+	client.BackchannelLogoutSessionRequired = metadata.BackchannelLogoutSessionRequired
+	client.FrontchannelLogoutURI = metadata.FrontchannelLogoutURI
+	client.FrontchannelLogoutSessionRequired = metadata.FrontchannelLogoutSessionRequired
+	client.BackchannelTokenDeliveryMode = metadata.BackchannelTokenDeliveryMode
+	client.BackchannelClientNotificationEndpoint = metadata.BackchannelClientNotificationEndpoint
+	client.ApplicationType = metadata.ApplicationType
+
+	return nil
+}
+
+// redirectURIHosts returns the distinct hosts of validated redirect URIs.
+func redirectURIHosts(redirectURIs []string) []string {
+	var hosts []string
+	for _, redirectURI := range redirectURIs {
+		if u, err := url.Parse(redirectURI); err == nil && !containsString(hosts, u.Host) {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
+
+// validateRegisteredLogoutURI checks a logout URI submitted for registration:
+// it must be an absolute URI without a fragment (RFC 6749 Section 3.1.2).
+func validateRegisteredLogoutURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be an absolute URI", redirectURI)
+	}
+	if u.Fragment != "" || strings.Contains(redirectURI, "#") {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", redirectURI)
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return fmt.Errorf("redirect URI %q must include a host", redirectURI)
+	}
+	return nil
+}
+
+// bearerToken returns the token of a Bearer Authorization header, or "" if there is none.
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// registrationChallenge writes a 401 response for a missing or invalid registration token.
+func registrationChallenge(c *gin.Context, description string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": description})
+}
+
+// containsString reports whether the slice contains the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateGrantTypes sets the grant types of a client (admin endpoint). Unlike dynamic
+// registration, it can grant token exchange, the JWT bearer grant and CIBA.
+//
+// Example:
+//
+//	PUT /api/admin/clients/api-gateway/grant-types
+//	Content-Type: application/json
+//	{"grant_types": ["authorization_code", "urn:ietf:params:oauth:grant-type:token-exchange"]}
+func (h *Handler) UpdateGrantTypes(c *gin.Context) {
+	var req struct {
+		GrantTypes []string `json:"grant_types" binding:"required"` // Grant types the client may use
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	for _, grantType := range req.GrantTypes {
+		if !containsString(supportedGrantTypes, grantType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update grant types", "details": fmt.Sprintf("unsupported grant_type %q", grantType)})
+			return
+		}
+	}
+
+	clientID := c.Param("client_id")
+	if err := h.oauthService.UpdateGrantTypes(clientID, req.GrantTypes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update grant types", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Grant types updated successfully",
+		"client_id":   clientID,
+		"grant_types": req.GrantTypes,
+	})
+}
@@ -0,0 +1,144 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScopeOfflineAccess is the scope requesting a refresh token that outlives the user's
+// SSO session (OpenID Connect Core 1.0 Section 11).
+const ScopeOfflineAccess = "offline_access"
+
+// hasScope reports whether a space-separated scope string contains a scope.
+func hasScope(scope, name string) bool {
+	return containsString(strings.Fields(scope), name)
+}
+
+// authorizeUser completes an authorization request for a logged-in user. Users who logged in
+// too weakly for the request are asked to authenticate again (see stepUpAuthentication).
+// Requests for sensitive scopes, such as offline access, including scopes whose claims are named
+// in the claims parameter, or with authorization details first ask the user for consent; other requests are granted directly.
+func (h *Handler) authorizeUser(c *gin.Context, client *models.OAuthClient, session *models.UserSession, req *AuthorizeRequest, responseMode string) {
+	scopes, err := h.authorizationScopes(client, req)
+	if err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("server_error", "", req.State))
+		return
+	}
+
+	if !h.stepUpAuthentication(c, client, session, req, scopes, responseMode) {
+		return
+	}
+
+	details, err := h.authorizationDetailViews(req.AuthorizationDetails)
+	if err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("server_error", "", req.State))
+		return
+	}
+
+	// 授权详情总是需要用户逐项确认
+	consent := len(details) > 0
+	for _, scope := range scopes {
+		consent = consent || scope.Sensitive
+	}
+	if consent {
+		h.extendPushedRequest(req)
+		c.HTML(http.StatusOK, "consent.gohtml", consentPageData(client, req, scopes, details))
+		return
+	}
+
+	h.issueAuthorizationCode(c, client, session, req, responseMode)
+}
+
+// consentPageData returns the data of the consent screen: the client, the scopes and
+// authorization details it asks for with their explanations, and the authorization parameters
+// carried through the form.
+func consentPageData(client *models.OAuthClient, req *AuthorizeRequest, granted []*models.Scope, details []authorizationDetailView) gin.H {
+	var scopes []gin.H
+	for _, s := range granted {
+		description := s.Description
+		if description == "" {
+			description = s.Name
+		}
+		scopes = append(scopes, gin.H{"name": s.Name, "description": description, "offline": s.Name == ScopeOfflineAccess})
+	}
+
+	data := loginPageData(req)
+	data["client_name"] = client.Name
+	data["scopes"] = scopes
+	data["details"] = details
+	return data
+}
+
+// Consent handles the user's answer on the consent screen. When the user allows the request,
+// an authorization code is issued for the requested scope, including sensitive scopes;
+// otherwise the client receives an access_denied error.
+//
+// Parameters:
+//   - decision: "allow" or "deny"
+//   - The authorization request parameters carried through the consent form
+//
+// Example:
+//
+//	POST /consent
+//	Cookie: flash_oauth2_session=...
+//	Content-Type: application/x-www-form-urlencoded
+//	decision=allow&client_id=my-app&redirect_uri=https://app.com/callback&scope=openid%20offline_access&response_type=code
+func (h *Handler) Consent(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	session := h.currentSession(c)
+	if session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login_required", "error_description": "the user is not logged in"})
+		return
+	}
+
+	client, responseMode, ok := h.resolveAuthorizeRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	// 同意页面提交前会话的认证等级可能已不满足要求
+	scopes, err := h.authorizationScopes(client, &req)
+	if err != nil {
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("server_error", "", req.State))
+		return
+	}
+	if !h.stepUpAuthentication(c, client, session, &req, scopes, responseMode) {
+		return
+	}
+
+	if c.PostForm("decision") != "allow" {
+		// 用户拒绝授权，推送的请求同样作废
+		if strings.HasPrefix(req.RequestURI, services.PARRequestURIPrefix) {
+			h.parService.Consume(req.RequestURI)
+		}
+		h.sendAuthorizationResponse(c, client.ID, req.RedirectURI, responseMode,
+			authorizationErrorParams("access_denied", "the user denied the request", req.State))
+		return
+	}
+
+	h.issueAuthorizationCode(c, client, session, &req, responseMode)
+}
+
+// issuesRefreshToken reports whether a refresh token is issued with the tokens of a grant:
+// the client must be allowed the refresh_token grant and, for OpenID Connect requests, the
+// user must have granted offline access.
+func issuesRefreshToken(client *models.OAuthClient, policy services.TokenPolicy, scope string) bool {
+	if !policy.IssueRefreshTokens || !containsString(client.GrantTypes, "refresh_token") {
+		return false
+	}
+
+	return !hasScope(scope, "openid") || hasScope(scope, ScopeOfflineAccess)
+}
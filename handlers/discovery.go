@@ -0,0 +1,117 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenIDConfiguration handles OpenID Provider metadata requests
+// (OpenID Connect Discovery 1.0 Section 4, RFC 8414).
+// The document advertises the server endpoints and the features clients can rely on,
+// so that relying parties and libraries can configure themselves automatically.
+//
+// Example:
+//
+//	GET /.well-known/openid-configuration
+//
+// Response:
+//
+//	{
+//	  "issuer": "flash-oauth2",
+//	  "authorization_endpoint": "http://localhost:8080/authorize",
+//	  "token_endpoint": "http://localhost:8080/token",
+//	  ...
+//	}
+func (h *Handler) OpenIDConfiguration(c *gin.Context) {
+	baseURL := strings.TrimSuffix(h.config.BaseURL, "/")
+
+	// 从scope注册表生成支持的scope与声明
+	scopes, err := h.scopeService.ListScopes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	scopeNames := []string{}
+	claims := []string{}
+	for _, scope := range scopes {
+		scopeNames = append(scopeNames, scope.Name)
+		for _, claim := range scope.Claims {
+			if !containsString(claims, claim) {
+				claims = append(claims, claim)
+			}
+		}
+	}
+
+	// 从授权详情类型注册表生成支持的类型
+	detailsTypes, err := h.authorizationDetailsService.ListTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	detailsTypeNames := []string{}
+	for _, detailsType := range detailsTypes {
+		detailsTypeNames = append(detailsTypeNames, detailsType.Type)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                h.config.Issuer,
+		"authorization_endpoint":                baseURL + "/authorize",
+		"token_endpoint":                        baseURL + "/token",
+		"userinfo_endpoint":                     baseURL + "/userinfo",
+		"introspection_endpoint":                baseURL + "/introspect",
+		"end_session_endpoint":                  baseURL + "/logout",
+		"jwks_uri":                              baseURL + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              supportedResponseModes,
+		"grant_types_supported":                 supportedGrantTypes,
+		"subject_types_supported":               h.subjectService.SupportedSubjectTypes(),
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		// Encrypted ID tokens (nested JWT, signed then encrypted to the client's key)
+		"id_token_encryption_alg_values_supported": services.JWEKeyManagementAlgorithms,
+		"id_token_encryption_enc_values_supported": services.JWEContentEncryptionAlgorithms,
+		"token_endpoint_auth_methods_supported":    supportedTokenEndpointAuthMethods,
+		"registration_endpoint":                    baseURL + "/register",
+		"scopes_supported":                         scopeNames,
+		"claims_supported":                         claims,
+		"claims_parameter_supported":               true,
+		"acr_values_supported":                     services.ACRValues,
+		// Back-Channel and Front-Channel Logout
+		"backchannel_logout_supported":          true,
+		"backchannel_logout_session_supported":  true,
+		"frontchannel_logout_supported":         true,
+		"frontchannel_logout_session_supported": true,
+		// Client-Initiated Backchannel Authentication (OpenID Connect CIBA Core 1.0)
+		"backchannel_authentication_endpoint":        baseURL + "/bc-authorize",
+		"backchannel_token_delivery_modes_supported": services.CIBADeliveryModes,
+		"backchannel_user_code_parameter_supported":  false,
+		// Signed UserInfo responses
+		"userinfo_signing_alg_values_supported": supportedUserInfoSigningAlgs,
+		// JWT Secured Authorization Response Mode (JARM)
+		"authorization_signing_alg_values_supported": []string{"RS256"},
+		// Rich Authorization Requests (RFC 9396)
+		"authorization_details_types_supported": detailsTypeNames,
+		// Pushed Authorization Requests (RFC 9126)
+		"pushed_authorization_request_endpoint": baseURL + "/par",
+		"require_pushed_authorization_requests": false,
+		// JWT-Secured Authorization Requests (RFC 9101)
+		"request_parameter_supported":                 true,
+		"request_uri_parameter_supported":             true,
+		"require_request_uri_registration":            true,
+		"require_signed_request_object":               false,
+		"request_object_signing_alg_values_supported": services.ClientJWTSigningAlgorithms,
+		// Demonstrating Proof of Possession (RFC 9449)
+		"dpop_signing_alg_values_supported": services.ClientJWTSigningAlgorithms,
+		// Mutual TLS client authentication and certificate-bound tokens (RFC 8705)
+		"tls_client_certificate_bound_access_tokens": true,
+		// Authenticated token introspection with JWT responses (RFC 7662, RFC 9701)
+		"introspection_endpoint_auth_methods_supported": supportedTokenEndpointAuthMethods,
+		"introspection_signing_alg_values_supported":    []string{"RS256"},
+		// Token revocation (RFC 7009)
+		"revocation_endpoint":                        baseURL + "/revoke",
+		"revocation_endpoint_auth_methods_supported": supportedTokenEndpointAuthMethods,
+	})
+}
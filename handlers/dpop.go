@@ -0,0 +1,75 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"errors"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifyDPoPProof verifies the DPoP proof sent with the request (RFC 9449), if any.
+// Whenever a proof is present a fresh nonce is returned in the DPoP-Nonce header,
+// so that the client can include it in its next proof.
+//
+// Parameters:
+//   - accessToken: The access token the proof must be bound to, empty at the token endpoint
+//
+// Returns:
+//   - string: The JWK thumbprint of the proof key, empty when no proof was sent
+//   - error: An error if the proof is invalid
+func (h *Handler) verifyDPoPProof(c *gin.Context, accessToken string) (string, error) {
+	proofs := c.Request.Header.Values("DPoP")
+	if len(proofs) == 0 {
+		return "", nil
+	}
+
+	// 下发新的nonce供客户端后续使用
+	if nonce, err := h.dpopService.IssueNonce(); err == nil {
+		c.Header("DPoP-Nonce", nonce)
+	}
+
+	if len(proofs) > 1 {
+		return "", fmt.Errorf("only one DPoP proof may be sent")
+	}
+
+	return h.dpopService.VerifyProof(proofs[0], services.DPoPProofRequest{
+		Method:      c.Request.Method,
+		URL:         strings.TrimSuffix(h.config.BaseURL, "/") + c.Request.URL.Path,
+		AccessToken: accessToken,
+	})
+}
+
+// tokenConfirmation verifies the DPoP proof sent to the token endpoint and returns
+// the confirmation issued access tokens are bound to, or nil for bearer tokens.
+// Errors are written to the response.
+func (h *Handler) tokenConfirmation(c *gin.Context) (*models.TokenConfirmation, bool) {
+	jkt, err := h.verifyDPoPProof(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": dpopErrorCode(err), "error_description": err.Error()})
+		return nil, false
+	}
+	if jkt == "" {
+		return nil, true
+	}
+
+	return &models.TokenConfirmation{JKT: jkt}, true
+}
+
+// dpopChallenge writes a 401 response with a DPoP WWW-Authenticate challenge.
+func dpopChallenge(c *gin.Context, code, description string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`DPoP error="%s", algs="%s"`, code, strings.Join(services.ClientJWTSigningAlgorithms, " ")))
+	c.JSON(http.StatusUnauthorized, gin.H{"error": code, "error_description": description})
+}
+
+// dpopErrorCode maps a DPoP proof verification error to its error code.
+func dpopErrorCode(err error) string {
+	if errors.Is(err, services.ErrDPoPNonceRequired) {
+		return "use_dpop_nonce"
+	}
+	return "invalid_dpop_proof"
+}
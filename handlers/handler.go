@@ -16,11 +16,27 @@ import (
 // Handler contains all the service dependencies needed for OAuth2 operations.
 // It acts as a container for business logic services and configuration.
 type Handler struct {
-	userService  *services.UserService  // User management and authentication
-	oauthService *services.OAuthService // OAuth2 core operations
-	jwtService   *services.JWTService   // JWT token operations
-	smsService   services.SMSService    // SMS service for testing access
-	config       *config.Config         // Server configuration
+	userService                 *services.UserService                 // User management and authentication
+	oauthService                *services.OAuthService                // OAuth2 core operations
+	jwtService                  *services.JWTService                  // JWT token operations
+	parService                  *services.PARService                  // Pushed authorization request storage
+	clientJWTService            *services.ClientJWTService            // Verification of client-signed JWTs
+	jwtBearerService            *services.JWTBearerService            // JWT bearer assertion verification (RFC 7523)
+	dpopService                 *services.DPoPService                 // DPoP proof verification (RFC 9449)
+	mtlsService                 *services.MTLSService                 // Mutual TLS client authentication (RFC 8705)
+	registrationService         *services.ClientRegistrationService   // Dynamic client registration (RFC 7591)
+	resourceService             *services.ResourceService             // API resource registry (RFC 8707)
+	scopeService                *services.ScopeService                // Scope catalogue
+	authorizationDetailsService *services.AuthorizationDetailsService // Authorization details types (RFC 9396)
+	appService                  *services.AppManagementService        // External applications linked to clients
+	revocationService           *services.TokenRevocationService      // Revoked JWT access tokens (RFC 7009)
+	sessionService              *services.SessionService              // SSO sessions of logged-in users
+	subjectService              *services.SubjectService              // Public and pairwise subject identifiers
+	backchannelLogoutService    *services.BackchannelLogoutService    // Logout token delivery to clients (OpenID Connect Back-Channel Logout 1.0)
+	cibaService                 *services.CIBAService                 // Backchannel authentication requests (OpenID Connect CIBA Core 1.0)
+	totpService                 *services.TOTPService                 // Authenticator app codes for step-up authentication
+	smsService                  services.SMSService                   // SMS service for verification codes and approval links
+	config                      *config.Config                        // Server configuration
 }
 
 // New creates a new Handler instance with all required dependencies.
@@ -41,14 +57,32 @@ func New(db *sql.DB, redis *redis.Client, cfg *config.Config) *Handler {
 	// 创建用户服务，传入SMS服务
 	userService := services.NewUserService(db, redis, smsService)
 	oauthService := services.NewOAuthService(db)
-	jwtService := services.NewJWTService(cfg.JWTPrivateKey, cfg.JWTPublicKey, "flash-oauth2")
+	jwtService := services.NewJWTService(cfg.JWTPrivateKey, cfg.JWTPublicKey, cfg.Issuer)
+	appService := services.NewAppManagementService(db)
+	clientJWTService := services.NewClientJWTService(appService, redis)
 
 	return &Handler{
-		userService:  userService,
-		oauthService: oauthService,
-		jwtService:   jwtService,
-		smsService:   smsService,
-		config:       cfg,
+		userService:                 userService,
+		oauthService:                oauthService,
+		jwtService:                  jwtService,
+		parService:                  services.NewPARService(redis),
+		clientJWTService:            clientJWTService,
+		jwtBearerService:            services.NewJWTBearerService(appService, redis),
+		dpopService:                 services.NewDPoPService(redis, cfg.DPoPRequireNonce),
+		mtlsService:                 services.NewMTLSService(cfg, clientJWTService),
+		registrationService:         services.NewClientRegistrationService(db),
+		resourceService:             services.NewResourceService(db),
+		scopeService:                services.NewScopeService(db),
+		authorizationDetailsService: services.NewAuthorizationDetailsService(db),
+		appService:                  appService,
+		revocationService:           services.NewTokenRevocationService(redis),
+		sessionService:              services.NewSessionService(redis, cfg.SSOSessionLifetime),
+		subjectService:              services.NewSubjectService(db, cfg.PairwiseSubjectSalt),
+		backchannelLogoutService:    services.NewBackchannelLogoutService(backchannelLogoutAttempts, backchannelLogoutRetryDelay),
+		cibaService:                 services.NewCIBAService(redis),
+		totpService:                 services.NewTOTPService(db, redis, cfg.Issuer),
+		smsService:                  smsService,
+		config:                      cfg,
 	}
 }
 
@@ -132,6 +166,8 @@ func getAPIDocumentationHTML() string {
         }
         .get { background: #007bff; }
         .post { background: #28a745; }
+        .put { background: #fd7e14; }
+        .delete { background: #dc3545; }
         .nav {
             background: #343a40;
             padding: 15px;
@@ -202,17 +238,41 @@ func getAPIDocumentationHTML() string {
             <span class="method get">GET</span>
             <strong>/authorize</strong>
             <span class="badge">OAuth2</span>
-            <p>Initiates the OAuth2 authorization flow. Redirects user to login if not authenticated.</p>
+            <p>Initiates the OAuth2 authorization flow. Redirects user to login if not authenticated; users with an SSO session (set at login) are not asked to log in again.</p>
             <strong>Parameters:</strong>
             <ul>
                 <li><code>client_id</code> (required): OAuth2 client identifier</li>
-                <li><code>redirect_uri</code> (required): Callback URL after authorization</li>
+                <li><code>redirect_uri</code> (required): Callback URL after authorization, exactly as registered. Native clients may use any port with a registered loopback redirect URI such as <code>http://127.0.0.1/callback</code> (RFC 8252)</li>
                 <li><code>response_type</code> (required): Must be "code"</li>
-                <li><code>scope</code> (optional): Requested scopes (space-separated)</li>
+                <li><code>scope</code> (optional): Requested scopes (space-separated), defaulting to the client's registered scopes. Scopes must be registered in the scope catalogue (<code>/api/admin/scopes</code>, advertised as <code>scopes_supported</code>); unknown scopes are rejected with <code>invalid_scope</code>, and scopes not allowed for the client or its linked application are dropped. Sensitive scopes need the user's approval on a consent screen. <code>offline_access</code> requests a refresh token that remains valid after the user logs out; it is ignored for clients without the <code>refresh_token</code> grant.</li>
                 <li><code>state</code> (optional): Client state parameter</li>
+                <li><code>response_mode</code> (optional): "query" (default), "fragment", "form_post", or the JWT secured variants "query.jwt", "fragment.jwt", "form_post.jwt", "jwt"</li>
+                <li><code>request</code> (optional): Signed request object (RFC 9101) carrying the parameters above; signed with an application key pair or a key from the client's <code>jwks_uri</code>, and expiring (<code>exp</code>) within an hour</li>
+                <li><code>request_uri</code> (optional): A <code>/par</code> reference, or one of the client's registered <code>request_uris</code> hosting a signed request object. Other URLs are not fetched, nor are URLs resolving to private or loopback addresses.</li>
+                <li><code>resource</code> (optional, repeatable): Identifier of a registered API the tokens are requested for (RFC 8707); unknown resources are rejected with <code>invalid_target</code></li>
+                <li><code>claims</code> (optional): JSON claims request (OpenID Connect Core 1.0 Section 5.5) naming individual claims for the <code>userinfo</code> response and the <code>id_token</code>, e.g. <code>{"id_token":{"email":{"essential":true}},"userinfo":{"name":null}}</code>. Claims outside those of the scopes allowed for the client are ignored; the scopes unlocking the other claims are granted with the request, so claims of sensitive scopes are shown on the consent screen and claims of scopes with a <code>minimum_acr</code> require a strong enough login; requested claims are returned when the user has a value for them.</li>
+                <li><code>authorization_details</code> (optional): JSON array of fine-grained permissions (RFC 9396), e.g. <code>[{"type":"payment_initiation","instructedAmount":{"currency":"CNY","amount":"500.00"}}]</code>. Each <code>type</code> must be registered (<code>/api/admin/authorization-details-types</code>, advertised as <code>authorization_details_types_supported</code>) and may only carry its registered fields besides <code>locations</code>, <code>actions</code>, <code>datatypes</code>, <code>identifier</code> and <code>privileges</code>; otherwise the request fails with <code>invalid_authorization_details</code>. The user approves them on the consent screen.</li>
+                <li><code>acr_values</code> (optional): Authentication context classes the user should log in with, from <code>acr_values_supported</code>: "urn:flash-oauth2:acr:sms" (SMS code) or "urn:flash-oauth2:acr:sms-totp" (SMS code and authenticator app code). Scopes (<code>minimum_acr</code>) and clients (<code>PUT /api/admin/clients/{client_id}/minimum-acr</code>) can require a minimum. Users whose session is weaker log in again with an authenticator app code; users without an authenticator app (<code>POST /api/admin/users/{user_id}/totp</code>) are refused with <code>unmet_authentication_requirements</code> when the minimum is not met. ID tokens and access tokens carry the <code>acr</code> of the login. API routes protected with <code>RequireAccessToken</code> reject tokens from a weaker login with <code>insufficient_user_authentication</code> (RFC 9470).</li>
             </ul>
         </div>
 
+        <div class="endpoint">
+            <span class="method post">POST</span>
+            <strong>/par</strong>
+            <span class="badge">OAuth2</span>
+            <p>Pushed Authorization Requests (RFC 9126). Authenticated clients push the authorization parameters and receive a <code>request_uri</code> valid for 90 seconds, which is then passed to <code>/authorize</code> together with <code>client_id</code>. Once the user is shown the login or consent screen, the request stays valid for 10 minutes.</p>
+            <strong>Authentication:</strong> HTTP Basic or <code>client_id</code>/<code>client_secret</code> form parameters
+        </div>
+
+        <div class="endpoint">
+            <span class="method post">POST</span>
+            <strong>/bc-authorize</strong>
+            <span class="badge">OIDC</span>
+            <p>Client-Initiated Backchannel Authentication (OpenID Connect CIBA Core 1.0). Clients registered for the "urn:openid:params:grant-type:ciba" grant send <code>scope</code> (including <code>openid</code>), <code>login_hint</code> (the phone number of a registered user), an optional <code>binding_message</code> and, in ping mode, a <code>client_notification_token</code>. The user receives an SMS with a link to approve or deny the request (<code>/bc-authorize/approve</code>), and the client receives <code>auth_req_id</code>, <code>expires_in</code> and <code>interval</code>. Unknown phone numbers get <code>unknown_user_id</code>, and scopes or clients requiring more than an SMS login get <code>unmet_authentication_requirements</code>.</p>
+            <p>The client then calls <code>/token</code> with <code>grant_type</code> "urn:openid:params:grant-type:ciba" and <code>auth_req_id</code>, receiving <code>authorization_pending</code> until the user answered, <code>slow_down</code> when polling faster than the interval, <code>access_denied</code> or <code>expired_token</code>. With <code>backchannel_token_delivery_mode</code> "poll" the client polls at the interval; with "ping" its <code>backchannel_client_notification_endpoint</code> (an https URL on a public host) receives <code>{"auth_req_id": ...}</code> with the <code>client_notification_token</code> as bearer token once the user answered.</p>
+            <strong>Authentication:</strong> HTTP Basic or <code>client_id</code>/<code>client_secret</code> form parameters
+        </div>
+
         <div class="endpoint">
             <span class="method post">POST</span>
             <strong>/token</strong>
@@ -221,29 +281,64 @@ func getAPIDocumentationHTML() string {
             <strong>Content-Type:</strong> <code>application/x-www-form-urlencoded</code><br>
             <strong>Parameters:</strong>
             <ul>
-                <li><code>grant_type</code>: "authorization_code" or "refresh_token"</li>
+                <li><code>grant_type</code>: "authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:jwt-bearer" or "urn:openid:params:grant-type:ciba" (with <code>auth_req_id</code>, see <code>/bc-authorize</code>)</li>
                 <li><code>code</code>: Authorization code (for auth code flow)</li>
                 <li><code>client_id</code>: OAuth2 client identifier</li>
                 <li><code>client_secret</code>: OAuth2 client secret</li>
                 <li><code>redirect_uri</code>: Must match original request</li>
+                <li><code>resource</code> (optional): Registered API the access token is for (RFC 8707). The token's <code>aud</code> is the resource, its scope is limited to the resource's scopes and it uses the resource's lifetime. Must be one of the resources of the authorization, if any were requested.</li>
+                <li><code>authorization_details</code> (optional): Limits the access token to some of the authorization details of the grant (RFC 9396); details that were not granted fail with <code>invalid_authorization_details</code>. The response and the access token carry the token's <code>authorization_details</code>; tokens for a <code>resource</code> only carry those whose <code>locations</code> include it or that have none.</li>
             </ul>
+            <strong>Token Exchange:</strong> Clients registered for the token exchange grant (RFC 8693) send <code>subject_token</code> and <code>subject_token_type</code> ("urn:ietf:params:oauth:token-type:access_token"), plus optional <code>audience</code>, <code>scope</code> and <code>actor_token</code>/<code>actor_token_type</code>. The issued token is addressed to the audience, never exceeds the subject token's scope, and names the actor in its <code>act</code> claim. Subject and actor tokens must be addressed to the client or to an audience of its policy, and sender-constrained tokens must be presented with the DPoP key or client certificate they are bound to. Audiences and scopes are limited by the client's exchange policy (<code>PUT /api/admin/clients/{client_id}/token-exchange-policy</code>); clients without scopes in their policy cannot exchange tokens.<br>
+            <strong>JWT Bearer:</strong> Trusted backend apps send <code>grant_type</code> "urn:ietf:params:oauth:grant-type:jwt-bearer" and an <code>assertion</code> (RFC 7523) signed with one of their app key pairs (<code>kid</code> header). The assertion is issued by the client (<code>iss</code>), addressed to the issuer or the token endpoint (<code>aud</code>), names the user ID in <code>sub</code>, carries a unique <code>jti</code> and expires within 10 minutes. Revoked and expired keys are rejected; the subjects an app may assert are set with <code>PUT /api/admin/apps/{app_id}/assertion-subjects</code> and the scope is limited to the app's scopes.<br>
+            <strong>DPoP (optional):</strong> Send a <code>DPoP</code> proof header (RFC 9449) to receive a <code>token_type</code> "DPoP" access token bound to the proof key. A fresh nonce is returned in the <code>DPoP-Nonce</code> header; <code>use_dpop_nonce</code> errors ask the client to retry with it.<br>
+            <strong>Token lifetimes:</strong> Access token, ID token, refresh token and authorization code lifetimes default to the server settings and can be set per client with <code>PUT /api/admin/clients/{client_id}/token-policy</code> (seconds), together with a refresh token idle timeout, a maximum session lifetime after login and whether refresh tokens are issued at all. Refresh tokens may only be used by the client they were issued to, and are only issued to clients with the <code>refresh_token</code> grant; OpenID Connect requests also need the <code>offline_access</code> scope.<br>
+            <strong>Mutual TLS (optional):</strong> Clients registered for <code>tls_client_auth</code> or <code>self_signed_tls_client_auth</code> send only <code>client_id</code> and authenticate with their TLS client certificate (RFC 8705). Self-signed certificates are registered as <code>x5c</code> in the client's <code>jwks</code> or at its <code>jwks_uri</code>; key sets fetched from a <code>jwks_uri</code> are cached for 5 minutes. Clients with <code>tls_client_certificate_bound_access_tokens</code> receive access tokens bound to the certificate (<code>cnf.x5t#S256</code>), which must then be presented over mTLS.
         </div>
 
         <div class="endpoint">
             <span class="method post">POST</span>
             <strong>/introspect</strong>
             <span class="badge">OAuth2</span>
-            <p>Validates and returns metadata about an access token.</p>
+            <p>Validates and returns metadata about an access token. Token metadata is only disclosed to the token's audience or the client it was issued to; revoked tokens and tokens whose client, application or user is no longer active are reported as inactive. Send <code>Accept: application/token-introspection+jwt</code> to receive the result as a signed JWT in its <code>token_introspection</code> claim (RFC 9701).</p>
+            <p>Access tokens are JWTs following RFC 9068: the header carries <code>typ</code> "at+jwt" and <code>kid</code>, and the claims include <code>iss</code>, <code>sub</code> (user ID as a string), <code>aud</code>, <code>client_id</code>, <code>scope</code>, <code>exp</code>, <code>iat</code>, a random <code>jti</code>, after an interactive login, <code>auth_time</code> and <code>acr</code> and, for grants with rich authorization requests, <code>authorization_details</code>, which is also returned in the introspection response. Tokens of any other type are reported as inactive.</p>
+            <p>Clients configured for opaque access tokens (<code>PUT /api/admin/clients/{client_id}/access-token-format</code> with <code>{"format": "opaque"}</code>) receive random reference tokens instead. Only their hash is stored; resource servers resolve them here.</p>
+            <strong>Authentication:</strong> Clients use their token endpoint credentials. Resource servers use HTTP Basic with their resource identifier and the secret returned when the resource was registered (<code>POST /api/admin/resources</code>).
+        </div>
+
+        <div class="endpoint">
+            <span class="method post">POST</span>
+            <strong>/revoke</strong>
+            <span class="badge">OAuth2</span>
+            <p>Token revocation (RFC 7009). Clients revoke their opaque access tokens and refresh tokens with <code>token</code> and an optional <code>token_type_hint</code>; revoked tokens stop working immediately. Unknown tokens are ignored.</p>
+            <strong>Authentication:</strong> HTTP Basic or <code>client_id</code>/<code>client_secret</code> form parameters
+        </div>
+
+        <div class="endpoint">
+            <span class="method post">POST</span>
+            <strong>/register</strong>
+            <span class="badge">OAuth2</span>
+            <p>Dynamic Client Registration (RFC 7591). Developers register a client with a JSON metadata document (<code>redirect_uris</code>, <code>client_name</code>, <code>grant_types</code>, <code>scope</code>, <code>token_endpoint_auth_method</code>, <code>userinfo_signed_response_alg</code>, <code>jwks</code>, <code>id_token_encrypted_response_alg</code>, <code>subject_type</code>, <code>sector_identifier_uri</code>, <code>request_uris</code>, <code>post_logout_redirect_uris</code>, <code>backchannel_logout_uri</code>, <code>frontchannel_logout_uri</code>, <code>backchannel_token_delivery_mode</code>, <code>backchannel_client_notification_endpoint</code>, <code>application_type</code>, ...) and receive <code>client_id</code>, <code>client_secret</code> and a <code>registration_access_token</code>. The client is linked to the developer the initial access token was issued to. Developers can only register the <code>authorization_code</code> and <code>refresh_token</code> grants; token exchange, the JWT bearer grant and CIBA are granted by administrators (<code>PUT /api/admin/clients/{client_id}/grant-types</code>) and kept on later updates. Redirect URIs must not contain fragments or wildcards; web clients (the default <code>application_type</code>) must use https, while <code>native</code> clients may also use loopback http URIs and private-use schemes in reverse domain name notation (<code>com.example.app:/callback</code>). Pairwise subjects (<code>subject_type</code> "pairwise") are only offered when the server is configured with <code>PAIRWISE_SUBJECT_SALT</code>. Updates cannot change a client's <code>subject_type</code> or the sector of its pairwise subjects, so subjects in tokens already issued stay valid.</p>
+            <strong>Authorization:</strong> <code>Bearer {initial_access_token}</code>, issued from the admin dashboard and valid for one registration
+        </div>
+
+        <div class="endpoint">
+            <span class="method get">GET</span> <span class="method put">PUT</span> <span class="method delete">DELETE</span>
+            <strong>/register/{client_id}</strong>
+            <span class="badge">OAuth2</span>
+            <p>Client configuration endpoint (RFC 7592). Reads, replaces or deletes the metadata of a dynamically registered client. Updates send the complete metadata together with <code>client_id</code>.</p>
+            <strong>Authorization:</strong> <code>Bearer {registration_access_token}</code>
         </div>
 
         <h3>OpenID Connect Endpoints</h3>
 
         <div class="endpoint">
             <span class="method get">GET</span>
+            <span class="method post">POST</span>
             <strong>/userinfo</strong>
             <span class="badge">OIDC</span>
-            <p>Returns user profile information for authenticated requests.</p>
-            <strong>Authorization:</strong> <code>Bearer {access_token}</code>
+            <p>Returns the user's claims released by the access token's scope: <code>sub</code> always, <code>name</code>, <code>nickname</code>, <code>picture</code> and <code>locale</code> with <code>profile</code>, <code>email</code> and <code>email_verified</code> with <code>email</code>, <code>phone_number</code> and <code>phone_number_verified</code> with <code>phone</code>. The claims a scope unlocks are set in the scope catalogue; ID tokens carry the same claims. Claims requested with the <code>claims</code> authorization parameter are added to the response they were requested for. Profiles are set with <code>PUT /api/admin/users/{user_id}/profile</code>. Clients registered with <code>userinfo_signed_response_alg</code> ("RS256") receive the claims as a signed JWT (<code>application/jwt</code>) with <code>iss</code> and <code>aud</code>.</p>
+            <strong>Authorization:</strong> <code>Bearer {access_token}</code>, <code>DPoP {access_token}</code> with a <code>DPoP</code> proof header for DPoP-bound tokens, or the <code>access_token</code> form parameter of a POST request
         </div>
 
         <div class="endpoint">
@@ -253,6 +348,13 @@ func getAPIDocumentationHTML() string {
             <p>Returns JSON Web Key Set for token verification.</p>
         </div>
 
+        <div class="endpoint">
+            <span class="method get">GET</span>
+            <strong>/.well-known/openid-configuration</strong>
+            <span class="badge">OIDC</span>
+            <p>Returns the OpenID Provider metadata (endpoints, supported response modes, algorithms).</p>
+        </div>
+
         <h3 id="authentication">Authentication Endpoints</h3>
 
         <div class="endpoint">
@@ -268,11 +370,26 @@ func getAPIDocumentationHTML() string {
             <span class="method post">POST</span>
             <strong>/login</strong>
             <span class="badge">Auth</span>
-            <p>Verifies code and completes user authentication.</p>
+            <p>Verifies code and completes user authentication. Starts the user's SSO session (cookie <code>flash_oauth2_session</code>). An optional <code>totp_code</code> from the user's authenticator app raises the session to "urn:flash-oauth2:acr:sms-totp"; wrong codes fail with <code>invalid_totp_code</code>.</p>
             <strong>Body (JSON):</strong>
             <pre>{"phone": "13800138000", "code": "123456"}</pre>
         </div>
 
+        <div class="endpoint">
+            <span class="method post">POST</span>
+            <strong>/consent</strong>
+            <span class="badge">Auth</span>
+            <p>Submits the user's answer on the consent screen shown for <code>offline_access</code> requests, with the authorization parameters and <code>decision</code> "allow" or "deny". Denied requests return <code>access_denied</code> to the client.</p>
+        </div>
+
+        <div class="endpoint">
+            <span class="method get">GET</span>
+            <span class="method post">POST</span>
+            <strong>/logout</strong>
+            <span class="badge">OIDC</span>
+            <p>RP-Initiated Logout: ends the user's SSO session. Refresh tokens issued without <code>offline_access</code> (online refresh tokens) are bound to the session and stop working; offline refresh tokens remain valid. Clients pass <code>id_token_hint</code>, <code>post_logout_redirect_uri</code> (one of the client's registered <code>post_logout_redirect_uris</code>) and <code>state</code>; the user is redirected there after logout. GET requests without an <code>id_token_hint</code> for the logged-in user show a confirmation page. Other clients of the session are notified: a logout token (with the <code>sid</code> also found in their ID tokens) is posted to their <code>backchannel_logout_uri</code> (an https URL on a public host; redirects are not followed), and their <code>frontchannel_logout_uri</code> (https for web clients) is loaded in an iframe with <code>iss</code> and <code>sid</code>.</p>
+        </div>
+
         <h2 id="models">Data Models</h2>
 
         <h3>Token Response</h3>
@@ -288,7 +405,11 @@ func getAPIDocumentationHTML() string {
         <h3>UserInfo Response</h3>
         <pre>{
   "sub": "123",
-  "phone": "13800138000"
+  "name": "张三",
+  "email": "zhangsan@example.com",
+  "email_verified": true,
+  "phone_number": "13800138000",
+  "phone_number_verified": true
 }</pre>
 
         <h3>JWKS Response</h3>
@@ -437,6 +558,16 @@ func (h *Handler) GetSMSService() services.SMSService {
 	return h.smsService
 }
 
+// GetBackchannelLogoutService returns the back-channel logout service instance (for testing)
+func (h *Handler) GetBackchannelLogoutService() *services.BackchannelLogoutService {
+	return h.backchannelLogoutService
+}
+
+// GetCIBAService returns the backchannel authentication service instance (for testing)
+func (h *Handler) GetCIBAService() *services.CIBAService {
+	return h.cibaService
+}
+
 // Helper functions for admin session management
 func getAdminSession(c *gin.Context) map[string]interface{} {
 	session := make(map[string]interface{})
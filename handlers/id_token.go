@@ -0,0 +1,31 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+)
+
+// issueIDToken issues the ID token of an authorization code or refresh token grant. The token
+// carries the user claims released by the granted scope and the claims requested for the ID
+// token, uses the client's ID token lifetime and is encrypted to the client's key when the
+// client registered id_token_encrypted_response_alg. The subject is public or pairwise
+// according to the client's subject type, and "auth_time" and "sid" describe the user's login.
+func (h *Handler) issueIDToken(client *models.OAuthClient, user *models.User, scope string, authn *models.UserAuthentication, claimsRequest *models.ClaimsRequest) (string, error) {
+	subject, err := h.subjectService.Subject(client, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	userClaims, err := h.userClaims(user, subject, scope, services.ClaimNames(claimsRequest.IDToken))
+	if err != nil {
+		return "", err
+	}
+
+	encryption, err := h.clientJWTService.IDTokenEncryption(client)
+	if err != nil {
+		return "", err
+	}
+
+	return h.jwtService.GenerateIDToken(subject, client.ID, h.tokenPolicy(client).IDTokenLifetime, authn, userClaims, encryption)
+}
@@ -0,0 +1,79 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// introspectionJWTContentType is the media type of signed introspection responses (RFC 9701 Section 4).
+const introspectionJWTContentType = "application/token-introspection+jwt"
+
+// authenticateIntrospectionCaller authenticates the caller of the introspection endpoint and
+// returns its identity: the identifier of a registered resource server authenticating with HTTP
+// Basic and its resource secret, or otherwise the ID of an authenticated OAuth2 client.
+func (h *Handler) authenticateIntrospectionCaller(c *gin.Context) (string, error) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		// 资源标识是绝对URI，客户端ID不是
+		identifier, err := url.QueryUnescape(id)
+		if err == nil && services.ValidateResourceIdentifier(identifier) == nil {
+			if secret, err = url.QueryUnescape(secret); err != nil {
+				return "", err
+			}
+			resource, err := h.resourceService.AuthenticateResource(identifier, secret)
+			if err != nil {
+				return "", err
+			}
+			return resource.Identifier, nil
+		}
+	}
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		return "", err
+	}
+
+	return client.ID, nil
+}
+
+// tokenSubjectActive reports whether the parties a token was issued for are still active:
+// the client must still be registered, its linked application must be active, and the user
+// must still exist.
+func (h *Handler) tokenSubjectActive(claims *models.AccessTokenClaims) bool {
+	client, err := h.oauthService.GetClient(claims.ClientID)
+	if err != nil {
+		return false
+	}
+
+	if client.AppID != "" {
+		app, err := h.appService.GetApp(client.AppID)
+		if err != nil || app.Status != "active" {
+			return false
+		}
+	}
+
+	_, err = h.userService.GetUserByID(claims.UserID)
+	return err == nil
+}
+
+// sendIntrospectionResponse writes an introspection result, as a signed JWT addressed to the
+// caller when it was requested with the Accept header (RFC 9701), or as plain JSON otherwise.
+func (h *Handler) sendIntrospectionResponse(c *gin.Context, caller string, response gin.H) {
+	if !strings.Contains(c.GetHeader("Accept"), introspectionJWTContentType) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	signed, err := h.jwtService.GenerateIntrospectionResponse(caller, response)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Data(http.StatusOK, introspectionJWTContentType, []byte(signed))
+}
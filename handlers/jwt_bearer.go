@@ -0,0 +1,89 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GrantTypeJWTBearer is the JWT bearer authorization grant type (RFC 7523 Section 2.1).
+const GrantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// handleJWTBearerGrant issues a user-scoped access token to a trusted backend application
+// that presents a JWT assertion signed with one of its registered key pairs (RFC 7523).
+// The client must be linked to the application and registered for the grant; the asserted
+// subject (the user's subject identifier for the client) and the requested scope are limited by the application's policy.
+func (h *Handler) handleJWTBearerGrant(c *gin.Context, req TokenRequest, cnf *models.TokenConfirmation) {
+	// 验证客户端
+	client, cnf, ok := h.authenticateTokenClient(c, cnf)
+	if !ok {
+		return
+	}
+
+	if !containsString(client.GrantTypes, GrantTypeJWTBearer) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": "client is not allowed to use JWT bearer assertions"})
+		return
+	}
+
+	if req.Assertion == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "assertion is required"})
+		return
+	}
+
+	// 验证JWT断言
+	tokenEndpoint := strings.TrimSuffix(h.config.BaseURL, "/") + "/token"
+	assertion, err := h.jwtBearerService.VerifyAssertion(client, req.Assertion, []string{h.config.Issuer, tokenEndpoint})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": err.Error()})
+		return
+	}
+
+	userID, err := h.subjectService.ResolveSubject(client, assertion.Subject)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "unknown subject"})
+		return
+	}
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "unknown subject"})
+		return
+	}
+
+	scope, err := h.jwtBearerService.AllowedScope(assertion.App, req.Scope)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+		return
+	}
+
+	// 断言授权中用户未交互登录，令牌不含auth_time和acr
+	lifetime := h.tokenPolicy(client).AccessTokenLifetime
+	subject, err := h.subjectService.Subject(client, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	accessToken, err := h.jwtService.GenerateAccessToken(&models.AccessTokenClaims{
+		Subject:  subject,
+		ClientID: client.ID,
+		Scope:    scope,
+
+		Confirmation: cnf,
+	}, nil, lifetime)
+	if err == nil {
+		accessToken, err = h.encodeAccessToken(client, user.ID, scope, accessToken, lifetime)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   accessTokenType(cnf),
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       scope,
+	})
+}
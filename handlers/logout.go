@@ -0,0 +1,230 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Back-channel logout delivery: attempts per logout token and the delay before the first retry.
+const (
+	backchannelLogoutAttempts   = 3
+	backchannelLogoutRetryDelay = time.Second
+)
+
+// LogoutRequest represents an RP-initiated logout request (OpenID Connect RP-Initiated Logout 1.0 Section 2).
+type LogoutRequest struct {
+	IDTokenHint           string `form:"id_token_hint"`            // ID token previously issued to the client, identifying the user
+	ClientID              string `form:"client_id"`                // Client initiating the logout
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri"` // Where the user is sent after logout, must be registered by the client
+	State                 string `form:"state"`                    // Opaque value returned to the client with the redirect
+}
+
+// Logout ends the user's SSO session (OpenID Connect RP-Initiated Logout 1.0). Refresh tokens
+// issued within the session stop working, except those granted offline access (offline_access scope).
+//
+// Clients send the user here with the ID token they received as id_token_hint; after logout the
+// user is redirected to post_logout_redirect_uri, which must be one of the client's registered
+// post_logout_redirect_uris, with the state parameter. Since any site can link to the endpoint,
+// GET requests without an id_token_hint for the logged-in user first ask the user to confirm;
+// POST requests carry the session cookie only from this server's own pages (SameSite=Lax).
+//
+// The other clients the user signed in to during the session are told about the logout: clients
+// with a backchannel_logout_uri receive a logout token, and clients with a frontchannel_logout_uri
+// are loaded in hidden iframes on a page that then continues to post_logout_redirect_uri.
+//
+// Parameters:
+//   - id_token_hint: ID token issued to the client, expired tokens are accepted (recommended)
+//   - client_id: The client initiating the logout, required with post_logout_redirect_uri unless id_token_hint is given
+//   - post_logout_redirect_uri: Where to redirect the user after logout (optional)
+//   - state: Opaque value returned with the redirect (optional)
+//
+// Example:
+//
+//	GET /logout?id_token_hint=eyJhbGciOiJSUzI1NiI...&post_logout_redirect_uri=https://app.com/logged-out&state=xyz
+//	Cookie: flash_oauth2_session=...
+//
+// Response:
+//
+//	HTTP/1.1 302 Found
+//	Location: https://app.com/logged-out?state=xyz
+//
+// Without post_logout_redirect_uri:
+//
+//	{"message": "logout successful"}
+func (h *Handler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	// 校验失败时不重定向，避免开放重定向
+	client, hint, err := h.resolveLogoutRequest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	session := h.currentSession(c)
+	if session != nil && c.Request.Method == http.MethodGet && !h.logoutHintIdentifies(client, hint, session) {
+		c.HTML(http.StatusOK, "logout.gohtml", gin.H{
+			"client_name":              logoutClientName(client),
+			"id_token_hint":            req.IDTokenHint,
+			"client_id":                req.ClientID,
+			"post_logout_redirect_uri": req.PostLogoutRedirectURI,
+			"state":                    req.State,
+		})
+		return
+	}
+
+	var frontchannelLogoutURIs []string
+	if session != nil {
+		clientIDs, err := h.sessionService.SessionClients(session.ID)
+		if err == nil {
+			err = h.sessionService.EndSession(session.ID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+
+		// 在线刷新令牌随会话失效
+		if _, err := h.oauthService.RevokeSessionRefreshTokens(session.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+
+		frontchannelLogoutURIs = h.notifyLogout(session, clientIDs)
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookieName, "", -1, "/", "", strings.HasPrefix(h.config.BaseURL, "https://"), true)
+
+	location := req.PostLogoutRedirectURI
+	if location != "" && req.State != "" {
+		if location, err = buildRedirectURL(req.PostLogoutRedirectURI, url.Values{"state": {req.State}}, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "invalid post_logout_redirect_uri"})
+			return
+		}
+	}
+
+	// 前端通道登出页面加载各客户端的登出地址后再继续重定向
+	if len(frontchannelLogoutURIs) > 0 {
+		c.HTML(http.StatusOK, "logged_out.gohtml", gin.H{
+			"frontchannel_logout_uris": frontchannelLogoutURIs,
+			"redirect_uri":             location,
+		})
+		return
+	}
+
+	if location != "" {
+		c.Redirect(http.StatusFound, location)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logout successful"})
+}
+
+// notifyLogout tells the clients that took part in an ended session about the logout. Logout
+// tokens are sent to the back-channel logout endpoints in the background; the front-channel
+// logout URLs, carrying the issuer and the session's "sid", are returned for the logout page.
+// Clients that can no longer be found are skipped.
+func (h *Handler) notifyLogout(session *models.UserSession, clientIDs []string) []string {
+	sid := services.SessionSID(session.ID)
+
+	var frontchannelLogoutURIs []string
+	for _, clientID := range clientIDs {
+		client, err := h.oauthService.GetClient(clientID)
+		if err != nil {
+			continue
+		}
+
+		if client.BackchannelLogoutURI != "" {
+			subject, err := h.subjectService.Subject(client, session.UserID)
+			if err == nil {
+				var logoutToken string
+				logoutToken, err = h.jwtService.GenerateLogoutToken(subject, client.ID, sid)
+				if err == nil {
+					h.backchannelLogoutService.Notify(client.BackchannelLogoutURI, logoutToken)
+				}
+			}
+		}
+
+		if client.FrontchannelLogoutURI != "" {
+			params := url.Values{"iss": {h.config.Issuer}, "sid": {sid}}
+			if logoutURI, err := buildRedirectURL(client.FrontchannelLogoutURI, params, false); err == nil {
+				frontchannelLogoutURIs = append(frontchannelLogoutURIs, logoutURI)
+			}
+		}
+	}
+
+	return frontchannelLogoutURIs
+}
+
+// resolveLogoutRequest validates the parameters of a logout request and returns the client
+// that initiated it, identified by client_id or by the audience of the id_token_hint, and the
+// claims of the hint. Both are nil when the request does not name them.
+func (h *Handler) resolveLogoutRequest(req *LogoutRequest) (*models.OAuthClient, *models.IDTokenClaims, error) {
+	clientID := req.ClientID
+
+	var hint *models.IDTokenClaims
+	if req.IDTokenHint != "" {
+		var err error
+		hint, err = h.jwtService.ParseIDTokenHint(req.IDTokenHint)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid id_token_hint")
+		}
+		if clientID != "" && clientID != hint.Aud {
+			return nil, nil, fmt.Errorf("client_id does not match the audience of id_token_hint")
+		}
+		clientID = hint.Aud
+	}
+
+	var client *models.OAuthClient
+	if clientID != "" {
+		var err error
+		client, err = h.oauthService.GetClient(clientID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unknown client")
+		}
+	}
+
+	// 登出后重定向地址必须是客户端注册的地址
+	if req.PostLogoutRedirectURI != "" {
+		if client == nil {
+			return nil, nil, fmt.Errorf("post_logout_redirect_uri requires client_id or id_token_hint")
+		}
+		if !containsString(client.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+			return nil, nil, fmt.Errorf("post_logout_redirect_uri is not registered for the client")
+		}
+	}
+
+	return client, hint, nil
+}
+
+// logoutHintIdentifies reports whether the id_token_hint of a logout request identifies the
+// user of the SSO session, showing that the request comes from a client the user signed in to.
+func (h *Handler) logoutHintIdentifies(client *models.OAuthClient, hint *models.IDTokenClaims, session *models.UserSession) bool {
+	if client == nil || hint == nil {
+		return false
+	}
+
+	userID, err := h.subjectService.ResolveSubject(client, hint.Subject)
+	return err == nil && userID == session.UserID
+}
+
+// logoutClientName returns the name of the client shown on the logout confirmation page.
+func logoutClientName(client *models.OAuthClient) string {
+	if client == nil {
+		return ""
+	}
+	return client.Name
+}
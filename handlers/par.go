@@ -0,0 +1,117 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/services"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// PushedAuthorization handles pushed authorization requests (RFC 9126).
+// Clients push the authorization request parameters over an authenticated back channel
+// and receive a short-lived request_uri, which they then send to /authorize instead of
+// the parameters themselves. This keeps long scope lists and state blobs out of browser URLs.
+//
+// The endpoint:
+//  1. Authenticates the client (client_secret_basic or client_secret_post)
+//  2. Validates the authorization request parameters like /authorize would
+//  3. Stores the request in Redis for 90 seconds, extended to 10 minutes once the user is shown
+//     the login or consent screen
+//  4. Returns the request_uri referencing it
+//
+// Example:
+//
+//	POST /par
+//	Authorization: Basic base64(client_id:client_secret)
+//	Content-Type: application/x-www-form-urlencoded
+//	response_type=code&redirect_uri=https://client.com/callback&scope=openid&state=xyz
+//
+// Response:
+//
+//	{
+//	  "request_uri": "urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c",
+//	  "expires_in": 90
+//	}
+func (h *Handler) PushedAuthorization(c *gin.Context) {
+	// 验证客户端
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	// 收集授权请求参数（不保存客户端凭据）
+	params := url.Values{}
+	for name, values := range c.Request.PostForm {
+		if name == "client_secret" {
+			continue
+		}
+		params[name] = values
+	}
+
+	if params.Get("request_uri") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "request_uri must not be pushed"})
+		return
+	}
+	if clientID := params.Get("client_id"); clientID != "" && clientID != client.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client_id does not match the authenticated client"})
+		return
+	}
+
+	// 按授权端点的规则验证请求
+	var req AuthorizeRequest
+	if err := binding.MapFormWithTag(&req, params, "form"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	req.ClientID = client.ID
+
+	// 推送的请求对象同样需要验证
+	if req.Request != "" {
+		if aerr := h.resolveRequestObject(client, &req, params); aerr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": aerr.Code, "error_description": aerr.Description})
+			return
+		}
+	} else if client.RequireSignedRequestObject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client requires signed request objects"})
+		return
+	}
+
+	if _, aerr := validateAuthorizeRequest(client, &req); aerr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": aerr.Code, "error_description": aerr.Description})
+		return
+	}
+	if err := h.resolveAuthorizationDetails(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_authorization_details", "error_description": err.Error()})
+		return
+	}
+
+	requestURI, err := h.parService.Push(client.ID, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"request_uri": requestURI,
+		"expires_in":  int(services.PARLifetime.Seconds()),
+	})
+}
+
+// extendPushedRequest keeps a pushed authorization request valid while the user is on the
+// login or consent screen, whose forms carry only its request_uri.
+func (h *Handler) extendPushedRequest(req *AuthorizeRequest) {
+	if strings.HasPrefix(req.RequestURI, services.PARRequestURIPrefix) {
+		h.parService.Extend(req.RequestURI)
+	}
+}
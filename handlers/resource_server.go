@@ -0,0 +1,112 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessTokenContextKey is the gin context key of the claims of the access token accepted by RequireAccessToken.
+const accessTokenContextKey = "access_token_claims"
+
+// authenticateAccessToken validates the access token of a request to a protected endpoint, sent
+// in the Authorization header with the Bearer or DPoP scheme or, for POST requests, as the
+// access_token form parameter (RFC 6750 Section 2.2). Errors are written to the response.
+//
+// Returns:
+//   - *models.AccessTokenClaims: The validated access token claims
+//   - string: The Authorization scheme the token was sent with
+//   - bool: Whether processing may continue
+func (h *Handler) authenticateAccessToken(c *gin.Context) (*models.AccessTokenClaims, string, bool) {
+	// 从Authorization header获取访问令牌，POST请求也可通过表单参数传递
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" && c.Request.Method == http.MethodPost && c.PostForm("access_token") != "" {
+		authHeader = "Bearer " + c.PostForm("access_token")
+	}
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return nil, "", false
+	}
+
+	// 提取Bearer或DPoP令牌
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "DPoP") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return nil, "", false
+	}
+
+	accessToken := tokenParts[1]
+
+	// 验证访问令牌
+	claims, err := h.resolveAccessToken(accessToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": err.Error()})
+		return nil, "", false
+	}
+
+	// 验证令牌持有者
+	if !h.authorizeTokenHolder(c, tokenParts[0], accessToken, claims) {
+		return nil, "", false
+	}
+
+	return claims, tokenParts[0], true
+}
+
+// RequireAccessToken returns a middleware protecting API routes served next to the
+// authorization server. Requests must present a valid access token carrying the scope, issued
+// after a login of at least the authentication context class. Tokens from a weaker login are
+// refused with the insufficient_user_authentication error and the required acr_values, so the
+// client can send the user through step-up authentication (RFC 9470 Section 3).
+// The accepted token's claims are available to the handlers with AccessTokenClaims.
+//
+// Parameters:
+//   - scope: The scope the token must carry, or empty
+//   - acr: The minimum authentication context class, or empty
+//
+// Returns:
+//   - gin.HandlerFunc: The middleware
+//
+// Example:
+//
+//	r.POST("/api/payments", handler.RequireAccessToken("payments", services.ACRPhoneSMSTOTP), createPayment)
+func (h *Handler) RequireAccessToken(scope, acr string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, scheme, ok := h.authenticateAccessToken(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if scope != "" && !hasScope(claims.Scope, scope) {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`%s error="insufficient_scope", scope="%s"`, scheme, scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "error_description": "the access token does not carry the required scope"})
+			return
+		}
+
+		if !services.ACRSatisfies(claims.ACR, acr) {
+			description := "a stronger authentication is required"
+			c.Header("WWW-Authenticate", fmt.Sprintf(`%s error="insufficient_user_authentication", error_description="%s", acr_values="%s"`, scheme, description, acr))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "insufficient_user_authentication", "error_description": description})
+			return
+		}
+
+		c.Set(accessTokenContextKey, claims)
+		c.Next()
+	}
+}
+
+// AccessTokenClaims returns the claims of the access token accepted by RequireAccessToken,
+// or nil when the route is not protected.
+func AccessTokenClaims(c *gin.Context) *models.AccessTokenClaims {
+	if claims, exists := c.Get(accessTokenContextKey); exists {
+		if accessToken, ok := claims.(*models.AccessTokenClaims); ok {
+			return accessToken
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessTokenGrant describes the access token issued by a token request.
+type accessTokenGrant struct {
+	Token     string // The signed access token
+	Scope     string // The granted scope
+	ExpiresIn int    // Token lifetime in seconds
+}
+
+// issueAccessToken issues the access token of an authorization code or refresh token grant.
+// Tokens use the client's access token lifetime. When a resource is requested at the token
+// endpoint, or exactly one was granted, the token is
+// restricted to that resource (RFC 8707 Section 2.2): its audience is the resource identifier,
+// its scope is limited to the resource's scopes and it uses the resource's lifetime.
+// Clients configured for opaque tokens receive a reference token. The token's subject is public
+// or pairwise according to the client's subject type.
+// Errors are written to the response.
+//
+// Parameters:
+//   - client: The authenticated client
+//   - grant: The grant the token is issued for; its resources are empty when none were requested
+//     at authorization, and claims requested for the UserInfo response are carried by tokens
+//     for the client only
+//   - requested: The resource parameters of the token request
+//   - cnf: The key the token is bound to, or nil for a bearer token
+//   - authorizationDetails: Authorization details of the token (RFC 9396); tokens for a resource
+//     carry those whose locations include it
+func (h *Handler) issueAccessToken(c *gin.Context, client *models.OAuthClient, grant *models.GrantContext, requested []string, cnf *models.TokenConfirmation, authorizationDetails []models.AuthorizationDetail) (*accessTokenGrant, bool) {
+	subject, err := h.subjectService.Subject(client, grant.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return nil, false
+	}
+
+	var identifier string
+	switch {
+	case len(requested) > 1:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target", "error_description": "only one resource may be requested per token"})
+		return nil, false
+	case len(requested) == 1:
+		identifier = requested[0]
+		if len(grant.Resources) > 0 && !containsString(grant.Resources, identifier) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target", "error_description": "resource was not part of the authorization"})
+			return nil, false
+		}
+	case len(grant.Resources) == 1:
+		identifier = grant.Resources[0]
+	}
+
+	// 未指定资源时签发默认访问令牌
+	if identifier == "" {
+		lifetime := h.tokenPolicy(client).AccessTokenLifetime
+		accessToken, err := h.jwtService.GenerateAccessToken(&models.AccessTokenClaims{
+			Subject:  subject,
+			ClientID: client.ID,
+			Scope:    grant.Scope,
+
+			Confirmation: cnf,
+
+			UserInfoClaims: services.ClaimNames(grantClaimsRequest(grant.Claims).UserInfo),
+
+			AuthorizationDetails: authorizationDetails,
+		}, &grant.Authentication, lifetime)
+		if err == nil {
+			accessToken, err = h.encodeAccessToken(client, grant.UserID, grant.Scope, accessToken, lifetime)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return nil, false
+		}
+		return &accessTokenGrant{Token: accessToken, Scope: grant.Scope, ExpiresIn: int(lifetime.Seconds())}, true
+	}
+
+	resource, err := h.resourceService.GetResource(identifier)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target", "error_description": err.Error()})
+		return nil, false
+	}
+
+	// 仅保留资源允许的授权范围
+	var scopes []string
+	for _, s := range strings.Fields(grant.Scope) {
+		if containsString(resource.Scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target", "error_description": "no granted scope applies to the resource"})
+		return nil, false
+	}
+	resourceScope := strings.Join(scopes, " ")
+
+	lifetime := time.Duration(resource.AccessTokenLifetime) * time.Second
+	accessToken, err := h.jwtService.GenerateAccessToken(&models.AccessTokenClaims{
+		Subject:  subject,
+		ClientID: client.ID,
+		Scope:    resourceScope,
+		Aud:      resource.Identifier,
+
+		Confirmation: cnf,
+
+		AuthorizationDetails: services.AuthorizationDetailsForResource(authorizationDetails, resource.Identifier),
+	}, &grant.Authentication, lifetime)
+	if err == nil {
+		accessToken, err = h.encodeAccessToken(client, grant.UserID, resourceScope, accessToken, lifetime)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return nil, false
+	}
+
+	return &accessTokenGrant{Token: accessToken, Scope: resourceScope, ExpiresIn: resource.AccessTokenLifetime}, true
+}
+
+// CreateResource registers or updates a protected API resource (admin endpoint).
+//
+// Example:
+//
+//	POST /api/admin/resources
+//	Content-Type: application/json
+//	{"identifier": "https://orders.example.com", "name": "Orders API", "scopes": ["orders:read"], "access_token_lifetime": 900}
+func (h *Handler) CreateResource(c *gin.Context) {
+	var req struct {
+		Identifier          string   `json:"identifier" binding:"required"` // Absolute URI of the resource
+		Name                string   `json:"name" binding:"required"`       // Human-readable name
+		Scopes              []string `json:"scopes" binding:"required"`     // Scopes tokens may carry
+		AccessTokenLifetime int      `json:"access_token_lifetime"`         // Lifetime in seconds (default 3600)
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	resource := &models.APIResource{
+		Identifier:          req.Identifier,
+		Name:                req.Name,
+		Scopes:              req.Scopes,
+		AccessTokenLifetime: req.AccessTokenLifetime,
+	}
+	if err := h.resourceService.CreateResource(resource); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to register resource", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Resource registered successfully",
+		"resource": resource,
+	})
+}
+
+// ListResources returns all registered API resources (admin endpoint).
+func (h *Handler) ListResources(c *gin.Context) {
+	resources, err := h.resourceService.ListResources()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve resources", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resources": resources,
+	})
+}
+
+// DeleteResource removes an API resource from the registry (admin endpoint).
+// The identifier is passed as a query parameter since it is a URI.
+//
+// Example:
+//
+//	DELETE /api/admin/resources?identifier=https://orders.example.com
+func (h *Handler) DeleteResource(c *gin.Context) {
+	identifier := c.Query("identifier")
+	if identifier == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Resource identifier is required"})
+		return
+	}
+
+	if err := h.resourceService.DeleteResource(identifier); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete resource", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Resource deleted successfully",
+	})
+}
@@ -0,0 +1,55 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionCookieName is the cookie referencing the user's SSO session.
+const sessionCookieName = "flash_oauth2_session"
+
+// currentSession returns the SSO session referenced by the request's session cookie,
+// or nil if the user is not logged in.
+func (h *Handler) currentSession(c *gin.Context) *models.UserSession {
+	sessionID, err := c.Cookie(sessionCookieName)
+	if err != nil || sessionID == "" {
+		return nil
+	}
+
+	session, err := h.sessionService.GetSession(sessionID)
+	if err != nil {
+		return nil
+	}
+
+	return session
+}
+
+// startSession starts an SSO session for a user who just logged in with the given authentication
+// context class and sets the session cookie.
+// The cookie is SameSite=Lax, so cross-site form posts (such as a forged consent) carry no session.
+func (h *Handler) startSession(c *gin.Context, userID int, acr string) (*models.UserSession, error) {
+	session, err := h.sessionService.CreateSession(userID, acr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookieName, session.ID, int(h.config.SSOSessionLifetime.Seconds()), "/", "",
+		strings.HasPrefix(h.config.BaseURL, "https://"), true)
+
+	return session, nil
+}
+
+// sessionAuthentication returns how the user of an SSO session authenticated, as recorded in
+// the grants and tokens issued within the session.
+func sessionAuthentication(session *models.UserSession) models.UserAuthentication {
+	return models.UserAuthentication{
+		Time:      session.AuthTime,
+		ACR:       session.ACR,
+		SessionID: session.ID,
+	}
+}
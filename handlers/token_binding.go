@@ -0,0 +1,69 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeTokenHolder checks that the presenter of an access token may use it.
+// Certificate-bound tokens must be sent over a connection authenticated with the bound
+// client certificate. DPoP-bound tokens must be sent with the DPoP scheme and a proof signed
+// by the bound key; other tokens must be sent with the Bearer scheme. Errors are written to
+// the response.
+//
+// Parameters:
+//   - scheme: The Authorization header scheme (Bearer or DPoP)
+//   - accessToken: The presented access token
+//   - claims: The validated access token claims
+//
+// Returns:
+//   - bool: Whether processing may continue
+func (h *Handler) authorizeTokenHolder(c *gin.Context, scheme, accessToken string, claims *models.AccessTokenClaims) bool {
+	// 验证令牌绑定的客户端证书
+	if claims.Confirmation != nil && claims.Confirmation.X5TS256 != "" {
+		chain, err := h.mtlsService.ClientCertificates(c.Request, c.RemoteIP())
+		if err != nil || len(chain) == 0 || services.CertificateThumbprint(chain[0]) != claims.Confirmation.X5TS256 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "access token is not bound to the presented client certificate"})
+			return false
+		}
+	}
+
+	bound := claims.Confirmation != nil && claims.Confirmation.JKT != ""
+
+	if scheme != "DPoP" {
+		if bound {
+			dpopChallenge(c, "invalid_token", "DPoP-bound access tokens must use the DPoP authorization scheme")
+			return false
+		}
+		return true
+	}
+
+	// 验证DPoP证明与令牌绑定的密钥一致
+	jkt, err := h.verifyDPoPProof(c, accessToken)
+	if err == nil && jkt == "" {
+		err = fmt.Errorf("DPoP proof is required")
+	}
+	if err != nil {
+		dpopChallenge(c, dpopErrorCode(err), err.Error())
+		return false
+	}
+	if !bound || claims.Confirmation.JKT != jkt {
+		dpopChallenge(c, "invalid_token", "access token is not bound to the DPoP proof key")
+		return false
+	}
+
+	return true
+}
+
+// accessTokenType returns the token_type of an issued access token.
+func accessTokenType(cnf *models.TokenConfirmation) string {
+	if cnf != nil && cnf.JKT != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
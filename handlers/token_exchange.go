@@ -0,0 +1,256 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token exchange grant type and token type identifiers (RFC 8693 Section 3).
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// handleTokenExchangeGrant exchanges an access token for a narrower token aimed at another
+// service (RFC 8693). The client must be registered for the token exchange grant, and may only
+// request the audiences and scopes allowed by its exchange policy; the scope can never exceed
+// that of the subject token. With an actor_token the issued token names the actor in its
+// "act" claim (delegation); prior actors of the subject token are nested below it.
+func (h *Handler) handleTokenExchangeGrant(c *gin.Context, req TokenRequest, cnf *models.TokenConfirmation) {
+	// 验证客户端
+	client, cnf, ok := h.authenticateTokenClient(c, cnf)
+	if !ok {
+		return
+	}
+
+	if !containsString(client.GrantTypes, GrantTypeTokenExchange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": "client is not allowed to exchange tokens"})
+		return
+	}
+
+	if req.SubjectToken == "" || req.SubjectTokenType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "subject_token and subject_token_type are required"})
+		return
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "only access tokens can be requested"})
+		return
+	}
+
+	// 验证主体令牌
+	subject, err := h.parseExchangeToken(c, client, cnf, req.SubjectToken, req.SubjectTokenType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "subject_token: " + err.Error()})
+		return
+	}
+
+	// 验证行为方令牌
+	actor := subject.Actor
+	if req.ActorToken != "" {
+		if req.ActorTokenType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "actor_token_type is required with actor_token"})
+			return
+		}
+		actorClaims, err := h.parseExchangeToken(c, client, cnf, req.ActorToken, req.ActorTokenType)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "actor_token: " + err.Error()})
+			return
+		}
+		actor = &models.TokenActor{
+			Subject:  actorClaims.Subject,
+			ClientID: actorClaims.ClientID,
+			Actor:    subject.Actor,
+		}
+	} else if req.ActorTokenType != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "actor_token_type must not be sent without actor_token"})
+		return
+	}
+
+	// 校验目标受众
+	audience, err := exchangeAudience(client, req.Audience)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_target", "error_description": err.Error()})
+		return
+	}
+
+	// 校验授权范围
+	scope, err := exchangeScope(client, subject.Scope, req.Scope)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+		return
+	}
+
+	// 沿用主体令牌的用户认证信息
+	var authn *models.UserAuthentication
+	if subject.AuthTime != 0 {
+		authn = &models.UserAuthentication{Time: time.Unix(subject.AuthTime, 0), ACR: subject.ACR}
+	}
+
+	// 签发给当前客户端的令牌使用该客户端对应的sub
+	lifetime := h.tokenPolicy(client).AccessTokenLifetime
+	sub, err := h.subjectService.Subject(client, subject.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	accessToken, err := h.jwtService.GenerateAccessToken(&models.AccessTokenClaims{
+		Subject:  sub,
+		ClientID: client.ID,
+		Scope:    scope,
+		Aud:      audience,
+
+		Confirmation: cnf,
+
+		Actor: actor,
+	}, authn, lifetime)
+	if err == nil {
+		accessToken, err = h.encodeAccessToken(client, subject.UserID, scope, accessToken, lifetime)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   accessTokenType(cnf),
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       scope,
+
+		IssuedTokenType: TokenTypeAccessToken,
+	})
+}
+
+// parseExchangeToken validates a subject or actor token presented for exchange.
+// Only access tokens issued by this server are accepted, JWTs as well as reference tokens.
+// The token must be addressed to the exchanging client or to one of the audiences of its
+// exchange policy, so clients cannot exchange tokens obtained by others. Sender-constrained
+// tokens additionally require proof of possession: the DPoP proof of the token request must
+// use the bound key, and the client must present the bound certificate.
+func (h *Handler) parseExchangeToken(c *gin.Context, client *models.OAuthClient, cnf *models.TokenConfirmation, token, tokenType string) (*models.AccessTokenClaims, error) {
+	if tokenType != TokenTypeAccessToken && tokenType != TokenTypeJWT {
+		return nil, fmt.Errorf("unsupported token type %q", tokenType)
+	}
+
+	claims, err := h.resolveAccessToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	// 令牌须签发给当前客户端或其交换策略中的受众
+	if claims.Aud != client.ID && !containsString(client.TokenExchangeAudiences, claims.Aud) {
+		return nil, fmt.Errorf("token was not issued to the client")
+	}
+
+	// 验证持有证明
+	if claims.Confirmation != nil && claims.Confirmation.JKT != "" {
+		if cnf == nil || cnf.JKT != claims.Confirmation.JKT {
+			return nil, fmt.Errorf("DPoP proof with the key the token is bound to is required")
+		}
+	}
+	if claims.Confirmation != nil && claims.Confirmation.X5TS256 != "" {
+		chain, err := h.mtlsService.ClientCertificates(c.Request, c.RemoteIP())
+		if err != nil || len(chain) == 0 || services.CertificateThumbprint(chain[0]) != claims.Confirmation.X5TS256 {
+			return nil, fmt.Errorf("token is not bound to the presented client certificate")
+		}
+	}
+
+	return claims, nil
+}
+
+// exchangeAudience returns the audience of an exchanged token. Without an audience parameter
+// the token is issued to the client itself; otherwise the audience must be in the client's policy.
+func exchangeAudience(client *models.OAuthClient, requested []string) (string, error) {
+	switch len(requested) {
+	case 0:
+		return client.ID, nil
+	case 1:
+	default:
+		return "", fmt.Errorf("only one audience may be requested")
+	}
+
+	if requested[0] != client.ID && !containsString(client.TokenExchangeAudiences, requested[0]) {
+		return "", fmt.Errorf("client is not allowed to request tokens for audience %q", requested[0])
+	}
+
+	return requested[0], nil
+}
+
+// exchangeScope returns the scope of an exchanged token. The requested scope defaults to that
+// of the subject token, must be contained in it, and must be allowed by the client's policy.
+// Clients without exchangeable scopes in their policy cannot exchange tokens.
+func exchangeScope(client *models.OAuthClient, subjectScope, requested string) (string, error) {
+	if len(client.TokenExchangeScopes) == 0 {
+		return "", fmt.Errorf("client has no exchangeable scopes")
+	}
+
+	granted := strings.Fields(subjectScope)
+	permitted := func(scope string) bool {
+		return containsString(client.TokenExchangeScopes, scope)
+	}
+
+	var scopes []string
+	if requested == "" {
+		// 未指定时沿用主体令牌中策略允许的范围
+		for _, scope := range granted {
+			if permitted(scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	} else {
+		for _, scope := range strings.Fields(requested) {
+			if !containsString(granted, scope) {
+				return "", fmt.Errorf("scope %q exceeds the subject token", scope)
+			}
+			if !permitted(scope) {
+				return "", fmt.Errorf("client is not allowed to request scope %q", scope)
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("no exchangeable scope")
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+// UpdateTokenExchangePolicy sets the token exchange policy of a client (admin endpoint).
+//
+// Example:
+//
+//	PUT /api/admin/clients/api-gateway/token-exchange-policy
+//	Content-Type: application/json
+//	{"audiences": ["https://orders.example.com"], "scopes": ["orders:read"]}
+func (h *Handler) UpdateTokenExchangePolicy(c *gin.Context) {
+	var req struct {
+		Audiences []string `json:"audiences"` // Audiences the client may request
+		Scopes    []string `json:"scopes"`    // Scopes exchanged tokens may carry
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	clientID := c.Param("client_id")
+	if err := h.oauthService.UpdateTokenExchangePolicy(clientID, req.Audiences, req.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update token exchange policy", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Token exchange policy updated successfully",
+		"client_id": clientID,
+		"audiences": req.Audiences,
+		"scopes":    req.Scopes,
+	})
+}
@@ -0,0 +1,83 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenPolicy returns the token lifetimes and refresh token rules in effect for a client.
+func (h *Handler) tokenPolicy(client *models.OAuthClient) services.TokenPolicy {
+	return services.DefaultTokenPolicy(h.config).ForClient(client)
+}
+
+// GetTokenPolicy returns the token policy of a client (admin endpoint): the client's overrides
+// and the policy in effect after applying them to the server defaults, durations in seconds.
+//
+// Example:
+//
+//	GET /api/admin/clients/partner-app/token-policy
+func (h *Handler) GetTokenPolicy(c *gin.Context) {
+	client, err := h.oauthService.GetClient(c.Param("client_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client_id": client.ID,
+		"overrides": client.TokenPolicy,
+		"effective": effectiveTokenPolicy(h.tokenPolicy(client)),
+	})
+}
+
+// UpdateTokenPolicy sets the token lifetimes and refresh token rules of a client (admin endpoint).
+// Durations are in seconds; omitted settings fall back to the server defaults.
+//
+// Example:
+//
+//	PUT /api/admin/clients/partner-app/token-policy
+//	Content-Type: application/json
+//	{"access_token_lifetime": 900, "refresh_token_idle_timeout": 86400, "issue_refresh_tokens": true}
+func (h *Handler) UpdateTokenPolicy(c *gin.Context) {
+	var policy models.ClientTokenPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	clientID := c.Param("client_id")
+	if err := h.oauthService.UpdateTokenPolicy(clientID, policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update token policy", "details": err.Error()})
+		return
+	}
+
+	client, err := h.oauthService.GetClient(clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve client", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Token policy updated successfully",
+		"client_id": clientID,
+		"overrides": client.TokenPolicy,
+		"effective": effectiveTokenPolicy(h.tokenPolicy(client)),
+	})
+}
+
+// effectiveTokenPolicy renders a token policy for the admin API, durations in seconds.
+func effectiveTokenPolicy(policy services.TokenPolicy) gin.H {
+	return gin.H{
+		"access_token_lifetime":      int(policy.AccessTokenLifetime.Seconds()),
+		"id_token_lifetime":          int(policy.IDTokenLifetime.Seconds()),
+		"refresh_token_lifetime":     int(policy.RefreshTokenLifetime.Seconds()),
+		"auth_code_lifetime":         int(policy.AuthCodeLifetime.Seconds()),
+		"refresh_token_idle_timeout": int(policy.RefreshTokenIdleTimeout.Seconds()),
+		"session_lifetime":           int(policy.SessionLifetime.Seconds()),
+		"issue_refresh_tokens":       policy.IssueRefreshTokens,
+	}
+}
@@ -0,0 +1,58 @@
+// Package handlers provides HTTP request handlers for OAuth2 and OpenID Connect endpoints.
+package handlers
+
+import (
+	"flash-oauth2/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateUserProfile sets the profile attributes of a user (admin endpoint). The attributes are
+// released as OpenID Connect claims from /userinfo and in ID tokens according to the granted scopes.
+// The phone number is verified by SMS login and cannot be changed here.
+//
+// Example:
+//
+//	PUT /api/admin/users/123/profile
+//	Content-Type: application/json
+//	{"name": "张三", "nickname": "小张", "locale": "zh-CN", "email": "zhangsan@example.com", "email_verified": true}
+func (h *Handler) UpdateUserProfile(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name"`           // Full name
+		Nickname      string `json:"nickname"`       // Casual name
+		Picture       string `json:"picture"`        // Profile picture URL
+		Locale        string `json:"locale"`         // Preferred locale (BCP47)
+		Email         string `json:"email"`          // Email address
+		EmailVerified bool   `json:"email_verified"` // Whether the email address was verified
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(userID, &models.User{
+		Name:          req.Name,
+		Nickname:      req.Nickname,
+		Picture:       req.Picture,
+		Locale:        req.Locale,
+		Email:         req.Email,
+		EmailVerified: req.EmailVerified,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to update user profile", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User profile updated successfully",
+		"user":    user,
+	})
+}
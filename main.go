@@ -20,16 +20,20 @@
 //   - PORT: Server port (default: 8080)
 //   - DATABASE_URL: PostgreSQL connection string
 //   - REDIS_URL: Redis connection string
+//   - TLS_CERT_FILE, TLS_KEY_FILE: Serve HTTPS and accept TLS client certificates
 package main
 
 import (
+	"crypto/tls"
 	"flash-oauth2/config"
 	"flash-oauth2/database"
 	"flash-oauth2/handlers"
 	"flash-oauth2/middleware"
 	"flash-oauth2/redis_client"
 	"flash-oauth2/routes"
+	"flash-oauth2/services"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -53,6 +57,11 @@ func main() {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// 未配置盐值时不能存在使用成对标识符的客户端
+	if err := services.NewSubjectService(db, cfg.PairwiseSubjectSalt).CheckSalt(); err != nil {
+		log.Fatal("PAIRWISE_SUBJECT_SALT is not set:", err)
+	}
+
 	// 初始化Redis
 	redisClient := redis_client.Init(cfg.RedisURL)
 
@@ -74,6 +83,20 @@ func main() {
 	// 启动服务器
 	log.Printf("Server starting on port %s", cfg.Port)
 	log.Printf("Management Dashboard: http://localhost:%s/admin/dashboard", cfg.Port)
+
+	// 启用TLS时请求客户端证书，证书由客户端认证逻辑按注册的方式验证
+	if cfg.TLSCertFile != "" {
+		server := &http.Server{
+			Addr:      ":" + cfg.Port,
+			Handler:   r,
+			TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+		}
+		if err := server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+		return
+	}
+
 	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
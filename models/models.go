@@ -16,6 +16,18 @@ type User struct {
 	Role      string    `json:"role" db:"role"`             // User role (user/admin)
 	CreatedAt time.Time `json:"created_at" db:"created_at"` // Account creation time
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // Last update time
+
+	// Profile attributes released as OpenID Connect claims (OpenID Connect Core 1.0 Section 5.1)
+	Name                string `json:"name,omitempty" db:"name"`                         // Full name
+	Nickname            string `json:"nickname,omitempty" db:"nickname"`                 // Casual name
+	Picture             string `json:"picture,omitempty" db:"picture"`                   // Profile picture URL
+	Locale              string `json:"locale,omitempty" db:"locale"`                     // Preferred locale (BCP47, e.g. zh-CN)
+	Email               string `json:"email,omitempty" db:"email"`                       // Email address
+	EmailVerified       bool   `json:"email_verified" db:"email_verified"`               // Whether the email address was verified
+	PhoneNumberVerified bool   `json:"phone_number_verified" db:"phone_number_verified"` // Whether the phone number was verified by SMS
+
+	// Whether the user set up an authenticator app for step-up authentication (see TOTPService)
+	TOTPEnabled bool `json:"totp_enabled" db:"totp_secret"`
 }
 
 // OAuthClient represents an OAuth2 client application.
@@ -29,65 +41,300 @@ type OAuthClient struct {
 	ResponseTypes []string  `json:"response_types" db:"response_types"` // Supported response types
 	Scope         string    `json:"scope" db:"scope"`                   // Default scopes
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`         // Client registration time
+
+	// Authorization request policy
+	RequirePAR                 bool `json:"require_pushed_authorization_requests" db:"require_pushed_authorization_requests"` // Authorization requests must be pushed first (RFC 9126)
+	RequireSignedRequestObject bool `json:"require_signed_request_object" db:"require_signed_request_object"`                 // Authorization requests must be signed request objects (RFC 9101)
+
+	// https URLs of request objects the client may pass by reference (RFC 9101 Section 5.2); no others are fetched
+	RequestURIs []string `json:"request_uris,omitempty" db:"request_uris"`
+
+	// Client keys
+	AppID   string `json:"app_id,omitempty" db:"app_id"`     // Linked external application whose key pairs the client signs with
+	JWKSURI string `json:"jwks_uri,omitempty" db:"jwks_uri"` // URL of the client's published JSON Web Key Set
+	JWKS    string `json:"jwks,omitempty" db:"jwks"`         // JSON Web Key Set registered by value, as JSON
+
+	// Client authentication (RFC 8705 for the tls_client_auth methods)
+	TokenEndpointAuthMethod               string `json:"token_endpoint_auth_method" db:"token_endpoint_auth_method"`                                 // client_secret_basic, client_secret_post, tls_client_auth or self_signed_tls_client_auth
+	TLSClientAuthSubjectDN                string `json:"tls_client_auth_subject_dn,omitempty" db:"tls_client_auth_subject_dn"`                       // Expected certificate subject DN (tls_client_auth)
+	TLSClientAuthSANDNS                   string `json:"tls_client_auth_san_dns,omitempty" db:"tls_client_auth_san_dns"`                             // Expected dNSName SAN (tls_client_auth)
+	TLSClientAuthSANURI                   string `json:"tls_client_auth_san_uri,omitempty" db:"tls_client_auth_san_uri"`                             // Expected uniformResourceIdentifier SAN (tls_client_auth)
+	TLSClientAuthSANIP                    string `json:"tls_client_auth_san_ip,omitempty" db:"tls_client_auth_san_ip"`                               // Expected iPAddress SAN (tls_client_auth)
+	TLSClientAuthSANEmail                 string `json:"tls_client_auth_san_email,omitempty" db:"tls_client_auth_san_email"`                         // Expected rfc822Name SAN (tls_client_auth)
+	TLSClientCertificateBoundAccessTokens bool   `json:"tls_client_certificate_bound_access_tokens" db:"tls_client_certificate_bound_access_tokens"` // Bind access tokens to the client certificate
+
+	// Dynamic registration (RFC 7591)
+	DeveloperID string   `json:"developer_id,omitempty" db:"developer_id"` // Developer that registered the client
+	ClientURI   string   `json:"client_uri,omitempty" db:"client_uri"`     // Client home page
+	LogoURI     string   `json:"logo_uri,omitempty" db:"logo_uri"`         // Client logo shown to users
+	Contacts    []string `json:"contacts,omitempty" db:"contacts"`         // Contact email addresses
+
+	// Token exchange policy (RFC 8693)
+	TokenExchangeAudiences []string `json:"token_exchange_audiences,omitempty" db:"token_exchange_audiences"` // Audiences the client may request exchanged tokens for
+	TokenExchangeScopes    []string `json:"token_exchange_scopes,omitempty" db:"token_exchange_scopes"`       // Scopes exchanged tokens may carry (empty: no exchange)
+
+	// Format of issued access tokens: "jwt" (default) or "opaque" reference tokens
+	AccessTokenFormat string `json:"access_token_format" db:"access_token_format"`
+
+	// Token lifetimes and refresh token rules overriding the server defaults
+	TokenPolicy ClientTokenPolicy `json:"token_policy"`
+
+	// JWS algorithm of signed UserInfo responses, empty for plain JSON (OpenID Connect Dynamic Client Registration 1.0 Section 2)
+	UserInfoSignedResponseAlg string `json:"userinfo_signed_response_alg,omitempty" db:"userinfo_signed_response_alg"`
+
+	// ID token encryption to the client's key, empty for signed-only ID tokens (OpenID Connect Dynamic Client Registration 1.0 Section 2)
+	IDTokenEncryptedResponseAlg string `json:"id_token_encrypted_response_alg,omitempty" db:"id_token_encrypted_response_alg"` // Key management algorithm (RSA-OAEP-256, ECDH-ES)
+	IDTokenEncryptedResponseEnc string `json:"id_token_encrypted_response_enc,omitempty" db:"id_token_encrypted_response_enc"` // Content encryption algorithm (A256GCM)
+
+	// Subject identifiers (OpenID Connect Core 1.0 Section 8)
+	SubjectType         string `json:"subject_type" db:"subject_type"`                             // "public" (user ID) or "pairwise" (per-sector identifier)
+	SectorIdentifierURI string `json:"sector_identifier_uri,omitempty" db:"sector_identifier_uri"` // URL listing the redirect URIs of the client's sector
+
+	// Logout (OpenID Connect RP-Initiated, Back-Channel and Front-Channel Logout 1.0)
+	PostLogoutRedirectURIs            []string `json:"post_logout_redirect_uris,omitempty" db:"post_logout_redirect_uris"`             // Allowed redirect URIs after logout
+	BackchannelLogoutURI              string   `json:"backchannel_logout_uri,omitempty" db:"backchannel_logout_uri"`                   // Receives logout tokens (OpenID Connect Back-Channel Logout 1.0)
+	BackchannelLogoutSessionRequired  bool     `json:"backchannel_logout_session_required" db:"backchannel_logout_session_required"`   // Logout tokens must carry "sid"
+	FrontchannelLogoutURI             string   `json:"frontchannel_logout_uri,omitempty" db:"frontchannel_logout_uri"`                 // Loaded in an iframe on logout (OpenID Connect Front-Channel Logout 1.0)
+	FrontchannelLogoutSessionRequired bool     `json:"frontchannel_logout_session_required" db:"frontchannel_logout_session_required"` // The iframe URL must carry "iss" and "sid"
+
+	// Client-Initiated Backchannel Authentication (OpenID Connect CIBA Core 1.0)
+	BackchannelTokenDeliveryMode          string `json:"backchannel_token_delivery_mode,omitempty" db:"backchannel_token_delivery_mode"`                   // "poll" or "ping"
+	BackchannelClientNotificationEndpoint string `json:"backchannel_client_notification_endpoint,omitempty" db:"backchannel_client_notification_endpoint"` // Pinged when the user answered (ping mode)
+
+	// Weakest authentication context class users must have logged in with to authorize the client (see ACRValues)
+	MinimumACR string `json:"minimum_acr,omitempty" db:"minimum_acr"`
+
+	// "web" (default) or "native"; native clients may use loopback and private-use scheme redirect URIs (RFC 8252)
+	ApplicationType string `json:"application_type" db:"application_type"`
+}
+
+// ClientTokenPolicy overrides the server's default token lifetimes and refresh token rules
+// for a client. Durations are in seconds; nil fields use the server defaults.
+type ClientTokenPolicy struct {
+	AccessTokenLifetime     *int  `json:"access_token_lifetime,omitempty" db:"access_token_lifetime"`           // Access token lifetime
+	IDTokenLifetime         *int  `json:"id_token_lifetime,omitempty" db:"id_token_lifetime"`                   // ID token lifetime
+	RefreshTokenLifetime    *int  `json:"refresh_token_lifetime,omitempty" db:"refresh_token_lifetime"`         // Refresh token lifetime
+	AuthCodeLifetime        *int  `json:"auth_code_lifetime,omitempty" db:"auth_code_lifetime"`                 // Authorization code lifetime
+	RefreshTokenIdleTimeout *int  `json:"refresh_token_idle_timeout,omitempty" db:"refresh_token_idle_timeout"` // Refresh tokens unused for this long expire (0 disables)
+	SessionLifetime         *int  `json:"session_lifetime,omitempty" db:"session_lifetime"`                     // Refresh tokens cannot be used this long after login (0 disables)
+	IssueRefreshTokens      *bool `json:"issue_refresh_tokens,omitempty" db:"issue_refresh_tokens"`             // Whether refresh tokens are issued
+}
+
+// GrantContext describes what a user granted a client: the state an authorization code hands
+// over to the tokens issued for it, and that a refresh token keeps for the tokens it renews.
+type GrantContext struct {
+	ClientID string `json:"client_id" db:"client_id"` // Client the grant was made to
+	UserID   int    `json:"user_id" db:"user_id"`     // User who made the grant
+	Scope    string `json:"scope" db:"scope"`         // Granted scopes
+
+	// Resource indicators the authorization was requested for (RFC 8707)
+	Resources []string `json:"resources,omitempty" db:"resources"`
+
+	// Claims requested with the claims parameter, as JSON (OpenID Connect Core 1.0 Section 5.5)
+	Claims string `json:"claims,omitempty" db:"claims"`
+
+	// Authorization details the user approved, as JSON (RFC 9396)
+	AuthorizationDetails string `json:"authorization_details,omitempty" db:"authorization_details"`
+
+	// How the user authenticated when approving the request
+	Authentication UserAuthentication `json:"authentication"`
 }
 
 // AuthCode represents an OAuth2 authorization code.
 // Authorization codes are short-lived tokens that can be exchanged for access tokens.
 type AuthCode struct {
+	GrantContext
+
 	Code        string    `json:"code" db:"code"`                 // The authorization code
-	ClientID    string    `json:"client_id" db:"client_id"`       // Client that requested the code
-	UserID      int       `json:"user_id" db:"user_id"`           // User who authorized the client
 	RedirectURI string    `json:"redirect_uri" db:"redirect_uri"` // URI to redirect after authorization
-	Scope       string    `json:"scope" db:"scope"`               // Requested scopes
 	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`     // Code expiration time
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`     // Code creation time
 }
 
-// AccessToken represents an OAuth2 access token stored in the database.
-// Note: In this implementation, JWT tokens are self-contained and don't need database storage,
-// but this table can be used for audit purposes and token tracking.
+// AccessToken represents an opaque reference access token stored in the database.
+// JWT access tokens are self-contained and not stored; clients configured for reference tokens
+// receive a random handle instead, which only the authorization server can resolve.
 type AccessToken struct {
-	Token     string    `json:"token" db:"token"`           // The access token
+	Token     string    `json:"token" db:"token"`           // The access token (only its hash is stored)
 	ClientID  string    `json:"client_id" db:"client_id"`   // Client that owns the token
 	UserID    int       `json:"user_id" db:"user_id"`       // User the token represents
 	Scope     string    `json:"scope" db:"scope"`           // Token scopes
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // Token expiration time
 	CreatedAt time.Time `json:"created_at" db:"created_at"` // Token creation time
+
+	// Signed JWT access token the reference token stands for, kept on the server
+	JWT string `json:"-" db:"jwt"`
 }
 
 // RefreshToken represents an OAuth2 refresh token.
-// Refresh tokens are long-lived tokens used to obtain new access tokens.
+// Refresh tokens are long-lived tokens used to obtain new access tokens; they keep the grant
+// they were issued for.
 type RefreshToken struct {
+	GrantContext
+
 	Token     string    `json:"token" db:"token"`           // The refresh token
-	ClientID  string    `json:"client_id" db:"client_id"`   // Client that owns the token
-	UserID    int       `json:"user_id" db:"user_id"`       // User the token represents
-	Scope     string    `json:"scope" db:"scope"`           // Token scopes
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // Token expiration time
 	CreatedAt time.Time `json:"created_at" db:"created_at"` // Token creation time
+
+	// Last time the token was used, for the idle timeout
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+
+	// Whether the token outlives the user's SSO session (granted offline_access)
+	Offline bool `json:"offline" db:"offline"`
 }
 
+// UserAuthentication records when and how a user authenticated for a grant.
+// Tokens derived from the grant report it in their "auth_time" and "acr" claims.
+type UserAuthentication struct {
+	Time      time.Time `json:"auth_time" db:"auth_time"`      // Time of the user's authentication
+	ACR       string    `json:"acr" db:"acr"`                  // Authentication context class reference
+	SessionID string    `json:"sid,omitempty" db:"session_id"` // SSO session the user authenticated in
+}
+
+// BackchannelAuthenticationRequest represents a Client-Initiated Backchannel Authentication
+// request (OpenID Connect CIBA Core 1.0). The client asks for a user identified by a hint; the
+// user approves or denies it on their own device, and the client collects the tokens with the
+// auth_req_id at the token endpoint.
+type BackchannelAuthenticationRequest struct {
+	AuthReqID               string    `json:"auth_req_id"`                         // Identifier of the request, presented at the token endpoint
+	ClientID                string    `json:"client_id"`                           // Client that made the request
+	UserID                  int       `json:"user_id"`                             // User asked to authenticate
+	Scope                   string    `json:"scope"`                               // Granted scope (space-separated)
+	BindingMessage          string    `json:"binding_message,omitempty"`           // Message shown on both the client's and the user's device
+	ClientNotificationToken string    `json:"client_notification_token,omitempty"` // Bearer token of the ping callback
+	Status                  string    `json:"status"`                              // "pending", "approved" or "denied"
+	ExpiresAt               time.Time `json:"expires_at"`                          // Expiration time
+	Interval                int       `json:"interval"`                            // Minimum seconds between token requests
+	LastPolledAt            time.Time `json:"last_polled_at"`                      // Time of the last token request
+
+	// How the user authenticated, once approved
+	Authentication UserAuthentication `json:"authentication"`
+}
+
+// UserSession represents a single sign-on session of a user at the authorization server.
+// It is created when the user logs in and referenced by a browser cookie, so that later
+// authorization requests do not ask the user to log in again until the session ends.
+type UserSession struct {
+	ID        string    `json:"id"`         // Session identifier, kept in the session cookie
+	UserID    int       `json:"user_id"`    // User who logged in
+	AuthTime  time.Time `json:"auth_time"`  // Time of the login
+	ACR       string    `json:"acr"`        // How the user logged in
+	ExpiresAt time.Time `json:"expires_at"` // Session expiration time
+}
+
+// APIResource represents a protected API registered with the authorization server (RFC 8707).
+// Access tokens requested for a resource carry its identifier as audience, are limited to its
+// scopes and use its token lifetime.
+type APIResource struct {
+	Identifier          string    `json:"identifier" db:"identifier"`                       // Absolute URI identifying the resource (token audience)
+	Name                string    `json:"name" db:"name"`                                   // Human-readable resource name
+	Scopes              []string  `json:"scopes" db:"scopes"`                               // Scopes tokens for the resource may carry
+	AccessTokenLifetime int       `json:"access_token_lifetime" db:"access_token_lifetime"` // Access token lifetime in seconds
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`                       // Registration time
+
+	// Secret the resource server authenticates with at the introspection endpoint,
+	// only returned when the resource is registered (stored hashed)
+	Secret string `json:"secret,omitempty" db:"-"`
+}
+
+// Scope represents an entry of the scope catalogue. Clients can only request registered scopes,
+// limited to those allowed for the client and its linked application.
+type Scope struct {
+	Name        string    `json:"name" db:"name"`                         // Scope value
+	Description string    `json:"description" db:"description"`           // Explanation shown to users on the consent screen
+	Sensitive   bool      `json:"sensitive" db:"sensitive"`               // Whether granting the scope requires the user's consent
+	Claims      []string  `json:"claims" db:"claims"`                     // User claims the scope unlocks
+	MinimumACR  string    `json:"minimum_acr,omitempty" db:"minimum_acr"` // Weakest authentication context class that may grant the scope
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`             // Registration time
+}
+
+// AuthorizationDetailsType represents an entry of the registry of authorization details types
+// (RFC 9396 Section 2). Clients can only request authorization details of registered types.
+type AuthorizationDetailsType struct {
+	Type        string    `json:"type" db:"type"`               // Value of the "type" member
+	Description string    `json:"description" db:"description"` // Explanation shown to users on the consent screen
+	Fields      []string  `json:"fields" db:"fields"`           // Type-specific members allowed besides the common ones, any when empty
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`   // Registration time
+}
+
+// AuthorizationDetail is one element of the authorization_details parameter (RFC 9396 Section 2):
+// a JSON object whose "type" member determines the other members.
+type AuthorizationDetail map[string]any
+
 // AccessTokenClaims represents the claims contained in a JWT access token.
 // These claims follow OAuth2 and JWT standards.
 type AccessTokenClaims struct {
-	UserID   int    `json:"sub"`       // Subject (user ID)
+	Subject  string `json:"sub"`       // Subject identifier (user ID or pairwise subject)
+	UserID   int    `json:"-"`         // User the subject identifies, resolved by the server
 	ClientID string `json:"client_id"` // Client identifier
 	Scope    string `json:"scope"`     // Token scopes
 	Exp      int64  `json:"exp"`       // Expiration time (Unix timestamp)
 	Iat      int64  `json:"iat"`       // Issued at time (Unix timestamp)
 	Iss      string `json:"iss"`       // Issuer
 	Aud      string `json:"aud"`       // Audience (client ID)
+
+	// Sender constraint, nil for bearer tokens
+	Confirmation *TokenConfirmation `json:"cnf,omitempty"`
+
+	// Current actor of a delegated token issued by token exchange (RFC 8693 Section 4.1)
+	Actor *TokenActor `json:"act,omitempty"`
+
+	// Claims the client requested for the UserInfo response with the claims parameter
+	UserInfoClaims []string `json:"userinfo_claims,omitempty"`
+
+	// Authorization details the token was granted for (RFC 9396 Section 9)
+	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty"`
+
+	// JWT access token profile claims (RFC 9068 Section 2.2)
+	JTI      string `json:"jti"`                 // Unique token identifier
+	AuthTime int64  `json:"auth_time,omitempty"` // Time the user authenticated, absent without interactive login
+	ACR      string `json:"acr,omitempty"`       // Authentication context class reference
+}
+
+// ClaimsRequest is the value of the claims authorization parameter (OpenID Connect Core 1.0
+// Section 5.5): individual claims requested for the UserInfo response and the ID token.
+// A nil ClaimRequest requests the claim in the default manner.
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"` // Claims returned from the UserInfo endpoint
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"` // Claims returned in the ID token
+}
+
+// ClaimRequest qualifies the request for an individual claim (OpenID Connect Core 1.0 Section 5.5.1).
+type ClaimRequest struct {
+	Essential bool  `json:"essential,omitempty"` // Whether the claim is essential rather than voluntary
+	Value     any   `json:"value,omitempty"`     // Requested value of the claim
+	Values    []any `json:"values,omitempty"`    // Requested values of the claim, in order of preference
+}
+
+// TokenActor identifies the party acting on behalf of a token's subject ("act" claim).
+// Earlier actors in a delegation chain are nested in Actor.
+type TokenActor struct {
+	Subject  string      `json:"sub"`                 // Subject of the actor token
+	ClientID string      `json:"client_id,omitempty"` // Client the actor token was issued to
+	Actor    *TokenActor `json:"act,omitempty"`       // Prior actor
+}
+
+// TokenConfirmation holds the confirmation ("cnf") claim of a sender-constrained token.
+// Only the holder of the referenced key can use the token.
+type TokenConfirmation struct {
+	JKT     string `json:"jkt,omitempty"`      // JWK SHA-256 thumbprint of the DPoP key (RFC 9449)
+	X5TS256 string `json:"x5t#S256,omitempty"` // SHA-256 thumbprint of the client certificate (RFC 8705)
 }
 
 // IDTokenClaims represents the claims contained in an OpenID Connect ID token.
 // These claims follow OpenID Connect specifications.
+// The user claims released by the granted scopes are added alongside these claims.
 type IDTokenClaims struct {
-	UserID   int    `json:"sub"`       // Subject (user ID)
-	Phone    string `json:"phone"`     // User's phone number
-	Exp      int64  `json:"exp"`       // Expiration time (Unix timestamp)
-	Iat      int64  `json:"iat"`       // Issued at time (Unix timestamp)
-	Iss      string `json:"iss"`       // Issuer
-	Aud      string `json:"aud"`       // Audience (client ID)
-	AuthTime int64  `json:"auth_time"` // Authentication time (Unix timestamp)
+	Subject  string `json:"sub"`           // Subject identifier (user ID or pairwise subject)
+	Exp      int64  `json:"exp"`           // Expiration time (Unix timestamp)
+	Iat      int64  `json:"iat"`           // Issued at time (Unix timestamp)
+	Iss      string `json:"iss"`           // Issuer
+	Aud      string `json:"aud"`           // Audience (client ID)
+	AuthTime int64  `json:"auth_time"`     // Authentication time (Unix timestamp)
+	ACR      string `json:"acr,omitempty"` // Authentication context class reference
+
+	// Session the user authenticated in (OpenID Connect Front-Channel Logout 1.0 Section 3)
+	SessionID string `json:"sid,omitempty"`
 }
 
 // ExternalApp represents an external application registered on the platform
@@ -102,6 +349,9 @@ type ExternalApp struct {
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`     // App registration time
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`     // Last update time
 	RevokedAt   *time.Time `json:"revoked_at" db:"revoked_at"`     // Revocation time (if revoked)
+
+	// Users the app may assert as subject in JWT bearer grants (RFC 7523), "*" for any user
+	AssertionSubjects []string `json:"assertion_subjects,omitempty" db:"assertion_subjects"`
 }
 
 // AppKeyPair represents an RSA key pair issued to an external application
@@ -21,16 +21,33 @@ func Setup(r *gin.Engine, handler *handlers.Handler) {
 
 	// OAuth2端点
 	r.GET("/authorize", handler.Authorize)
+	r.POST("/par", handler.PushedAuthorization)
+	r.POST("/bc-authorize", handler.BackchannelAuthentication)
 	r.POST("/token", handler.Token)
 	r.POST("/introspect", handler.Introspect)
+	r.POST("/revoke", handler.Revoke)
+
+	// 动态客户端注册端点
+	r.POST("/register", handler.RegisterClient)
+	r.GET("/register/:client_id", handler.GetRegisteredClient)
+	r.PUT("/register/:client_id", handler.UpdateRegisteredClient)
+	r.DELETE("/register/:client_id", handler.DeleteRegisteredClient)
 
 	// OpenID Connect端点
 	r.GET("/userinfo", handler.UserInfo)
+	r.POST("/userinfo", handler.UserInfo)
 	r.GET("/.well-known/jwks.json", handler.JWKs)
+	r.GET("/.well-known/openid-configuration", handler.OpenIDConfiguration)
+	r.GET("/.well-known/oauth-authorization-server", handler.OpenIDConfiguration)
 
 	// 用户认证端点
 	r.POST("/login", handler.Login)
+	r.POST("/consent", handler.Consent)
+	r.GET("/logout", handler.Logout)
+	r.POST("/logout", handler.Logout)
 	r.POST("/send-code", handler.SendVerificationCode)
+	r.GET("/bc-authorize/approve", handler.ShowBackchannelApproval)
+	r.POST("/bc-authorize/approve", handler.BackchannelApproval)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -84,6 +101,7 @@ func SetupAppManagement(r *gin.Engine, db *sql.DB, redis *redis.Client, cfg *con
 			{{define "dashboard.gohtml"}}<!DOCTYPE html><html><head><title>Dashboard</title></head><body><h1>Dashboard</h1></body></html>{{end}}
 			{{define "app_details.gohtml"}}<!DOCTYPE html><html><head><title>App Details</title></head><body><h1>App Details</h1></body></html>{{end}}
 			{{define "login.gohtml"}}<!DOCTYPE html><html><head><title>Login</title></head><body><h1>Login</h1></body></html>{{end}}
+			{{define "consent.gohtml"}}<!DOCTYPE html><html><head><title>Consent</title></head><body><h1>Consent</h1></body></html>{{end}}
 			{{define "admin_login.gohtml"}}<!DOCTYPE html><html><head><title>Admin Login</title></head><body><h1>Admin Login</h1></body></html>{{end}}
 			{{define "register_developer.gohtml"}}<!DOCTYPE html><html><head><title>Register Developer</title></head><body><h1>Register Developer</h1></body></html>{{end}}
 		`)))
@@ -121,14 +139,44 @@ func SetupAppManagement(r *gin.Engine, db *sql.DB, redis *redis.Client, cfg *con
 		// Developer management
 		api.POST("/developers", appHandler.RegisterDeveloper)
 		api.GET("/developers/:developer_id/apps", appHandler.GetDeveloperApps)
+		api.POST("/developers/:developer_id/initial-access-tokens", handler.IssueInitialAccessToken)
 
 		// Application management
 		api.POST("/apps", appHandler.RegisterApp)
 		api.GET("/apps", appHandler.GetAllApps)
+		api.PUT("/apps/:app_id/assertion-subjects", appHandler.UpdateAssertionSubjects)
 
 		// Key management
 		api.POST("/apps/:app_id/keys", appHandler.GenerateKeyPair)
 		api.GET("/apps/:app_id/keys", appHandler.GetAppKeys)
 		api.POST("/keys/:key_id/revoke", appHandler.RevokeKey)
+
+		// API resource registry
+		api.GET("/resources", handler.ListResources)
+		api.POST("/resources", handler.CreateResource)
+		api.DELETE("/resources", handler.DeleteResource)
+
+		// Scope catalogue
+		api.GET("/scopes", handler.ListScopes)
+		api.POST("/scopes", handler.CreateScope)
+		api.DELETE("/scopes", handler.DeleteScope)
+
+		// Authorization details types (RFC 9396)
+		api.GET("/authorization-details-types", handler.ListAuthorizationDetailsTypes)
+		api.POST("/authorization-details-types", handler.CreateAuthorizationDetailsType)
+		api.DELETE("/authorization-details-types", handler.DeleteAuthorizationDetailsType)
+
+		// User profiles
+		api.PUT("/users/:user_id/profile", handler.UpdateUserProfile)
+		api.POST("/users/:user_id/totp", handler.EnrollTOTP)
+		api.DELETE("/users/:user_id/totp", handler.DisableTOTP)
+
+		// OAuth2 client policies
+		api.PUT("/clients/:client_id/grant-types", handler.UpdateGrantTypes)
+		api.PUT("/clients/:client_id/token-exchange-policy", handler.UpdateTokenExchangePolicy)
+		api.PUT("/clients/:client_id/access-token-format", handler.UpdateAccessTokenFormat)
+		api.PUT("/clients/:client_id/minimum-acr", handler.UpdateMinimumACR)
+		api.GET("/clients/:client_id/token-policy", handler.GetTokenPolicy)
+		api.PUT("/clients/:client_id/token-policy", handler.UpdateTokenPolicy)
 	}
 }
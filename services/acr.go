@@ -0,0 +1,95 @@
+// Package services provides the authentication context classes users log in with and their levels.
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// Authentication context classes, reported in the "acr" claim of tokens (OpenID Connect Core 1.0 Section 2).
+const (
+	ACRPhoneSMS     = "urn:flash-oauth2:acr:sms"      // Login with a phone number and SMS code
+	ACRPhoneSMSTOTP = "urn:flash-oauth2:acr:sms-totp" // SMS login confirmed with an authenticator app code (RFC 6238)
+)
+
+// ACRValues lists the supported authentication context classes from the weakest to the strongest.
+var ACRValues = []string{ACRPhoneSMS, ACRPhoneSMSTOTP}
+
+// ErrInsufficientUserAuthentication reports that the user's authentication is weaker than the
+// level a request requires (RFC 9470 Section 3).
+var ErrInsufficientUserAuthentication = errors.New("the user authentication does not meet the required level")
+
+// ACRLevel returns the strength of an authentication context class, 0 for unknown classes.
+//
+// Parameters:
+//   - acr: The authentication context class reference
+//
+// Returns:
+//   - int: The level, higher for stronger authentication
+func ACRLevel(acr string) int {
+	for i, value := range ACRValues {
+		if value == acr {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ACRSatisfies reports whether an authentication meets a required authentication context class.
+// Nothing is required when required is empty.
+//
+// Parameters:
+//   - acr: The authentication context class the user authenticated with
+//   - required: The minimum authentication context class, may be empty
+//
+// Returns:
+//   - bool: True if acr is at least as strong as required
+//
+// Example:
+//
+//	services.ACRSatisfies(services.ACRPhoneSMSTOTP, services.ACRPhoneSMS) // true
+func ACRSatisfies(acr, required string) bool {
+	return required == "" || ACRLevel(acr) >= ACRLevel(required)
+}
+
+// StrongestACR returns the strongest of the given authentication context classes, ignoring
+// empty and unknown ones.
+//
+// Parameters:
+//   - values: The authentication context classes to compare
+//
+// Returns:
+//   - string: The strongest class, empty when none is known
+func StrongestACR(values ...string) string {
+	strongest := ""
+	for _, value := range values {
+		if ACRLevel(value) > ACRLevel(strongest) {
+			strongest = value
+		}
+	}
+	return strongest
+}
+
+// RequestedACR returns the authentication context class an acr_values parameter asks for
+// (OpenID Connect Core 1.0 Section 3.1.2.1). Any of the listed classes satisfies the request,
+// so the weakest supported one is returned; unknown classes are ignored.
+//
+// Parameters:
+//   - acrValues: Space-separated authentication context class references, may be empty
+//
+// Returns:
+//   - string: The requested class, empty when no supported class is listed
+//
+// Example:
+//
+//	acr := services.RequestedACR("urn:flash-oauth2:acr:sms-totp urn:example:unknown")
+//	// acr == services.ACRPhoneSMSTOTP
+func RequestedACR(acrValues string) string {
+	requested := ""
+	for _, value := range strings.Fields(acrValues) {
+		if level := ACRLevel(value); level > 0 && (requested == "" || level < ACRLevel(requested)) {
+			requested = value
+		}
+	}
+	return requested
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // AppManagementService provides services for managing external applications and their keys
@@ -195,6 +196,44 @@ func (s *AppManagementService) RevokeKeyPair(keyID string) error {
 	return nil
 }
 
+// GetApp retrieves an application by its ID, including its assertion policy
+func (s *AppManagementService) GetApp(appID string) (*models.ExternalApp, error) {
+	app := &models.ExternalApp{}
+	err := s.db.QueryRow(`
+		SELECT id, name, description, developer_id, status, callback_url, scopes,
+			   created_at, updated_at, revoked_at, assertion_subjects
+		FROM external_apps
+		WHERE id = $1
+	`, appID).Scan(&app.ID, &app.Name, &app.Description, &app.DeveloperID,
+		&app.Status, &app.CallbackURL, &app.Scopes, &app.CreatedAt, &app.UpdatedAt, &app.RevokedAt,
+		pq.Array(&app.AssertionSubjects))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// UpdateAssertionSubjects sets the users an application may assert in JWT bearer grants
+func (s *AppManagementService) UpdateAssertionSubjects(appID string, subjects []string) error {
+	result, err := s.db.Exec(`
+		UPDATE external_apps
+		SET assertion_subjects = $1, updated_at = $2
+		WHERE id = $3
+	`, pq.Array(subjects), time.Now(), appID)
+
+	if err != nil {
+		return fmt.Errorf("failed to update assertion subjects: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("app not found")
+	}
+
+	return nil
+}
+
 // GetDeveloperApps retrieves all applications for a developer
 func (s *AppManagementService) GetDeveloperApps(developerID string) ([]*models.ExternalApp, error) {
 	rows, err := s.db.Query(`
@@ -312,3 +351,22 @@ func (s *AppManagementService) GetKeyPairByKeyID(keyID string) (*models.AppKeyPa
 
 	return kp, nil
 }
+
+// GetActiveKeyPair retrieves a key pair by its key ID and ensures it can still be used,
+// i.e. it has not been revoked and has not expired.
+func (s *AppManagementService) GetActiveKeyPair(keyID string) (*models.AppKeyPair, error) {
+	kp, err := s.GetKeyPairByKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if kp.Status != "active" {
+		return nil, fmt.Errorf("key pair %s is %s", keyID, kp.Status)
+	}
+
+	if kp.ExpiresAt != nil && time.Now().After(*kp.ExpiresAt) {
+		return nil, fmt.Errorf("key pair %s has expired", keyID)
+	}
+
+	return kp, nil
+}
@@ -0,0 +1,271 @@
+// Package services provides the registry of authorization details types and the validation of
+// authorization_details parameters (RFC 9396).
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flash-oauth2/models"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AuthorizationDetailsCommonFields are the members every authorization details type may use
+// besides "type" (RFC 9396 Section 2.2).
+var AuthorizationDetailsCommonFields = []string{"locations", "actions", "datatypes", "identifier", "privileges"}
+
+// AuthorizationDetailsService manages the registry of authorization details types clients can
+// request. Each type has a description shown on the consent screen and may restrict the
+// type-specific members its authorization details carry.
+type AuthorizationDetailsService struct {
+	db *sql.DB // Database connection for the type registry
+}
+
+// NewAuthorizationDetailsService creates a new AuthorizationDetailsService instance.
+//
+// Parameters:
+//   - db: Database connection for type storage
+//
+// Returns:
+//   - *AuthorizationDetailsService: Configured authorization details service instance
+func NewAuthorizationDetailsService(db *sql.DB) *AuthorizationDetailsService {
+	return &AuthorizationDetailsService{
+		db: db,
+	}
+}
+
+// CreateType registers an authorization details type, or replaces the registration of an existing one.
+//
+// Parameters:
+//   - detailsType: The type to register
+//
+// Returns:
+//   - error: An error if the type is empty or database operations fail
+//
+// Example:
+//
+//	err := authorizationDetailsService.CreateType(&models.AuthorizationDetailsType{
+//		Type: "payment_initiation", Description: "发起付款",
+//		Fields: []string{"instructedAmount", "creditorName", "creditorAccount"},
+//	})
+func (s *AuthorizationDetailsService) CreateType(detailsType *models.AuthorizationDetailsType) error {
+	if detailsType.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if detailsType.Fields == nil {
+		detailsType.Fields = []string{}
+	}
+	detailsType.CreatedAt = time.Now()
+
+	_, err := s.db.Exec(`
+		INSERT INTO authorization_details_types (type, description, fields, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (type) DO UPDATE SET
+			description = EXCLUDED.description,
+			fields = EXCLUDED.fields
+	`, detailsType.Type, detailsType.Description, pq.Array(detailsType.Fields), detailsType.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to register authorization details type: %w", err)
+	}
+
+	return nil
+}
+
+// ListTypes returns the registered authorization details types.
+//
+// Returns:
+//   - []*models.AuthorizationDetailsType: The registered types ordered by name
+//   - error: An error if database operations fail
+func (s *AuthorizationDetailsService) ListTypes() ([]*models.AuthorizationDetailsType, error) {
+	rows, err := s.db.Query(`
+		SELECT type, description, COALESCE(fields, '{}'), created_at
+		FROM authorization_details_types
+		ORDER BY type
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []*models.AuthorizationDetailsType{}
+	for rows.Next() {
+		detailsType := &models.AuthorizationDetailsType{}
+		if err := rows.Scan(&detailsType.Type, &detailsType.Description, pq.Array(&detailsType.Fields),
+			&detailsType.CreatedAt); err != nil {
+			return nil, err
+		}
+		types = append(types, detailsType)
+	}
+
+	return types, rows.Err()
+}
+
+// DeleteType removes an authorization details type from the registry. Tokens already issued
+// with it stay valid until they expire, but clients can no longer request it.
+//
+// Parameters:
+//   - name: The type name
+//
+// Returns:
+//   - error: An error if the type is not registered or database operations fail
+func (s *AuthorizationDetailsService) DeleteType(name string) error {
+	result, err := s.db.Exec("DELETE FROM authorization_details_types WHERE type = $1", name)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("unknown authorization details type %s", name)
+	}
+
+	return nil
+}
+
+// Catalogue returns the registered authorization details types by name.
+//
+// Returns:
+//   - map[string]*models.AuthorizationDetailsType: The registered types
+//   - error: An error if database operations fail
+func (s *AuthorizationDetailsService) Catalogue() (map[string]*models.AuthorizationDetailsType, error) {
+	types, err := s.ListTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	catalogue := make(map[string]*models.AuthorizationDetailsType, len(types))
+	for _, detailsType := range types {
+		catalogue[detailsType.Type] = detailsType
+	}
+	return catalogue, nil
+}
+
+// ValidateAuthorizationDetails checks that every authorization detail has a registered type and
+// only carries the common members and the members registered for its type.
+//
+// Parameters:
+//   - details: The parsed authorization details
+//
+// Returns:
+//   - error: An error describing the first invalid authorization detail
+func (s *AuthorizationDetailsService) ValidateAuthorizationDetails(details []models.AuthorizationDetail) error {
+	catalogue, err := s.Catalogue()
+	if err != nil {
+		return err
+	}
+
+	for _, detail := range details {
+		name, _ := detail["type"].(string)
+		detailsType, ok := catalogue[name]
+		if !ok {
+			return fmt.Errorf("unknown authorization details type %s", name)
+		}
+		if len(detailsType.Fields) == 0 {
+			continue
+		}
+
+		allowed := map[string]bool{"type": true}
+		for _, member := range AuthorizationDetailsCommonFields {
+			allowed[member] = true
+		}
+		for _, member := range detailsType.Fields {
+			allowed[member] = true
+		}
+		for member := range detail {
+			if !allowed[member] {
+				return fmt.Errorf("member %s is not allowed for authorization details type %s", member, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseAuthorizationDetails parses the value of the authorization_details parameter
+// (RFC 9396 Section 2): a JSON array of objects, each with a string "type" member.
+//
+// Parameters:
+//   - raw: The JSON authorization details, may be empty
+//
+// Returns:
+//   - []models.AuthorizationDetail: The parsed authorization details, nil when raw is empty
+//   - error: An error if the value is not a valid authorization_details parameter
+//
+// Example:
+//
+//	details, err := services.ParseAuthorizationDetails(`[{"type":"payment_initiation","instructedAmount":{"currency":"CNY","amount":"500.00"}}]`)
+func ParseAuthorizationDetails(raw string) ([]models.AuthorizationDetail, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var details []models.AuthorizationDetail
+	if err := json.Unmarshal([]byte(raw), &details); err != nil {
+		return nil, fmt.Errorf("authorization_details must be a JSON array of objects")
+	}
+	if len(details) == 0 {
+		return nil, fmt.Errorf("authorization_details must not be empty")
+	}
+
+	for _, detail := range details {
+		if name, ok := detail["type"].(string); !ok || name == "" {
+			return nil, fmt.Errorf("every authorization detail requires a type")
+		}
+	}
+
+	return details, nil
+}
+
+// ContainsAuthorizationDetails reports whether every requested authorization detail is one of
+// the granted ones, so a token request can narrow but not extend a grant (RFC 9396 Section 6.1).
+//
+// Parameters:
+//   - granted: The authorization details of the grant
+//   - requested: The authorization details of the token request
+//
+// Returns:
+//   - bool: True if every requested authorization detail was granted
+func ContainsAuthorizationDetails(granted, requested []models.AuthorizationDetail) bool {
+	for _, detail := range requested {
+		found := false
+		for _, grantedDetail := range granted {
+			if reflect.DeepEqual(detail, grantedDetail) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizationDetailsForResource returns the authorization details that apply to a resource:
+// those without locations and those whose locations include the resource identifier
+// (RFC 9396 Section 9.1).
+//
+// Parameters:
+//   - details: The authorization details of the grant
+//   - resource: The resource identifier the access token is issued for
+//
+// Returns:
+//   - []models.AuthorizationDetail: The authorization details for the resource
+func AuthorizationDetailsForResource(details []models.AuthorizationDetail, resource string) []models.AuthorizationDetail {
+	var applicable []models.AuthorizationDetail
+	for _, detail := range details {
+		locations, ok := detail["locations"].([]any)
+		if !ok {
+			applicable = append(applicable, detail)
+			continue
+		}
+		for _, location := range locations {
+			if location == resource {
+				applicable = append(applicable, detail)
+				break
+			}
+		}
+	}
+	return applicable
+}
@@ -0,0 +1,103 @@
+// Package services provides the delivery of back-channel logout notifications to clients.
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BackchannelLogoutEvent is the event member of logout tokens (OpenID Connect Back-Channel Logout 1.0 Section 2.4).
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// BackchannelLogoutService delivers logout tokens to the backchannel_logout_uri of clients
+// when a user's session ends (OpenID Connect Back-Channel Logout 1.0 Section 2.5). Deliveries
+// run in the background so logout does not wait for clients, and are retried with exponential
+// backoff when a client cannot be reached or answers with a server error.
+type BackchannelLogoutService struct {
+	client     *http.Client  // HTTP client for logout requests
+	attempts   int           // Delivery attempts per logout token
+	retryDelay time.Duration // Delay before the first retry, doubled for each further retry
+}
+
+// NewBackchannelLogoutService creates a new BackchannelLogoutService instance.
+//
+// Parameters:
+//   - attempts: Delivery attempts per logout token (at least 1)
+//   - retryDelay: Delay before the first retry, doubled for each further retry
+//
+// Returns:
+//   - *BackchannelLogoutService: Configured back-channel logout service instance
+func NewBackchannelLogoutService(attempts int, retryDelay time.Duration) *BackchannelLogoutService {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return &BackchannelLogoutService{
+		client:     clientEndpointHTTPClient,
+		attempts:   attempts,
+		retryDelay: retryDelay,
+	}
+}
+
+// SetHTTPClient replaces the HTTP client that delivers logout tokens (for testing, where
+// clients listen on loopback addresses the default client refuses).
+//
+// Parameters:
+//   - client: The HTTP client for logout requests
+func (s *BackchannelLogoutService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// Notify sends a logout token to a client's back-channel logout endpoint in the background.
+//
+// Parameters:
+//   - logoutURI: The client's backchannel_logout_uri
+//   - logoutToken: The signed logout token (see JWTService.GenerateLogoutToken)
+//
+// Example:
+//
+//	backchannelLogoutService.Notify(client.BackchannelLogoutURI, logoutToken)
+func (s *BackchannelLogoutService) Notify(logoutURI, logoutToken string) {
+	go func() {
+		if err := s.deliver(logoutURI, logoutToken); err != nil {
+			log.Printf("Back-channel logout to %s failed: %v", logoutURI, err)
+		}
+	}()
+}
+
+// deliver posts a logout token, retrying until the client accepts it, rejects it with a
+// client error, or the attempts are exhausted.
+func (s *BackchannelLogoutService) deliver(logoutURI, logoutToken string) error {
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+	delay := s.retryDelay
+
+	var err error
+	for attempt := 1; attempt <= s.attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var resp *http.Response
+		resp, err = s.client.Post(logoutURI, "application/x-www-form-urlencoded", strings.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode < 500:
+			// 客户端拒绝的令牌重试无效
+			return fmt.Errorf("client rejected the logout token: status %d", resp.StatusCode)
+		}
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", s.attempts, err)
+}
@@ -0,0 +1,334 @@
+// Package services provides Client-Initiated Backchannel Authentication requests.
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flash-oauth2/models"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Token delivery modes of backchannel authentication (OpenID Connect CIBA Core 1.0 Section 5).
+const (
+	CIBADeliveryModePoll = "poll" // The client polls the token endpoint
+	CIBADeliveryModePing = "ping" // The client is pinged, then calls the token endpoint
+)
+
+// CIBADeliveryModes lists the token delivery modes clients can register.
+var CIBADeliveryModes = []string{CIBADeliveryModePoll, CIBADeliveryModePing}
+
+// Statuses of backchannel authentication requests.
+const (
+	CIBAStatusPending  = "pending"
+	CIBAStatusApproved = "approved"
+	CIBAStatusDenied   = "denied"
+)
+
+// CIBAPollInterval is the minimum number of seconds between token requests for a backchannel
+// authentication request; each slow_down answer adds another CIBAPollInterval.
+const CIBAPollInterval = 5
+
+// cibaPollAttempts limits how often Poll retries when the request changes while it is polled.
+const cibaPollAttempts = 3
+
+// Errors answering token requests for backchannel authentication requests that are not
+// approved (OpenID Connect CIBA Core 1.0 Section 11).
+var (
+	ErrCIBAAuthorizationPending = errors.New("the user has not yet answered the authentication request")
+	ErrCIBASlowDown             = errors.New("the authentication request is polled too frequently")
+	ErrCIBAAccessDenied         = errors.New("the user denied the authentication request")
+	ErrCIBAExpiredToken         = errors.New("the authentication request expired")
+)
+
+// CIBAService stores backchannel authentication requests (OpenID Connect CIBA Core 1.0) in
+// Redis. Each request is referenced by the auth_req_id returned to the client and by the
+// approval token of the link sent to the user, who approves or denies it on their phone.
+type CIBAService struct {
+	redis  *redis.Client // Redis client for pending authentication requests
+	client *http.Client  // HTTP client for ping callbacks
+}
+
+// NewCIBAService creates a new CIBAService instance backed by Redis.
+//
+// Parameters:
+//   - redis: Redis client for authentication request storage
+//
+// Returns:
+//   - *CIBAService: Configured CIBA service instance
+func NewCIBAService(redis *redis.Client) *CIBAService {
+	return &CIBAService{
+		redis:  redis,
+		client: clientEndpointHTTPClient,
+	}
+}
+
+// SetHTTPClient replaces the HTTP client that sends ping callbacks (for testing, where
+// clients listen on loopback addresses the default client refuses).
+//
+// Parameters:
+//   - client: The HTTP client for ping callbacks
+func (s *CIBAService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// CreateRequest stores a pending backchannel authentication request, assigning its auth_req_id,
+// and returns the approval token of the link sent to the user. Both expire with the request.
+//
+// Parameters:
+//   - req: The request (ClientID, UserID, Scope, BindingMessage, ClientNotificationToken)
+//   - lifetime: How long the user has to answer
+//
+// Returns:
+//   - string: The approval token
+//   - error: An error if Redis operations fail
+//
+// Example:
+//
+//	approvalToken, err := cibaService.CreateRequest(&models.BackchannelAuthenticationRequest{
+//		ClientID: "call-centre", UserID: 123, Scope: "openid",
+//	}, 5*time.Minute)
+func (s *CIBAService) CreateRequest(req *models.BackchannelAuthenticationRequest, lifetime time.Duration) (string, error) {
+	ctx := context.Background()
+
+	req.AuthReqID = generateRandomString(32)
+	req.Status = CIBAStatusPending
+	req.ExpiresAt = time.Now().Add(lifetime)
+	req.Interval = CIBAPollInterval
+	if err := s.save(ctx, s.redis, req); err != nil {
+		return "", err
+	}
+
+	approvalToken := generateRandomString(32)
+	if err := s.redis.Set(ctx, cibaApprovalKey(approvalToken), req.AuthReqID, lifetime).Err(); err != nil {
+		return "", err
+	}
+
+	return approvalToken, nil
+}
+
+// GetPendingRequest resolves the approval token of a link sent to a user to the request
+// waiting for the user's answer.
+//
+// Parameters:
+//   - approvalToken: The token from the approval link
+//
+// Returns:
+//   - *models.BackchannelAuthenticationRequest: The pending request
+//   - error: An error if the link is unknown, expired or already answered
+func (s *CIBAService) GetPendingRequest(approvalToken string) (*models.BackchannelAuthenticationRequest, error) {
+	ctx := context.Background()
+
+	authReqID, err := s.redis.Get(ctx, cibaApprovalKey(approvalToken)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("authentication request not found or expired")
+	}
+
+	req, err := s.load(ctx, authReqID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != CIBAStatusPending {
+		return nil, fmt.Errorf("authentication request already answered")
+	}
+
+	return req, nil
+}
+
+// Answer records the user's answer to a pending request. The approval link is invalidated
+// before the answer is recorded, so that of concurrent answers only the first one counts.
+// Approved requests remember how the user authenticated for the tokens issued from them.
+//
+// Parameters:
+//   - approvalToken: The token from the approval link
+//   - approved: Whether the user approved the request
+//   - authn: How the user authenticated, for approved requests
+//
+// Returns:
+//   - *models.BackchannelAuthenticationRequest: The answered request
+//   - error: An error if the link is unknown, expired or already answered
+func (s *CIBAService) Answer(approvalToken string, approved bool, authn models.UserAuthentication) (*models.BackchannelAuthenticationRequest, error) {
+	ctx := context.Background()
+
+	// 先原子地取走审批令牌，并发提交的答复只有一个生效
+	authReqID, err := s.redis.GetDel(ctx, cibaApprovalKey(approvalToken)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("authentication request not found or expired")
+	}
+
+	req, err := s.load(ctx, authReqID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != CIBAStatusPending {
+		return nil, fmt.Errorf("authentication request already answered")
+	}
+
+	if approved {
+		req.Status = CIBAStatusApproved
+		req.Authentication = authn
+	} else {
+		req.Status = CIBAStatusDenied
+	}
+	if err := s.save(ctx, s.redis, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Poll answers a token request of a client for one of its backchannel authentication requests.
+// Approved requests are returned and removed, so tokens are issued only once; denied requests
+// are removed as well. Clients polling faster than the request's interval are told to slow down.
+// The poll time of pending requests is recorded in a transaction, so it never overwrites an
+// answer given in the meantime.
+//
+// Parameters:
+//   - clientID: The authenticated client
+//   - authReqID: The auth_req_id returned by the backchannel authentication endpoint
+//
+// Returns:
+//   - *models.BackchannelAuthenticationRequest: The approved request
+//   - error: ErrCIBAAuthorizationPending, ErrCIBASlowDown, ErrCIBAAccessDenied, ErrCIBAExpiredToken,
+//     or another error if the auth_req_id is unknown or belongs to another client
+func (s *CIBAService) Poll(clientID, authReqID string) (*models.BackchannelAuthenticationRequest, error) {
+	ctx := context.Background()
+
+	var err error
+	for attempt := 0; attempt < cibaPollAttempts; attempt++ {
+		var req *models.BackchannelAuthenticationRequest
+		req, err = s.poll(ctx, clientID, authReqID)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return req, err
+		}
+		// 用户在读取和写回之间作答，重新读取请求
+	}
+	return nil, err
+}
+
+// poll answers a token request within a transaction on the stored request. It returns
+// redis.TxFailedErr when the request was changed before the poll time was recorded.
+func (s *CIBAService) poll(ctx context.Context, clientID, authReqID string) (*models.BackchannelAuthenticationRequest, error) {
+	var result *models.BackchannelAuthenticationRequest
+	err := s.redis.Watch(ctx, func(tx *redis.Tx) error {
+		req, err := s.load(ctx, authReqID)
+		if err != nil {
+			return err
+		}
+		if req.ClientID != clientID {
+			return fmt.Errorf("auth_req_id was issued to a different client")
+		}
+
+		now := time.Now()
+		switch {
+		case now.After(req.ExpiresAt):
+			s.redis.Del(ctx, cibaRequestKey(authReqID))
+			return ErrCIBAExpiredToken
+		case req.Status == CIBAStatusApproved:
+			// 只有删除成功的请求签发令牌，防止并发轮询重复签发
+			if deleted, err := s.redis.Del(ctx, cibaRequestKey(authReqID)).Result(); err != nil || deleted == 0 {
+				return fmt.Errorf("auth_req_id was already used")
+			}
+			result = req
+			return nil
+		case req.Status == CIBAStatusDenied:
+			s.redis.Del(ctx, cibaRequestKey(authReqID))
+			return ErrCIBAAccessDenied
+		}
+
+		// 轮询过快时延长间隔
+		tooFast := now.Sub(req.LastPolledAt) < time.Duration(req.Interval)*time.Second
+		if tooFast {
+			req.Interval += CIBAPollInterval
+		}
+		req.LastPolledAt = now
+
+		// 请求在读取后被修改（用户已作答）时事务失败，不会覆盖答复
+		if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return s.save(ctx, pipe, req)
+		}); err != nil {
+			return err
+		}
+
+		if tooFast {
+			return ErrCIBASlowDown
+		}
+		return ErrCIBAAuthorizationPending
+	}, cibaRequestKey(authReqID))
+
+	return result, err
+}
+
+// Ping notifies a client in ping mode that the user answered one of its requests, by posting
+// the auth_req_id to its client notification endpoint with the client notification token as
+// bearer token (OpenID Connect CIBA Core 1.0 Section 10.2). The callback runs in the background.
+//
+// Parameters:
+//   - endpoint: The client's backchannel_client_notification_endpoint
+//   - req: The answered request
+func (s *CIBAService) Ping(endpoint string, req *models.BackchannelAuthenticationRequest) {
+	body, _ := json.Marshal(map[string]string{"auth_req_id": req.AuthReqID})
+	notificationToken := req.ClientNotificationToken
+
+	go func() {
+		httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("CIBA ping to %s failed: %v", endpoint, err)
+			return
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+notificationToken)
+
+		resp, err := s.client.Do(httpReq)
+		if err != nil {
+			log.Printf("CIBA ping to %s failed: %v", endpoint, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("CIBA ping to %s failed: status %d", endpoint, resp.StatusCode)
+		}
+	}()
+}
+
+// save stores a request until it expires, directly or as part of a transaction.
+func (s *CIBAService) save(ctx context.Context, rdb redis.Cmdable, req *models.BackchannelAuthenticationRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	// 过期后保留片刻，以便客户端收到expired_token
+	return rdb.Set(ctx, cibaRequestKey(req.AuthReqID), data, time.Until(req.ExpiresAt)+time.Minute).Err()
+}
+
+// load returns a stored request.
+func (s *CIBAService) load(ctx context.Context, authReqID string) (*models.BackchannelAuthenticationRequest, error) {
+	data, err := s.redis.Get(ctx, cibaRequestKey(authReqID)).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("unknown auth_req_id")
+	}
+
+	var req models.BackchannelAuthenticationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// cibaRequestKey returns the Redis key of a backchannel authentication request.
+func cibaRequestKey(authReqID string) string {
+	return fmt.Sprintf("ciba_request:%s", authReqID)
+}
+
+// cibaApprovalKey returns the Redis key of an approval link.
+func cibaApprovalKey(approvalToken string) string {
+	return fmt.Sprintf("ciba_approval:%s", approvalToken)
+}
@@ -0,0 +1,75 @@
+// Package services provides parsing of OpenID Connect claims requests.
+package services
+
+import (
+	"encoding/json"
+	"flash-oauth2/models"
+	"fmt"
+	"sort"
+)
+
+// ParseClaimsRequest parses the value of the claims authorization parameter
+// (OpenID Connect Core 1.0 Section 5.5). Members other than userinfo and id_token are ignored.
+//
+// Parameters:
+//   - raw: The JSON claims request, may be empty
+//
+// Returns:
+//   - *models.ClaimsRequest: The parsed request, nil when raw is empty
+//   - error: An error if the value is not a valid claims request
+//
+// Example:
+//
+//	request, err := services.ParseClaimsRequest(`{"id_token":{"email":{"essential":true}},"userinfo":{"name":null}}`)
+func ParseClaimsRequest(raw string) (*models.ClaimsRequest, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	request := &models.ClaimsRequest{}
+	if err := json.Unmarshal([]byte(raw), request); err != nil {
+		return nil, fmt.Errorf("invalid claims parameter: %w", err)
+	}
+
+	return request, nil
+}
+
+// RestrictClaimsRequest removes the claims a client may not request from a claims request.
+//
+// Parameters:
+//   - request: The claims request to restrict
+//   - supported: The claims the client may request
+//
+// Example:
+//
+//	services.RestrictClaimsRequest(request, []string{"name", "email", "email_verified"})
+func RestrictClaimsRequest(request *models.ClaimsRequest, supported []string) {
+	allowed := make(map[string]bool, len(supported))
+	for _, name := range supported {
+		allowed[name] = true
+	}
+
+	for _, claims := range []map[string]*models.ClaimRequest{request.UserInfo, request.IDToken} {
+		for name := range claims {
+			if !allowed[name] {
+				delete(claims, name)
+			}
+		}
+	}
+}
+
+// ClaimNames returns the names of the requested claims, sorted.
+//
+// Parameters:
+//   - claims: The requested claims of the userinfo or id_token member
+//
+// Returns:
+//   - []string: The claim names
+func ClaimNames(claims map[string]*models.ClaimRequest) []string {
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,232 @@
+// Package services provides verification of JWTs signed by OAuth2 clients and the keys clients register.
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flash-oauth2/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// ClientJWTSigningAlgorithms lists the algorithms accepted for JWTs signed by clients.
+// Symmetric algorithms and "none" are never accepted.
+var ClientJWTSigningAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// ClientJWKSCacheLifetime is how long a key set fetched from a client's jwks_uri is reused.
+// Keys the client publishes later are used once the cached set expires.
+const ClientJWKSCacheLifetime = 5 * time.Minute
+
+// ClientJWTService verifies JWTs signed by OAuth2 clients, such as request objects.
+// Verification keys are the key pairs issued to the external application linked to
+// the client, or the keys the client registered by value (jwks) or publishes at its jwks_uri.
+// The registered keys also provide the encryption keys of tokens issued to the client and
+// the self-signed certificates of mutual TLS clients.
+type ClientJWTService struct {
+	appService *AppManagementService // Access to application key pairs
+	redis      *redis.Client         // Redis client caching key sets fetched from jwks_uri
+}
+
+// NewClientJWTService creates a new ClientJWTService instance.
+//
+// Parameters:
+//   - appService: Application management service used to look up key pairs
+//   - redis: Redis client for caching key sets fetched from jwks_uri
+//
+// Returns:
+//   - *ClientJWTService: Configured client JWT service instance
+func NewClientJWTService(appService *AppManagementService, redis *redis.Client) *ClientJWTService {
+	return &ClientJWTService{
+		appService: appService,
+		redis:      redis,
+	}
+}
+
+// ParseClientJWT verifies the signature of a JWT signed by the client and returns its claims.
+// Additional parser options (issuer, audience, ...) are applied on top of the algorithm check.
+//
+// Parameters:
+//   - client: The client that signed the JWT
+//   - tokenString: The compact serialized JWT
+//   - opts: Extra validation options
+//
+// Returns:
+//   - jwt.MapClaims: The verified claims
+//   - error: An error if no key is found, the signature is invalid or a claim check fails
+//
+// Example:
+//
+//	claims, err := clientJWTService.ParseClientJWT(client, requestObject, jwt.WithIssuer(client.ID))
+func (s *ClientJWTService) ParseClientJWT(client *models.OAuthClient, tokenString string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods(ClientJWTSigningAlgorithms)}, opts...)
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, s.keyfunc(client), opts...); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// FetchRequestObject downloads a request object passed by reference (RFC 9101 Section 5.2).
+//
+// Parameters:
+//   - requestURI: The HTTPS URL hosting the request object
+//
+// Returns:
+//   - string: The compact serialized request object
+//   - error: An error if the download fails
+func (s *ClientJWTService) FetchRequestObject(requestURI string) (string, error) {
+	body, err := fetchRemoteDocument(requestURI)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// keyfunc resolves the verification key of a client JWT from its "kid" header.
+func (s *ClientJWTService) keyfunc(client *models.OAuthClient) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		// 优先使用关联应用签发的密钥对
+		if client.AppID != "" && kid != "" {
+			key, err := s.appKey(client, kid, token.Method.Alg())
+			if err == nil || !errors.Is(err, sql.ErrNoRows) {
+				return key, err
+			}
+		}
+
+		// 其次使用客户端注册或发布的JWKS
+		if client.JWKS != "" || client.JWKSURI != "" {
+			keySet, err := s.ClientKeySet(client)
+			if err != nil {
+				return nil, err
+			}
+
+			jwk, err := keySet.Find(kid, "sig")
+			if err != nil {
+				return nil, err
+			}
+
+			return jwk.PublicKey()
+		}
+
+		return nil, fmt.Errorf("no signing key registered for client %s", client.ID)
+	}
+}
+
+// ClientKeySet returns the JSON Web Key Set of a client: the set registered by value,
+// or the one published at its jwks_uri, which is fetched at most once per ClientJWKSCacheLifetime.
+//
+// Parameters:
+//   - client: The client whose keys are requested
+//
+// Returns:
+//   - *JWKSet: The client's key set
+//   - error: An error if the client has no keys or the key set cannot be loaded
+func (s *ClientJWTService) ClientKeySet(client *models.OAuthClient) (*JWKSet, error) {
+	if client.JWKS != "" {
+		keySet := &JWKSet{}
+		if err := json.Unmarshal([]byte(client.JWKS), keySet); err != nil {
+			return nil, fmt.Errorf("invalid JWKS registered for client %s: %w", client.ID, err)
+		}
+		return keySet, nil
+	}
+
+	if client.JWKSURI != "" {
+		return s.fetchClientJWKS(client.JWKSURI)
+	}
+
+	return nil, fmt.Errorf("no keys registered for client %s", client.ID)
+}
+
+// fetchClientJWKS returns the key set published at a jwks_uri, from the cache when it was
+// fetched within ClientJWKSCacheLifetime.
+func (s *ClientJWTService) fetchClientJWKS(jwksURI string) (*JWKSet, error) {
+	ctx := context.Background()
+
+	if data, err := s.redis.Get(ctx, clientJWKSKey(jwksURI)).Bytes(); err == nil {
+		keySet := &JWKSet{}
+		if err := json.Unmarshal(data, keySet); err == nil {
+			return keySet, nil
+		}
+	}
+
+	keySet, err := FetchJWKS(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+
+	// 缓存失败不影响本次认证
+	if data, err := json.Marshal(keySet); err == nil {
+		s.redis.Set(ctx, clientJWKSKey(jwksURI), data, ClientJWKSCacheLifetime)
+	}
+	return keySet, nil
+}
+
+// clientJWKSKey returns the Redis key of a cached key set.
+func clientJWKSKey(jwksURI string) string {
+	return fmt.Sprintf("client_jwks:%s", jwksURI)
+}
+
+// IDTokenEncryption returns how ID tokens issued to a client are encrypted, or nil when the
+// client did not register id_token_encrypted_response_alg.
+//
+// Parameters:
+//   - client: The client the ID token is issued to
+//
+// Returns:
+//   - *JWEEncryption: The client's encryption key and algorithms, nil for signed-only ID tokens
+//   - error: An error if no suitable encryption key is found
+func (s *ClientJWTService) IDTokenEncryption(client *models.OAuthClient) (*JWEEncryption, error) {
+	if client.IDTokenEncryptedResponseAlg == "" {
+		return nil, nil
+	}
+
+	keySet, err := s.ClientKeySet(client)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keySet.FindEncryptionKey(client.IDTokenEncryptedResponseAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWEEncryption{
+		Key: key,
+		Alg: client.IDTokenEncryptedResponseAlg,
+		Enc: client.IDTokenEncryptedResponseEnc,
+	}, nil
+}
+
+// appKey returns the public key of an active key pair issued to the client's application.
+func (s *ClientJWTService) appKey(client *models.OAuthClient, kid, alg string) (any, error) {
+	kp, err := s.appService.GetActiveKeyPair(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if kp.AppID != client.AppID {
+		return nil, fmt.Errorf("key %s does not belong to client %s", kid, client.ID)
+	}
+
+	if kp.Algorithm != alg {
+		return nil, fmt.Errorf("key %s must be used with %s", kid, kp.Algorithm)
+	}
+
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(kp.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	s.appService.UpdateKeyLastUsed(kid)
+	return publicKey, nil
+}
@@ -0,0 +1,258 @@
+// Package services provides dynamic client registration (RFC 7591) and management (RFC 7592).
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flash-oauth2/models"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrInvalidInitialAccessToken is returned when an initial access token is unknown,
+// already used, expired, or belongs to a developer that is no longer active.
+var ErrInvalidInitialAccessToken = errors.New("invalid or expired initial access token")
+
+// ClientRegistrationService manages OAuth2 clients registered by developers through the
+// dynamic client registration endpoint. Registration is authorized by initial access tokens
+// issued from the admin dashboard; each registered client receives a registration access
+// token for managing its own configuration. Only hashes of both token kinds are stored.
+type ClientRegistrationService struct {
+	db *sql.DB // Database connection for clients and initial access tokens
+}
+
+// NewClientRegistrationService creates a new ClientRegistrationService instance.
+//
+// Parameters:
+//   - db: Database connection for client storage
+//
+// Returns:
+//   - *ClientRegistrationService: Configured client registration service instance
+func NewClientRegistrationService(db *sql.DB) *ClientRegistrationService {
+	return &ClientRegistrationService{
+		db: db,
+	}
+}
+
+// CreateInitialAccessToken issues a single-use initial access token that lets a developer
+// register one client at the registration endpoint.
+//
+// Parameters:
+//   - developerID: The developer the registered client will be linked to
+//   - lifetime: How long the token can be used
+//
+// Returns:
+//   - string: The initial access token (only its hash is stored)
+//   - time.Time: When the token expires
+//   - error: An error if the developer does not exist or database operations fail
+//
+// Example:
+//
+//	token, expiresAt, err := registrationService.CreateInitialAccessToken("dev_123", 7*24*time.Hour)
+func (s *ClientRegistrationService) CreateInitialAccessToken(developerID string, lifetime time.Duration) (string, time.Time, error) {
+	var status string
+	err := s.db.QueryRow("SELECT status FROM developers WHERE id = $1", developerID).Scan(&status)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("developer not found: %w", err)
+	}
+	if status != "active" {
+		return "", time.Time{}, fmt.Errorf("developer is %s", status)
+	}
+
+	token := generateRandomString(48)
+	expiresAt := time.Now().Add(lifetime)
+
+	_, err = s.db.Exec(`
+		INSERT INTO initial_access_tokens (token_hash, developer_id, expires_at)
+		VALUES ($1, $2, $3)
+	`, hashToken(token), developerID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// RegisterClient consumes an initial access token and stores a new client linked to the
+// token's developer. The client ID, client secret and registration access token are generated.
+//
+// Parameters:
+//   - initialAccessToken: The initial access token presented by the developer
+//   - client: The validated client metadata; ID, Secret and DeveloperID are filled in
+//
+// Returns:
+//   - string: The registration access token for managing the client (only its hash is stored)
+//   - error: ErrInvalidInitialAccessToken if the token cannot be used, or a database error
+func (s *ClientRegistrationService) RegisterClient(initialAccessToken string, client *models.OAuthClient) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// 消费初始访问令牌（只能使用一次）
+	var developerID string
+	err = tx.QueryRow(`
+		UPDATE initial_access_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING developer_id
+	`, hashToken(initialAccessToken)).Scan(&developerID)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidInitialAccessToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var status string
+	if err := tx.QueryRow("SELECT status FROM developers WHERE id = $1", developerID).Scan(&status); err != nil {
+		return "", err
+	}
+	if status != "active" {
+		return "", fmt.Errorf("%w: developer is %s", ErrInvalidInitialAccessToken, status)
+	}
+
+	client.ID = uuid.New().String()
+	client.Secret = generateRandomString(64)
+	client.DeveloperID = developerID
+	client.CreatedAt = time.Now()
+	registrationAccessToken := generateRandomString(48)
+
+	_, err = tx.Exec(`
+		INSERT INTO oauth_clients (id, secret, name, redirect_uris, grant_types, response_types, scope, created_at,
+			require_pushed_authorization_requests, require_signed_request_object, jwks_uri,
+			token_endpoint_auth_method, tls_client_auth_subject_dn, tls_client_auth_san_dns, tls_client_auth_san_uri,
+			tls_client_auth_san_ip, tls_client_auth_san_email, tls_client_certificate_bound_access_tokens,
+			developer_id, client_uri, logo_uri, contacts, registration_access_token_hash, userinfo_signed_response_alg,
+			jwks, id_token_encrypted_response_alg, id_token_encrypted_response_enc, subject_type, sector_identifier_uri,
+			post_logout_redirect_uris, backchannel_logout_uri, backchannel_logout_session_required,
+			frontchannel_logout_uri, frontchannel_logout_session_required, backchannel_token_delivery_mode,
+			backchannel_client_notification_endpoint, application_type, request_uris)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, NULLIF($13, ''), NULLIF($14, ''),
+			NULLIF($15, ''), NULLIF($16, ''), NULLIF($17, ''), $18, $19, NULLIF($20, ''), NULLIF($21, ''), $22, $23,
+			NULLIF($24, ''), NULLIF($25, ''), NULLIF($26, ''), NULLIF($27, ''), $28, NULLIF($29, ''), $30,
+			NULLIF($31, ''), $32, NULLIF($33, ''), $34, NULLIF($35, ''), NULLIF($36, ''), $37, $38)
+	`, client.ID, client.Secret, client.Name, pq.Array(client.RedirectURIs), pq.Array(client.GrantTypes),
+		pq.Array(client.ResponseTypes), client.Scope, client.CreatedAt,
+		client.RequirePAR, client.RequireSignedRequestObject, client.JWKSURI,
+		client.TokenEndpointAuthMethod, client.TLSClientAuthSubjectDN, client.TLSClientAuthSANDNS, client.TLSClientAuthSANURI,
+		client.TLSClientAuthSANIP, client.TLSClientAuthSANEmail, client.TLSClientCertificateBoundAccessTokens,
+		client.DeveloperID, client.ClientURI, client.LogoURI, pq.Array(client.Contacts), hashToken(registrationAccessToken),
+		client.UserInfoSignedResponseAlg, client.JWKS, client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptedResponseEnc,
+		client.SubjectType, client.SectorIdentifierURI, pq.Array(client.PostLogoutRedirectURIs),
+		client.BackchannelLogoutURI, client.BackchannelLogoutSessionRequired,
+		client.FrontchannelLogoutURI, client.FrontchannelLogoutSessionRequired, client.BackchannelTokenDeliveryMode,
+		client.BackchannelClientNotificationEndpoint, client.ApplicationType, pq.Array(client.RequestURIs))
+	if err != nil {
+		return "", fmt.Errorf("failed to register client: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return registrationAccessToken, nil
+}
+
+// GetRegisteredClient returns a dynamically registered client after checking the
+// registration access token presented for it.
+//
+// Parameters:
+//   - clientID: The client identifier from the registration client URI
+//   - registrationAccessToken: The bearer token presented by the caller
+//
+// Returns:
+//   - *models.OAuthClient: The registered client
+//   - error: An error if the client does not exist or the token does not match
+func (s *ClientRegistrationService) GetRegisteredClient(clientID, registrationAccessToken string) (*models.OAuthClient, error) {
+	var tokenHash string
+	err := s.db.QueryRow(`
+		SELECT COALESCE(registration_access_token_hash, '') FROM oauth_clients WHERE id = $1
+	`, clientID).Scan(&tokenHash)
+	if err != nil || tokenHash == "" {
+		return nil, fmt.Errorf("invalid registration access token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(tokenHash), []byte(hashToken(registrationAccessToken))) != 1 {
+		return nil, fmt.Errorf("invalid registration access token")
+	}
+
+	return scanClient(s.db.QueryRow(`SELECT `+clientColumns+` FROM oauth_clients WHERE id = $1`, clientID))
+}
+
+// UpdateClient replaces the metadata of a registered client (RFC 7592 Section 2.2).
+// The client ID, secret, developer and registration access token are not changed.
+//
+// Parameters:
+//   - client: The client with its new, validated metadata
+//
+// Returns:
+//   - error: An error if database operations fail
+func (s *ClientRegistrationService) UpdateClient(client *models.OAuthClient) error {
+	_, err := s.db.Exec(`
+		UPDATE oauth_clients SET name = $2, redirect_uris = $3, grant_types = $4, response_types = $5, scope = $6,
+			require_pushed_authorization_requests = $7, require_signed_request_object = $8, jwks_uri = NULLIF($9, ''),
+			token_endpoint_auth_method = $10, tls_client_auth_subject_dn = NULLIF($11, ''),
+			tls_client_auth_san_dns = NULLIF($12, ''), tls_client_auth_san_uri = NULLIF($13, ''),
+			tls_client_auth_san_ip = NULLIF($14, ''), tls_client_auth_san_email = NULLIF($15, ''),
+			tls_client_certificate_bound_access_tokens = $16, client_uri = NULLIF($17, ''), logo_uri = NULLIF($18, ''),
+			contacts = $19, userinfo_signed_response_alg = NULLIF($20, ''), jwks = NULLIF($21, ''),
+			id_token_encrypted_response_alg = NULLIF($22, ''), id_token_encrypted_response_enc = NULLIF($23, ''),
+			subject_type = $24, sector_identifier_uri = NULLIF($25, ''), post_logout_redirect_uris = $26,
+			backchannel_logout_uri = NULLIF($27, ''), backchannel_logout_session_required = $28,
+			frontchannel_logout_uri = NULLIF($29, ''), frontchannel_logout_session_required = $30,
+			backchannel_token_delivery_mode = NULLIF($31, ''), backchannel_client_notification_endpoint = NULLIF($32, ''),
+			application_type = $33, request_uris = $34
+		WHERE id = $1
+	`, client.ID, client.Name, pq.Array(client.RedirectURIs), pq.Array(client.GrantTypes),
+		pq.Array(client.ResponseTypes), client.Scope, client.RequirePAR, client.RequireSignedRequestObject, client.JWKSURI,
+		client.TokenEndpointAuthMethod, client.TLSClientAuthSubjectDN, client.TLSClientAuthSANDNS, client.TLSClientAuthSANURI,
+		client.TLSClientAuthSANIP, client.TLSClientAuthSANEmail, client.TLSClientCertificateBoundAccessTokens,
+		client.ClientURI, client.LogoURI, pq.Array(client.Contacts), client.UserInfoSignedResponseAlg, client.JWKS,
+		client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptedResponseEnc, client.SubjectType, client.SectorIdentifierURI,
+		pq.Array(client.PostLogoutRedirectURIs), client.BackchannelLogoutURI, client.BackchannelLogoutSessionRequired,
+		client.FrontchannelLogoutURI, client.FrontchannelLogoutSessionRequired, client.BackchannelTokenDeliveryMode,
+		client.BackchannelClientNotificationEndpoint, client.ApplicationType, pq.Array(client.RequestURIs))
+	return err
+}
+
+// DeleteClient removes a registered client together with its outstanding
+// authorization codes and tokens (RFC 7592 Section 2.3).
+//
+// Parameters:
+//   - clientID: The client to delete
+//
+// Returns:
+//   - error: An error if database operations fail
+func (s *ClientRegistrationService) DeleteClient(clientID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 先删除引用该客户端的授权数据
+	for _, table := range []string{"auth_codes", "access_tokens", "refresh_tokens"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE client_id = $1", clientID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM oauth_clients WHERE id = $1", clientID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// hashToken returns the hex-encoded SHA-256 hash under which a bearer token is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,202 @@
+// Package services provides DPoP proof verification and nonce management.
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// DPoPProofLifetime is how far the "iat" of a DPoP proof may lie in the past.
+// Proof identifiers are remembered for this long to detect replays.
+const DPoPProofLifetime = 5 * time.Minute
+
+// DPoPNonceLifetime is how long a server-issued DPoP nonce is accepted.
+const DPoPNonceLifetime = 5 * time.Minute
+
+// dpopClockSkew is how far the "iat" of a DPoP proof may lie in the future.
+const dpopClockSkew = time.Minute
+
+// ErrDPoPNonceRequired is returned when a DPoP proof lacks a valid server-issued nonce.
+// Clients must retry with the nonce sent in the DPoP-Nonce response header.
+var ErrDPoPNonceRequired = errors.New("a valid DPoP nonce is required")
+
+// DPoPService verifies DPoP proofs (RFC 9449) presented by clients to bind access tokens
+// to a key they hold. Proof identifiers and server nonces are tracked in Redis.
+type DPoPService struct {
+	redis        *redis.Client // Redis client for replay detection and nonces
+	requireNonce bool          // Whether every proof must carry a server-issued nonce
+}
+
+// DPoPProofRequest describes the HTTP request a DPoP proof must be bound to.
+type DPoPProofRequest struct {
+	Method      string // HTTP method of the request (htm)
+	URL         string // Request URL without query and fragment (htu)
+	AccessToken string // Access token presented with the proof, empty at the token endpoint (ath)
+}
+
+// NewDPoPService creates a new DPoPService instance backed by Redis.
+//
+// Parameters:
+//   - redis: Redis client for replay detection and nonce storage
+//   - requireNonce: Whether proofs must include a nonce issued by this server
+//
+// Returns:
+//   - *DPoPService: Configured DPoP service instance
+func NewDPoPService(redis *redis.Client, requireNonce bool) *DPoPService {
+	return &DPoPService{
+		redis:        redis,
+		requireNonce: requireNonce,
+	}
+}
+
+// VerifyProof verifies a DPoP proof JWT and returns the JWK thumbprint of its key,
+// which is used as the "jkt" confirmation of bound access tokens.
+//
+// The proof must:
+//   - have typ "dpop+jwt", an asymmetric algorithm and a public "jwk" header
+//   - be signed by that key
+//   - match the method, URL and (when given) access token hash of the request
+//   - be recent and not replayed
+//   - carry a server nonce when nonces are required
+//
+// Parameters:
+//   - proof: The value of the DPoP request header
+//   - req: The request the proof must be bound to
+//
+// Returns:
+//   - string: The JWK SHA-256 thumbprint of the proof key
+//   - error: ErrDPoPNonceRequired if a nonce is missing or stale, otherwise a validation error
+//
+// Example:
+//
+//	jkt, err := dpopService.VerifyProof(c.GetHeader("DPoP"), services.DPoPProofRequest{Method: "POST", URL: "https://auth.example.com/token"})
+func (s *DPoPService) VerifyProof(proof string, req DPoPProofRequest) (string, error) {
+	var jwk *JWK
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(proof, claims, func(token *jwt.Token) (any, error) {
+		if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, fmt.Errorf("DPoP proof must have typ dpop+jwt")
+		}
+
+		// 解析头部中的公钥，禁止携带私钥
+		header, ok := token.Header["jwk"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("DPoP proof must include a jwk header")
+		}
+		if _, ok := header["d"]; ok {
+			return nil, fmt.Errorf("DPoP proof jwk must not contain a private key")
+		}
+
+		encoded, err := json.Marshal(header)
+		if err != nil {
+			return nil, err
+		}
+		jwk = &JWK{}
+		if err := json.Unmarshal(encoded, jwk); err != nil {
+			return nil, fmt.Errorf("invalid DPoP proof jwk: %w", err)
+		}
+
+		return jwk.PublicKey()
+	}, jwt.WithValidMethods(ClientJWTSigningAlgorithms))
+	if err != nil {
+		return "", fmt.Errorf("invalid DPoP proof: %w", err)
+	}
+
+	// 验证请求绑定
+	if htm, _ := claims["htm"].(string); htm != req.Method {
+		return "", fmt.Errorf("DPoP proof htm does not match the request method")
+	}
+	if htu, _ := claims["htu"].(string); htu != req.URL {
+		return "", fmt.Errorf("DPoP proof htu does not match the request URL")
+	}
+	if req.AccessToken != "" {
+		if ath, _ := claims["ath"].(string); ath != AccessTokenHash(req.AccessToken) {
+			return "", fmt.Errorf("DPoP proof ath does not match the access token")
+		}
+	}
+
+	// 验证签发时间
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return "", fmt.Errorf("DPoP proof must include iat")
+	}
+	now := time.Now()
+	if iat.Before(now.Add(-DPoPProofLifetime)) || iat.After(now.Add(dpopClockSkew)) {
+		return "", fmt.Errorf("DPoP proof iat is outside the acceptable window")
+	}
+
+	// 验证服务器下发的nonce
+	nonce, _ := claims["nonce"].(string)
+	if nonce != "" || s.requireNonce {
+		if nonce == "" || !s.validNonce(nonce) {
+			return "", ErrDPoPNonceRequired
+		}
+	}
+
+	jkt, err := jwk.Thumbprint()
+	if err != nil {
+		return "", err
+	}
+
+	// 防止重放
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", fmt.Errorf("DPoP proof must include jti")
+	}
+	fresh, err := s.redis.SetNX(context.Background(), dpopJTIKey(jkt, jti), "1", DPoPProofLifetime+dpopClockSkew).Result()
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", fmt.Errorf("DPoP proof has already been used")
+	}
+
+	return jkt, nil
+}
+
+// IssueNonce creates a new server nonce for clients to include in their next DPoP proofs.
+//
+// Returns:
+//   - string: The nonce to send in the DPoP-Nonce response header
+//   - error: An error if Redis operations fail
+func (s *DPoPService) IssueNonce() (string, error) {
+	nonce := generateRandomString(32)
+	err := s.redis.Set(context.Background(), dpopNonceKey(nonce), "1", DPoPNonceLifetime).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return nonce, nil
+}
+
+// validNonce reports whether the nonce was issued by this server and has not expired.
+func (s *DPoPService) validNonce(nonce string) bool {
+	n, err := s.redis.Exists(context.Background(), dpopNonceKey(nonce)).Result()
+	return err == nil && n == 1
+}
+
+// AccessTokenHash returns the base64url-encoded SHA-256 hash of an access token,
+// as carried in the "ath" claim of DPoP proofs.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// dpopJTIKey returns the Redis key remembering a used DPoP proof.
+func dpopJTIKey(jkt, jti string) string {
+	sum := sha256.Sum256([]byte(jti))
+	return fmt.Sprintf("dpop_jti:%s:%s", jkt, base64.RawURLEncoding.EncodeToString(sum[:]))
+}
+
+// dpopNonceKey returns the Redis key of a server-issued DPoP nonce.
+func dpopNonceKey(nonce string) string {
+	return fmt.Sprintf("dpop_nonce:%s", nonce)
+}
@@ -0,0 +1,198 @@
+// Package services provides JSON Web Encryption (RFC 7516) of tokens issued to clients.
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JWE algorithms supported for encrypting tokens to clients (RFC 7518 Sections 4 and 5).
+const (
+	JWEAlgRSAOAEP256 = "RSA-OAEP-256" // RSAES-OAEP with SHA-256 key encryption
+	JWEAlgECDHES     = "ECDH-ES"      // Direct key agreement with ephemeral-static ECDH
+	JWEEncA256GCM    = "A256GCM"      // AES-256 GCM content encryption
+)
+
+// JWEKeyManagementAlgorithms lists the "alg" values clients can register for encrypted tokens.
+var JWEKeyManagementAlgorithms = []string{JWEAlgRSAOAEP256, JWEAlgECDHES}
+
+// JWEContentEncryptionAlgorithms lists the "enc" values clients can register for encrypted tokens.
+var JWEContentEncryptionAlgorithms = []string{JWEEncA256GCM}
+
+// JWEEncryption describes how a token is encrypted to a client: the client's public key and
+// the key management and content encryption algorithms it registered.
+type JWEEncryption struct {
+	Key *JWK   // Recipient public key
+	Alg string // Key management algorithm (RSA-OAEP-256, ECDH-ES)
+	Enc string // Content encryption algorithm (A256GCM)
+}
+
+// EncryptJWT encrypts a signed JWT to a client, producing a nested JWT (RFC 7519 Section 5.2)
+// in JWE compact serialization with the "cty" header set to "JWT".
+//
+// Parameters:
+//   - signed: The compact serialized signed JWT
+//   - encryption: The recipient key and algorithms
+//
+// Returns:
+//   - string: The compact serialized JWE
+//   - error: An error if the algorithms are unsupported or do not match the key
+//
+// Example:
+//
+//	encrypted, err := services.EncryptJWT(idToken, &services.JWEEncryption{Key: key, Alg: services.JWEAlgRSAOAEP256, Enc: services.JWEEncA256GCM})
+func EncryptJWT(signed string, encryption *JWEEncryption) (string, error) {
+	if encryption.Enc != JWEEncA256GCM {
+		return "", fmt.Errorf("unsupported content encryption algorithm %q", encryption.Enc)
+	}
+
+	header := map[string]any{
+		"alg": encryption.Alg,
+		"enc": encryption.Enc,
+		"cty": "JWT",
+	}
+	if encryption.Key.Kid != "" {
+		header["kid"] = encryption.Key.Kid
+	}
+
+	// 生成内容加密密钥
+	var cek, encryptedKey []byte
+	publicKey, err := encryption.Key.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	switch encryption.Alg {
+	case JWEAlgRSAOAEP256:
+		rsaKey, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires an RSA key", encryption.Alg)
+		}
+		cek = make([]byte, 32)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, cek, nil); err != nil {
+			return "", err
+		}
+	case JWEAlgECDHES:
+		ecKey, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires an EC key", encryption.Alg)
+		}
+		var epk *JWK
+		if cek, epk, err = ecdhKeyAgreement(ecKey, encryption.Key.Crv, encryption.Enc, 256); err != nil {
+			return "", err
+		}
+		header["epk"] = epk
+	default:
+		return "", fmt.Errorf("unsupported key management algorithm %q", encryption.Alg)
+	}
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(encodedHeader)
+
+	// 使用AES-GCM加密，受保护头部作为附加认证数据
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(signed), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// ecdhKeyAgreement derives the content encryption key of an ECDH-ES direct key agreement
+// (RFC 7518 Section 4.6) with a fresh ephemeral key, returned as the "epk" header.
+func ecdhKeyAgreement(recipient *ecdsa.PublicKey, crv, enc string, keyBits int) ([]byte, *JWK, error) {
+	recipientKey, err := recipient.ECDH()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeral, err := recipientKey.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 非压缩格式公钥：0x04 || X || Y
+	point := ephemeral.PublicKey().Bytes()
+	size := (len(point) - 1) / 2
+	epk := &JWK{
+		Kty: "EC",
+		Crv: crv,
+		X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+	}
+
+	return concatKDF(z, enc, nil, nil, keyBits), epk, nil
+}
+
+// concatKDF implements the Concat KDF with SHA-256 (NIST SP 800-56A Section 5.8.1) as used by
+// ECDH-ES (RFC 7518 Section 4.6.2).
+func concatKDF(z []byte, algorithmID string, partyUInfo, partyVInfo []byte, keyBits int) []byte {
+	var otherInfo []byte
+	for _, field := range [][]byte{[]byte(algorithmID), partyUInfo, partyVInfo} {
+		otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(field)))
+		otherInfo = append(otherInfo, field...)
+	}
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(keyBits))
+
+	var key []byte
+	for counter := uint32(1); len(key) < keyBits/8; counter++ {
+		h := sha256.New()
+		h.Write(binary.BigEndian.AppendUint32(nil, counter))
+		h.Write(z)
+		h.Write(otherInfo)
+		key = h.Sum(key)
+	}
+	return key[:keyBits/8]
+}
+
+// FindEncryptionKey returns the first key of the set usable for encryption with the given key
+// management algorithm: an "enc" key (or one without "use") of the matching key type whose
+// "alg", if set, is the algorithm.
+func (s *JWKSet) FindEncryptionKey(alg string) (*JWK, error) {
+	kty := "RSA"
+	if alg == JWEAlgECDHES {
+		kty = "EC"
+	}
+
+	for i := range s.Keys {
+		key := &s.Keys[i]
+		if (key.Use == "" || key.Use == "enc") && key.Kty == kty && (key.Alg == "" || key.Alg == alg) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s encryption key found in JWKS", kty)
+}
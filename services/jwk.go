@@ -0,0 +1,264 @@
+// Package services provides JSON Web Key (RFC 7517) helpers.
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRemoteDocumentSize limits the size of JWKS and request object documents fetched from clients.
+const maxRemoteDocumentSize = 64 * 1024
+
+// remoteHTTPClient is used to fetch documents published by clients (jwks_uri, request_uri,
+// sector_identifier_uri). It only connects to public addresses, so clients cannot make the
+// server reach internal services; the check runs on the resolved address of every connection,
+// including those of redirects.
+var remoteHTTPClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return fmt.Errorf("refusing to connect to non-public address %s", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// clientEndpointHTTPClient is used to send notifications to client endpoints
+// (backchannel_logout_uri, backchannel_client_notification_endpoint). It shares the transport of
+// remoteHTTPClient, so it only connects to public addresses, and does not follow redirects.
+var clientEndpointHTTPClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: remoteHTTPClient.Transport,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ValidateClientEndpoint checks an endpoint submitted for registration that the server sends
+// requests to, such as backchannel_logout_uri. It must be an https URL whose host is not
+// localhost or a non-public IP address. Host names are checked again when connecting, since
+// they may resolve to internal addresses.
+//
+// Parameters:
+//   - endpoint: The endpoint URL
+//
+// Returns:
+//   - error: Why the endpoint cannot be registered, or nil
+//
+// Example:
+//
+//	err := services.ValidateClientEndpoint("https://client.example.com/backchannel-logout")
+func ValidateClientEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint %q must be an https URL", endpoint)
+	}
+	if u.Fragment != "" || strings.Contains(endpoint, "#") {
+		return fmt.Errorf("endpoint %q must not contain a fragment", endpoint)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); (ip != nil && !isPublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("endpoint %q must use a public host", endpoint)
+	}
+	return nil
+}
+
+// isPublicIP reports whether an IP address is reachable on the internet, i.e. not a loopback,
+// private, link-local, multicast or unspecified address.
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// JWK represents a single JSON Web Key (RFC 7517).
+// Only the members needed for RSA and EC public keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`           // Key type (RSA, EC)
+	Kid string `json:"kid,omitempty"` // Key identifier
+	Use string `json:"use,omitempty"` // Intended use (sig, enc)
+	Alg string `json:"alg,omitempty"` // Intended algorithm
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA public exponent
+	Crv string `json:"crv,omitempty"` // EC curve (P-256, P-384, P-521)
+	X   string `json:"x,omitempty"`   // EC x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+
+	X5C []string `json:"x5c,omitempty"` // X.509 certificate chain, base64 DER (used for self-signed client certificates)
+}
+
+// JWKSet represents a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Find returns the key with the given key ID and use. When kid is empty the set
+// must contain exactly one key of that use. An empty use matches any key.
+func (s *JWKSet) Find(kid, use string) (*JWK, error) {
+	var match *JWK
+	for i := range s.Keys {
+		key := &s.Keys[i]
+		if use != "" && key.Use != "" && key.Use != use {
+			continue
+		}
+		if kid != "" && key.Kid == kid {
+			return key, nil
+		}
+		if kid == "" {
+			if match != nil {
+				return nil, fmt.Errorf("multiple keys in JWKS, kid is required")
+			}
+			match = key
+		}
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("key %q not found in JWKS", kid)
+	}
+	return match, nil
+}
+
+// PublicKey converts the JWK to a Go public key (*rsa.PublicKey or *ecdsa.PublicKey).
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// Thumbprint computes the base64url-encoded SHA-256 JWK thumbprint (RFC 7638),
+// which identifies the key independently of optional members such as kid or use.
+func (k *JWK) Thumbprint() (string, error) {
+	var members string
+	switch k.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ellipticCurve returns the curve for a JWK "crv" value.
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported EC curve %q", crv)
+}
+
+// FetchJWKS downloads and parses the JSON Web Key Set published at a client's jwks_uri.
+//
+// Parameters:
+//   - jwksURI: The HTTPS URL of the key set
+//
+// Returns:
+//   - *JWKSet: The parsed key set
+//   - error: An error if the URL is not HTTPS, the download fails or the document is invalid
+//
+// Example:
+//
+//	keySet, err := services.FetchJWKS("https://client.example.com/jwks.json")
+func FetchJWKS(jwksURI string) (*JWKSet, error) {
+	body, err := fetchRemoteDocument(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := &JWKSet{}
+	if err := json.Unmarshal(body, keySet); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	return keySet, nil
+}
+
+// fetchRemoteDocument downloads a small document published by a client over HTTPS.
+func fetchRemoteDocument(uri string) ([]byte, error) {
+	if !strings.HasPrefix(uri, "https://") {
+		return nil, fmt.Errorf("remote documents must be served over https")
+	}
+
+	resp, err := remoteHTTPClient.Get(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", uri, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", uri, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRemoteDocumentSize {
+		return nil, fmt.Errorf("document at %s is too large", uri)
+	}
+
+	return body, nil
+}
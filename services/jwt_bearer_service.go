@@ -0,0 +1,221 @@
+// Package services provides verification of JWT bearer authorization grants (RFC 7523).
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flash-oauth2/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxAssertionLifetime is the longest validity accepted for a JWT bearer assertion.
+// Assertions are meant to be minted per request, so a short limit bounds replay storage.
+const MaxAssertionLifetime = 10 * time.Minute
+
+// assertionClockSkew is the clock skew tolerated when checking assertion timestamps.
+const assertionClockSkew = time.Minute
+
+// JWTAssertion holds the verified content of a JWT bearer assertion.
+type JWTAssertion struct {
+	App     *models.ExternalApp // Application whose key signed the assertion
+	Subject string              // The asserted user ("sub" claim)
+	KeyID   string              // Key pair the assertion was signed with
+}
+
+// JWTBearerService verifies JWT bearer assertions (RFC 7523 Section 2.1) signed with the
+// key pairs issued to external applications. Each assertion can be used only once.
+type JWTBearerService struct {
+	appService *AppManagementService // Access to applications and their key pairs
+	redis      *redis.Client         // Redis client for assertion replay detection
+}
+
+// NewJWTBearerService creates a new JWTBearerService instance.
+//
+// Parameters:
+//   - appService: Application management service used to look up key pairs
+//   - redis: Redis client for replay detection
+//
+// Returns:
+//   - *JWTBearerService: Configured JWT bearer service instance
+func NewJWTBearerService(appService *AppManagementService, redis *redis.Client) *JWTBearerService {
+	return &JWTBearerService{
+		appService: appService,
+		redis:      redis,
+	}
+}
+
+// VerifyAssertion verifies a JWT bearer assertion presented by a client (RFC 7523 Section 3).
+// The assertion must be signed with an active, unexpired key pair of the application linked
+// to the client, be issued by the client, be addressed to one of the given audiences, be
+// short-lived and not replayed, and assert a subject the application is allowed to assert.
+//
+// Parameters:
+//   - client: The authenticated client presenting the assertion
+//   - assertion: The compact serialized JWT
+//   - audiences: Accepted "aud" values (the issuer and the token endpoint URL)
+//
+// Returns:
+//   - *JWTAssertion: The verified application and subject
+//   - error: An error if the assertion is invalid
+//
+// Example:
+//
+//	verified, err := jwtBearerService.VerifyAssertion(client, assertion, []string{issuer, tokenEndpoint})
+func (s *JWTBearerService) VerifyAssertion(client *models.OAuthClient, assertion string, audiences []string) (*JWTAssertion, error) {
+	if client.AppID == "" {
+		return nil, fmt.Errorf("client is not linked to an application")
+	}
+
+	var keyPair *models.AppKeyPair
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("assertion must include a kid header")
+		}
+
+		// 拒绝未知、已吊销或已过期的密钥
+		kp, err := s.appService.GetActiveKeyPair(kid)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unknown key %s", kid)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kp.AppID != client.AppID {
+			return nil, fmt.Errorf("key %s does not belong to client %s", kid, client.ID)
+		}
+		if kp.Algorithm != token.Method.Alg() {
+			return nil, fmt.Errorf("key %s must be used with %s", kid, kp.Algorithm)
+		}
+
+		keyPair = kp
+		return jwt.ParseRSAPublicKeyFromPEM([]byte(kp.PublicKey))
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(client.ID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(assertionClockSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验受众
+	aud, _ := claims.GetAudience()
+	if !audienceMatches(aud, audiences) {
+		return nil, fmt.Errorf("assertion audience must be the authorization server")
+	}
+
+	// 限制断言有效期
+	exp, _ := claims.GetExpirationTime()
+	if time.Until(exp.Time) > MaxAssertionLifetime+assertionClockSkew {
+		return nil, fmt.Errorf("assertion lifetime exceeds %s", MaxAssertionLifetime)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("assertion must include a sub claim")
+	}
+
+	app, err := s.appService.GetApp(keyPair.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if app.Status != "active" {
+		return nil, fmt.Errorf("application is %s", app.Status)
+	}
+	if !containsSubject(app.AssertionSubjects, subject) {
+		return nil, fmt.Errorf("application is not allowed to assert subject %s", subject)
+	}
+
+	// 防止断言重放
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, fmt.Errorf("assertion must include a jti claim")
+	}
+	fresh, err := s.redis.SetNX(context.Background(), assertionJTIKey(client.ID, jti), "1", time.Until(exp.Time)+assertionClockSkew).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fmt.Errorf("assertion has already been used")
+	}
+
+	s.appService.UpdateKeyLastUsed(keyPair.KeyID)
+
+	return &JWTAssertion{
+		App:     app,
+		Subject: subject,
+		KeyID:   keyPair.KeyID,
+	}, nil
+}
+
+// AllowedScope returns the scope granted for an assertion: the requested scope, or all
+// scopes of the application when none is requested. Every scope must be allowed for the app.
+//
+// Parameters:
+//   - app: The application that signed the assertion
+//   - requested: The requested scope (space-separated), may be empty
+//
+// Returns:
+//   - string: The granted scope
+//   - error: An error if a requested scope is not allowed for the app
+func (s *JWTBearerService) AllowedScope(app *models.ExternalApp, requested string) (string, error) {
+	allowed := strings.Fields(app.Scopes)
+	if requested == "" {
+		return strings.Join(allowed, " "), nil
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		found := false
+		for _, a := range allowed {
+			if a == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("application is not allowed to assert scope %q", scope)
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+// audienceMatches reports whether any of the assertion audiences is accepted.
+func audienceMatches(aud, accepted []string) bool {
+	for _, a := range aud {
+		for _, b := range accepted {
+			if strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsSubject reports whether the policy allows the subject; "*" allows any subject.
+func containsSubject(subjects []string, subject string) bool {
+	for _, s := range subjects {
+		if s == subject || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// assertionJTIKey returns the Redis key marking an assertion jti as used.
+func assertionJTIKey(clientID, jti string) string {
+	sum := sha256.Sum256([]byte(jti))
+	return fmt.Sprintf("jwt_bearer_jti:%s:%s", clientID, hex.EncodeToString(sum[:]))
+}
@@ -7,11 +7,25 @@ import (
 	"crypto/rsa"
 	"flash-oauth2/models"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// SigningKeyID is the key identifier ("kid") of the server signing key published in the JWKS.
+const SigningKeyID = "default"
+
+// AccessTokenType is the "typ" header of JWT access tokens (RFC 9068 Section 2.1).
+const AccessTokenType = "at+jwt"
+
+// IntrospectionResponseType is the "typ" header of JWT introspection responses (RFC 9701 Section 5).
+const IntrospectionResponseType = "token-introspection+jwt"
+
+// LogoutTokenType is the "typ" header of logout tokens (OpenID Connect Back-Channel Logout 1.0 Section 2.4).
+const LogoutTokenType = "logout+jwt"
+
 // JWTService provides JWT token generation and validation using RSA asymmetric encryption.
 // It supports creating OAuth2 access tokens and OpenID Connect ID tokens with
 // proper claims and cryptographic signatures.
@@ -39,12 +53,17 @@ func NewJWTService(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey, issuer
 }
 
 // GenerateAccessToken creates a signed JWT access token for OAuth2 authentication.
-// The token includes user ID, client ID, scope, and standard JWT claims with 1-hour expiration.
+// The token follows the JWT access token profile (RFC 9068): the caller sets the subject,
+// client, scope and the optional claims of the grant, and the standard claims are filled in.
+// The audience defaults to the client; tokens for a protected resource (RFC 8707) or issued by
+// a token exchange (RFC 8693) name that resource or service instead. When a confirmation is
+// given the token is sender-constrained through its "cnf" claim.
 //
 // Parameters:
-//   - userID: The authenticated user's unique identifier
-//   - clientID: The OAuth2 client that requested the token
-//   - scope: The granted OAuth2 scopes (space-separated)
+//   - claims: The token's subject, client_id, scope and optional aud, cnf, act, userinfo_claims
+//     and authorization_details claims
+//   - authn: When and how the user authenticated, or nil if the user did not log in interactively
+//   - lifetime: How long the token is valid
 //
 // Returns:
 //   - string: The signed JWT access token
@@ -52,30 +71,64 @@ func NewJWTService(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey, issuer
 //
 // Example:
 //
-//	token, err := jwtService.GenerateAccessToken(123, "my-app", "openid profile")
-func (s *JWTService) GenerateAccessToken(userID int, clientID, scope string) (string, error) {
+//	token, err := jwtService.GenerateAccessToken(&models.AccessTokenClaims{
+//		Subject:  "123",
+//		ClientID: "my-app",
+//		Scope:    "openid profile",
+//	}, &authCode.Authentication, time.Hour)
+func (s *JWTService) GenerateAccessToken(claims *models.AccessTokenClaims, authn *models.UserAuthentication, lifetime time.Duration) (string, error) {
+	if claims.Aud == "" {
+		claims.Aud = claims.ClientID
+	}
+	return s.signAccessToken(claims, authn, lifetime)
+}
+
+// signAccessToken fills in the standard claims of an access token with the given lifetime and signs it
+// as a JWT access token (RFC 9068): the header carries the "at+jwt" type and the signing key ID,
+// and the claims include a random "jti" and, for interactive logins, "auth_time" and "acr".
+func (s *JWTService) signAccessToken(claims *models.AccessTokenClaims, authn *models.UserAuthentication, lifetime time.Duration) (string, error) {
 	now := time.Now()
-	claims := &models.AccessTokenClaims{
-		UserID:   userID,
-		ClientID: clientID,
-		Scope:    scope,
-		Exp:      now.Add(1 * time.Hour).Unix(),
-		Iat:      now.Unix(),
-		Iss:      s.issuer,
-		Aud:      clientID,
+	claims.Exp = now.Add(lifetime).Unix()
+	claims.Iat = now.Unix()
+	claims.Iss = s.issuer
+	claims.JTI = uuid.New().String()
+	if authn != nil && !authn.Time.IsZero() {
+		claims.AuthTime = authn.Time.Unix()
+		claims.ACR = authn.ACR
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"sub":        claims.UserID,
-		"client_id":  claims.ClientID,
-		"scope":      claims.Scope,
-		"exp":        claims.Exp,
-		"iat":        claims.Iat,
-		"iss":        claims.Iss,
-		"aud":        claims.Aud,
-		"token_type": "access_token",
-		"jti":        fmt.Sprintf("%d-%d", now.UnixNano(), userID), // Add unique JWT ID
-	})
+	mapClaims := jwt.MapClaims{
+		"sub":       claims.Subject,
+		"client_id": claims.ClientID,
+		"scope":     claims.Scope,
+		"exp":       claims.Exp,
+		"iat":       claims.Iat,
+		"iss":       claims.Iss,
+		"aud":       claims.Aud,
+		"jti":       claims.JTI,
+	}
+	if claims.AuthTime != 0 {
+		mapClaims["auth_time"] = claims.AuthTime
+	}
+	if claims.ACR != "" {
+		mapClaims["acr"] = claims.ACR
+	}
+	if claims.Confirmation != nil {
+		mapClaims["cnf"] = claims.Confirmation
+	}
+	if claims.Actor != nil {
+		mapClaims["act"] = claims.Actor
+	}
+	if len(claims.UserInfoClaims) > 0 {
+		mapClaims["userinfo_claims"] = claims.UserInfoClaims
+	}
+	if len(claims.AuthorizationDetails) > 0 {
+		mapClaims["authorization_details"] = claims.AuthorizationDetails
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["typ"] = AccessTokenType
+	token.Header["kid"] = SigningKeyID
 
 	return token.SignedString(s.privateKey)
 }
@@ -84,38 +137,253 @@ func (s *JWTService) GenerateAccessToken(userID int, clientID, scope string) (st
 // ID tokens contain user identity information and are used by clients to verify user authentication.
 //
 // Parameters:
-//   - user: The authenticated user object containing identity information
+//   - subject: The user's subject identifier for the client (see SubjectService)
 //   - clientID: The OAuth2 client that requested the token
+//   - lifetime: How long the token is valid
+//   - authn: When and how the user authenticated, and in which SSO session ("auth_time", "acr" and "sid" claims)
+//   - userClaims: The user claims released by the granted scopes (see UserClaims)
+//   - encryption: The client's encryption key and algorithms, or nil for a signed-only ID token
 //
 // Returns:
-//   - string: The signed JWT ID token
-//   - error: An error if token generation or signing fails
+//   - string: The signed JWT ID token, or the signed-then-encrypted nested JWT when encryption is given
+//   - error: An error if token generation, signing or encryption fails
 //
 // Example:
 //
-//	idToken, err := jwtService.GenerateIDToken(user, "my-app")
-func (s *JWTService) GenerateIDToken(user *models.User, clientID string) (string, error) {
+//	idToken, err := jwtService.GenerateIDToken("123", "my-app", time.Hour, &authCode.Authentication, services.UserClaims(user, scopes, nil), nil)
+func (s *JWTService) GenerateIDToken(subject string, clientID string, lifetime time.Duration, authn *models.UserAuthentication, userClaims map[string]any, encryption *JWEEncryption) (string, error) {
 	now := time.Now()
 	claims := &models.IDTokenClaims{
-		UserID:   user.ID,
-		Phone:    user.Phone,
-		Exp:      now.Add(1 * time.Hour).Unix(),
+		Subject:  subject,
+		Exp:      now.Add(lifetime).Unix(),
 		Iat:      now.Unix(),
 		Iss:      s.issuer,
 		Aud:      clientID,
 		AuthTime: now.Unix(),
 	}
+	if authn != nil {
+		if !authn.Time.IsZero() {
+			claims.AuthTime = authn.Time.Unix()
+		}
+		claims.ACR = authn.ACR
+		claims.SessionID = SessionSID(authn.SessionID)
+	}
+
+	mapClaims := jwt.MapClaims{}
+	for name, value := range userClaims {
+		mapClaims[name] = value
+	}
+
+	// 注册声明优先于用户声明
+	mapClaims["sub"] = claims.Subject
+	mapClaims["exp"] = claims.Exp
+	mapClaims["iat"] = claims.Iat
+	mapClaims["iss"] = claims.Iss
+	mapClaims["aud"] = claims.Aud
+	mapClaims["auth_time"] = claims.AuthTime
+	mapClaims["token_type"] = "id_token"
+	if claims.ACR != "" {
+		mapClaims["acr"] = claims.ACR
+	}
+	if claims.SessionID != "" {
+		mapClaims["sid"] = claims.SessionID
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["kid"] = SigningKeyID
+
+	signed, err := token.SignedString(s.privateKey)
+	if err != nil || encryption == nil {
+		return signed, err
+	}
+
+	// 先签名后加密（嵌套JWT）
+	return EncryptJWT(signed, encryption)
+}
+
+// ParseIDTokenHint validates an ID token this server issued that a client presents as a hint
+// about the user, such as the id_token_hint of a logout request. The signature and issuer are
+// checked, but expired tokens are accepted since they still identify the user.
+//
+// Parameters:
+//   - tokenString: The signed ID token
+//
+// Returns:
+//   - *models.IDTokenClaims: The claims of the ID token
+//   - error: An error if the token is not a valid ID token issued by this server
+//
+// Example:
+//
+//	claims, err := jwtService.ParseIDTokenHint(c.Query("id_token_hint"))
+func (s *JWTService) ParseIDTokenHint(tokenString string) (*models.IDTokenClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return s.publicKey, nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	// 仅接受本服务器签发的ID令牌
+	tokenType, _ := claims["token_type"].(string)
+	iss, _ := claims["iss"].(string)
+	if tokenType != "id_token" || iss != s.issuer {
+		return nil, fmt.Errorf("%w: not an ID token issued by this server", jwt.ErrTokenInvalidClaims)
+	}
+
+	sub, _ := claims["sub"].(string)
+	aud, _ := claims["aud"].(string)
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+	authTime, _ := claims["auth_time"].(float64)
+	sid, _ := claims["sid"].(string)
+	if sub == "" || aud == "" {
+		return nil, fmt.Errorf("%w: missing required ID token claims", jwt.ErrTokenInvalidClaims)
+	}
+
+	return &models.IDTokenClaims{
+		Subject:  sub,
+		Exp:      int64(exp),
+		Iat:      int64(iat),
+		Iss:      iss,
+		Aud:      aud,
+		AuthTime: int64(authTime),
+
+		SessionID: sid,
+	}, nil
+}
+
+// GenerateLogoutToken creates a signed logout token telling a client that the user's session
+// ended (OpenID Connect Back-Channel Logout 1.0 Section 2.4). The token carries the
+// back-channel logout event, the user's subject for the client and the session's "sid",
+// never a nonce, and has the "logout+jwt" type so it cannot be mistaken for an ID token.
+//
+// Parameters:
+//   - subject: The user's subject identifier for the client (see SubjectService)
+//   - clientID: The client the token is sent to
+//   - sid: The "sid" of the ended session (see SessionSID)
+//
+// Returns:
+//   - string: The signed logout token, valid for 2 minutes
+//   - error: An error if signing fails
+//
+// Example:
+//
+//	logoutToken, err := jwtService.GenerateLogoutToken("123", "my-app", services.SessionSID(session.ID))
+func (s *JWTService) GenerateLogoutToken(subject, clientID, sid string) (string, error) {
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"iss":    s.issuer,
+		"aud":    clientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    uuid.New().String(),
+		"sub":    subject,
+		"sid":    sid,
+		"events": map[string]any{BackchannelLogoutEvent: map[string]any{}},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["typ"] = LogoutTokenType
+	token.Header["kid"] = SigningKeyID
+
+	return token.SignedString(s.privateKey)
+}
+
+// GenerateAuthorizationResponse creates a signed JWT carrying authorization response parameters
+// for the JWT Secured Authorization Response Mode (JARM).
+// The response is addressed to the client and expires after 10 minutes.
+//
+// Parameters:
+//   - clientID: The OAuth2 client receiving the response (used as audience)
+//   - params: The authorization response parameters (code, state, error, error_description)
+//
+// Returns:
+//   - string: The signed JWT authorization response
+//   - error: An error if token signing fails
+//
+// Example:
+//
+//	response, err := jwtService.GenerateAuthorizationResponse("my-app", map[string]string{"code": "abc123", "state": "xyz"})
+func (s *JWTService) GenerateAuthorizationResponse(clientID string, params map[string]string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.issuer,
+		"aud": clientID,
+		"exp": now.Add(10 * time.Minute).Unix(),
+		"iat": now.Unix(),
+	}
+	for name, value := range params {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = SigningKeyID
+
+	return token.SignedString(s.privateKey)
+}
+
+// GenerateUserInfoResponse creates a signed UserInfo response (OpenID Connect Core 1.0 Section 5.3.2)
+// for clients that registered userinfo_signed_response_alg. The JWT carries the user claims and
+// is addressed to the client.
+//
+// Parameters:
+//   - clientID: The client that requested the user claims (used as audience)
+//   - userClaims: The user claims of the response
+//
+// Returns:
+//   - string: The signed UserInfo response
+//   - error: An error if token signing fails
+//
+// Example:
+//
+//	response, err := jwtService.GenerateUserInfoResponse("my-app", map[string]any{"sub": "123", "name": "张三"})
+func (s *JWTService) GenerateUserInfoResponse(clientID string, userClaims map[string]any) (string, error) {
+	claims := jwt.MapClaims{}
+	for name, value := range userClaims {
+		claims[name] = value
+	}
+	claims["iss"] = s.issuer
+	claims["aud"] = clientID
+	claims["iat"] = time.Now().Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = SigningKeyID
 
+	return token.SignedString(s.privateKey)
+}
+
+// GenerateIntrospectionResponse creates a signed token introspection response (RFC 9701).
+// The introspection result is carried in the "token_introspection" claim and the JWT is
+// addressed to the caller of the introspection endpoint.
+//
+// Parameters:
+//   - audience: The authenticated client or resource server that requested the introspection
+//   - introspection: The introspection result, {"active": false} for inactive tokens
+//
+// Returns:
+//   - string: The signed JWT introspection response
+//   - error: An error if token signing fails
+//
+// Example:
+//
+//	response, err := jwtService.GenerateIntrospectionResponse("https://orders.example.com", map[string]any{"active": true, "sub": "123"})
+func (s *JWTService) GenerateIntrospectionResponse(audience string, introspection map[string]any) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"sub":        claims.UserID,
-		"phone":      claims.Phone,
-		"exp":        claims.Exp,
-		"iat":        claims.Iat,
-		"iss":        claims.Iss,
-		"aud":        claims.Aud,
-		"auth_time":  claims.AuthTime,
-		"token_type": "id_token",
+		"iss":                 s.issuer,
+		"aud":                 audience,
+		"iat":                 time.Now().Unix(),
+		"token_introspection": introspection,
 	})
+	token.Header["typ"] = IntrospectionResponseType
+	token.Header["kid"] = SigningKeyID
 
 	return token.SignedString(s.privateKey)
 }
@@ -145,6 +413,10 @@ func (s *JWTService) ValidateToken(tokenString string) (*jwt.Token, error) {
 
 // ParseAccessToken validates and parses an access token, extracting its claims.
 // This method is used to authenticate API requests and extract user/client information.
+// Tokens must follow the JWT access token profile (RFC 9068 Section 4): the "at+jwt" type,
+// this server as issuer, and the required "sub", "client_id", "aud", "exp", "iat" and "jti" claims.
+// The subject is returned as issued to the client; resolving it to a user is left to the caller
+// (see SubjectService.ResolveSubject).
 //
 // Parameters:
 //   - tokenString: The JWT access token string to parse
@@ -166,29 +438,93 @@ func (s *JWTService) ParseAccessToken(tokenString string) (*models.AccessTokenCl
 		return nil, jwt.ErrTokenInvalidClaims
 	}
 
+	// 检查令牌类型（RFC 9068 Section 4）
+	typ, _ := token.Header["typ"].(string)
+	if !strings.EqualFold(strings.TrimPrefix(typ, "application/"), AccessTokenType) {
+		return nil, fmt.Errorf("%w: not a JWT access token", jwt.ErrTokenInvalidClaims)
+	}
+
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
 
-	// 检查token类型
-	if tokenType, ok := claims["token_type"].(string); !ok || tokenType != "access_token" {
-		return nil, jwt.ErrTokenInvalidClaims
+	// 检查必需声明
+	sub, _ := claims["sub"].(string)
+	clientID, _ := claims["client_id"].(string)
+	iss, _ := claims["iss"].(string)
+	aud, _ := claims["aud"].(string)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+	if sub == "" || clientID == "" || aud == "" || jti == "" || exp == 0 || iat == 0 {
+		return nil, fmt.Errorf("%w: missing required access token claims", jwt.ErrTokenInvalidClaims)
+	}
+	if iss != s.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", jwt.ErrTokenInvalidClaims)
 	}
+	scope, _ := claims["scope"].(string)
+	authTime, _ := claims["auth_time"].(float64)
+	acr, _ := claims["acr"].(string)
 
 	accessTokenClaims := &models.AccessTokenClaims{
-		UserID:   int(claims["sub"].(float64)),
-		ClientID: claims["client_id"].(string),
-		Scope:    claims["scope"].(string),
-		Exp:      int64(claims["exp"].(float64)),
-		Iat:      int64(claims["iat"].(float64)),
-		Iss:      claims["iss"].(string),
-		Aud:      claims["aud"].(string),
+		Subject:  sub,
+		ClientID: clientID,
+		Scope:    scope,
+		Exp:      int64(exp),
+		Iat:      int64(iat),
+		Iss:      iss,
+		Aud:      aud,
+
+		JTI:      jti,
+		AuthTime: int64(authTime),
+		ACR:      acr,
+	}
+
+	// 解析令牌绑定的密钥
+	if cnf, ok := claims["cnf"].(map[string]any); ok {
+		jkt, _ := cnf["jkt"].(string)
+		x5t, _ := cnf["x5t#S256"].(string)
+		accessTokenClaims.Confirmation = &models.TokenConfirmation{JKT: jkt, X5TS256: x5t}
+	}
+
+	// 解析委托令牌的当前行为方
+	if act, ok := claims["act"].(map[string]any); ok {
+		accessTokenClaims.Actor = parseTokenActor(act)
+	}
+
+	// 解析为UserInfo请求的声明
+	if names, ok := claims["userinfo_claims"].([]any); ok {
+		for _, name := range names {
+			if str, ok := name.(string); ok {
+				accessTokenClaims.UserInfoClaims = append(accessTokenClaims.UserInfoClaims, str)
+			}
+		}
+	}
+
+	// 解析令牌的授权详情
+	if details, ok := claims["authorization_details"].([]any); ok {
+		for _, detail := range details {
+			if object, ok := detail.(map[string]any); ok {
+				accessTokenClaims.AuthorizationDetails = append(accessTokenClaims.AuthorizationDetails, object)
+			}
+		}
 	}
 
 	return accessTokenClaims, nil
 }
 
+// parseTokenActor converts an "act" claim, including nested prior actors, into a TokenActor.
+func parseTokenActor(act map[string]any) *models.TokenActor {
+	actor := &models.TokenActor{}
+	actor.Subject, _ = act["sub"].(string)
+	actor.ClientID, _ = act["client_id"].(string)
+	if prior, ok := act["act"].(map[string]any); ok {
+		actor.Actor = parseTokenActor(prior)
+	}
+	return actor
+}
+
 // GetPublicKey returns the RSA public key used for token verification
 func (s *JWTService) GetPublicKey() *rsa.PublicKey {
 	return s.publicKey
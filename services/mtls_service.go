@@ -0,0 +1,229 @@
+// Package services provides mutual TLS client authentication and certificate binding.
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"flash-oauth2/config"
+	"flash-oauth2/models"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Client authentication methods for the token endpoint (RFC 7591 Section 2, RFC 8705 Section 2).
+const (
+	AuthMethodClientSecretBasic       = "client_secret_basic"
+	AuthMethodClientSecretPost        = "client_secret_post"
+	AuthMethodTLSClientAuth           = "tls_client_auth"
+	AuthMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// MTLSService authenticates clients with TLS client certificates (RFC 8705).
+// Certificates are taken from the TLS connection, or from a header set by a
+// trusted TLS-terminating proxy.
+type MTLSService struct {
+	clientCAs        *x509.CertPool    // CAs trusted for tls_client_auth
+	certHeader       string            // Header carrying a forwarded client certificate
+	trustedProxies   []*net.IPNet      // Proxies allowed to forward client certificates
+	clientJWTService *ClientJWTService // Key sets with the certificates of self_signed_tls_client_auth clients
+}
+
+// NewMTLSService creates a new MTLSService instance from the server configuration.
+//
+// Parameters:
+//   - cfg: Configuration with the client CA pool and proxy settings
+//   - clientJWTService: Client JWT service resolving the key sets clients register
+//
+// Returns:
+//   - *MTLSService: Configured mutual TLS service instance
+func NewMTLSService(cfg *config.Config, clientJWTService *ClientJWTService) *MTLSService {
+	clientCAs := cfg.MTLSClientCAs
+	if clientCAs == nil {
+		clientCAs = x509.NewCertPool()
+	}
+
+	return &MTLSService{
+		clientCAs:        clientCAs,
+		certHeader:       cfg.MTLSClientCertHeader,
+		trustedProxies:   cfg.MTLSTrustedProxies,
+		clientJWTService: clientJWTService,
+	}
+}
+
+// ClientCertificates returns the client certificate chain presented with a request,
+// leaf first. Forwarded certificates are only accepted from trusted proxies.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - remoteIP: The address of the directly connected peer
+//
+// Returns:
+//   - []*x509.Certificate: The certificate chain, empty when no certificate was presented
+//   - error: An error if a forwarded certificate cannot be parsed
+func (s *MTLSService) ClientCertificates(r *http.Request, remoteIP string) ([]*x509.Certificate, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates, nil
+	}
+
+	if s.certHeader == "" || !s.trustedProxy(remoteIP) {
+		return nil, nil
+	}
+
+	value := r.Header.Get(s.certHeader)
+	if value == "" {
+		return nil, nil
+	}
+
+	cert, err := parseForwardedCertificate(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*x509.Certificate{cert}, nil
+}
+
+// AuthenticateClient verifies that the certificate chain authenticates the client
+// according to its registered token endpoint authentication method.
+//
+// For tls_client_auth the chain must be issued by a trusted CA and the leaf must match
+// the registered subject DN or subject alternative name. For self_signed_tls_client_auth
+// the leaf must be one of the certificates (x5c) in the client's JWKS, registered by value
+// or published at its jwks_uri (see ClientJWTService.ClientKeySet).
+//
+// Parameters:
+//   - client: The client being authenticated
+//   - chain: The presented certificate chain, leaf first
+//
+// Returns:
+//   - error: An error if the certificate does not authenticate the client
+func (s *MTLSService) AuthenticateClient(client *models.OAuthClient, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("client certificate required")
+	}
+	leaf := chain[0]
+
+	switch client.TokenEndpointAuthMethod {
+	case AuthMethodTLSClientAuth:
+		// 验证证书链
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         s.clientCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			return fmt.Errorf("client certificate is not trusted: %w", err)
+		}
+
+		if !matchesRegisteredIdentity(client, leaf) {
+			return fmt.Errorf("client certificate does not match the registered subject")
+		}
+		return nil
+
+	case AuthMethodSelfSignedTLSClientAuth:
+		now := time.Now()
+		if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			return fmt.Errorf("client certificate is expired or not yet valid")
+		}
+
+		keySet, err := s.clientJWTService.ClientKeySet(client)
+		if err != nil {
+			return err
+		}
+
+		// 与客户端注册的自签名证书比对
+		for _, key := range keySet.Keys {
+			if len(key.X5C) == 0 {
+				continue
+			}
+			registered, err := base64.StdEncoding.DecodeString(key.X5C[0])
+			if err == nil && string(registered) == string(leaf.Raw) {
+				return nil
+			}
+		}
+		return fmt.Errorf("client certificate is not registered")
+	}
+
+	return fmt.Errorf("client is not registered for mutual TLS authentication")
+}
+
+// CertificateThumbprint returns the base64url-encoded SHA-256 thumbprint of a certificate,
+// as carried in the "x5t#S256" confirmation of certificate-bound tokens.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// trustedProxy reports whether the peer address belongs to a trusted proxy.
+func (s *MTLSService) trustedProxy(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRegisteredIdentity reports whether the certificate matches the subject DN or
+// subject alternative name registered for a tls_client_auth client (RFC 8705 Section 2.1.2).
+func matchesRegisteredIdentity(client *models.OAuthClient, cert *x509.Certificate) bool {
+	switch {
+	case client.TLSClientAuthSubjectDN != "":
+		return cert.Subject.String() == client.TLSClientAuthSubjectDN
+	case client.TLSClientAuthSANDNS != "":
+		for _, name := range cert.DNSNames {
+			if name == client.TLSClientAuthSANDNS {
+				return true
+			}
+		}
+	case client.TLSClientAuthSANURI != "":
+		for _, uri := range cert.URIs {
+			if uri.String() == client.TLSClientAuthSANURI {
+				return true
+			}
+		}
+	case client.TLSClientAuthSANIP != "":
+		expected := net.ParseIP(client.TLSClientAuthSANIP)
+		for _, ip := range cert.IPAddresses {
+			if ip.Equal(expected) {
+				return true
+			}
+		}
+	case client.TLSClientAuthSANEmail != "":
+		for _, email := range cert.EmailAddresses {
+			if email == client.TLSClientAuthSANEmail {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseForwardedCertificate parses a client certificate forwarded by a proxy,
+// either as URL-encoded PEM (e.g. nginx $ssl_client_escaped_cert) or as base64 DER.
+func parseForwardedCertificate(value string) (*x509.Certificate, error) {
+	if decoded, err := url.PathUnescape(value); err == nil {
+		if block, _ := pem.Decode([]byte(decoded)); block != nil {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarded client certificate")
+	}
+	return x509.ParseCertificate(der)
+}
@@ -13,6 +13,12 @@ import (
 	"github.com/lib/pq"
 )
 
+// Access token formats a client can be configured for.
+const (
+	AccessTokenFormatJWT    = "jwt"    // Self-contained JWT access tokens (RFC 9068)
+	AccessTokenFormatOpaque = "opaque" // Opaque reference tokens, validated through introspection
+)
+
 // OAuthService provides OAuth2 and OpenID Connect operations including
 // client management, authorization code generation, and token lifecycle management.
 // It implements the OAuth2 Authorization Code Flow with PKCE support.
@@ -48,11 +54,38 @@ func NewOAuthService(db *sql.DB) *OAuthService {
 //
 //	client, err := oauthService.GetClient("my-app-client-id")
 func (s *OAuthService) GetClient(clientID string) (*models.OAuthClient, error) {
+	return scanClient(s.db.QueryRow(`SELECT `+clientColumns+` FROM oauth_clients WHERE id = $1`, clientID))
+}
+
+// clientColumns lists the oauth_clients columns read by scanClient, in scan order.
+const clientColumns = `id, secret, name, redirect_uris, grant_types, response_types, scope, created_at,
+	require_pushed_authorization_requests, require_signed_request_object,
+	COALESCE(app_id, ''), COALESCE(jwks_uri, ''),
+	COALESCE(token_endpoint_auth_method, 'client_secret_basic'), COALESCE(tls_client_auth_subject_dn, ''),
+	COALESCE(tls_client_auth_san_dns, ''), COALESCE(tls_client_auth_san_uri, ''),
+	COALESCE(tls_client_auth_san_ip, ''), COALESCE(tls_client_auth_san_email, ''),
+	COALESCE(tls_client_certificate_bound_access_tokens, FALSE),
+	COALESCE(developer_id, ''), COALESCE(client_uri, ''), COALESCE(logo_uri, ''), contacts,
+	token_exchange_audiences, token_exchange_scopes, COALESCE(access_token_format, 'jwt'),
+	access_token_lifetime, id_token_lifetime, refresh_token_lifetime, auth_code_lifetime,
+	refresh_token_idle_timeout, session_lifetime, issue_refresh_tokens,
+	COALESCE(userinfo_signed_response_alg, ''), COALESCE(jwks, ''),
+	COALESCE(id_token_encrypted_response_alg, ''), COALESCE(id_token_encrypted_response_enc, ''),
+	COALESCE(subject_type, 'public'), COALESCE(sector_identifier_uri, ''), post_logout_redirect_uris,
+	COALESCE(backchannel_logout_uri, ''), COALESCE(backchannel_logout_session_required, FALSE),
+	COALESCE(frontchannel_logout_uri, ''), COALESCE(frontchannel_logout_session_required, FALSE),
+	COALESCE(backchannel_token_delivery_mode, ''), COALESCE(backchannel_client_notification_endpoint, ''),
+	COALESCE(minimum_acr, ''), COALESCE(application_type, 'web'), request_uris`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanClient reads an OAuth2 client from a row selected with clientColumns.
+func scanClient(row rowScanner) (*models.OAuthClient, error) {
 	client := &models.OAuthClient{}
-	err := s.db.QueryRow(`
-		SELECT id, secret, name, redirect_uris, grant_types, response_types, scope, created_at 
-		FROM oauth_clients WHERE id = $1
-	`, clientID).Scan(
+	err := row.Scan(
 		&client.ID,
 		&client.Secret,
 		&client.Name,
@@ -61,6 +94,47 @@ func (s *OAuthService) GetClient(clientID string) (*models.OAuthClient, error) {
 		pq.Array(&client.ResponseTypes),
 		&client.Scope,
 		&client.CreatedAt,
+		&client.RequirePAR,
+		&client.RequireSignedRequestObject,
+		&client.AppID,
+		&client.JWKSURI,
+		&client.TokenEndpointAuthMethod,
+		&client.TLSClientAuthSubjectDN,
+		&client.TLSClientAuthSANDNS,
+		&client.TLSClientAuthSANURI,
+		&client.TLSClientAuthSANIP,
+		&client.TLSClientAuthSANEmail,
+		&client.TLSClientCertificateBoundAccessTokens,
+		&client.DeveloperID,
+		&client.ClientURI,
+		&client.LogoURI,
+		pq.Array(&client.Contacts),
+		pq.Array(&client.TokenExchangeAudiences),
+		pq.Array(&client.TokenExchangeScopes),
+		&client.AccessTokenFormat,
+		&client.TokenPolicy.AccessTokenLifetime,
+		&client.TokenPolicy.IDTokenLifetime,
+		&client.TokenPolicy.RefreshTokenLifetime,
+		&client.TokenPolicy.AuthCodeLifetime,
+		&client.TokenPolicy.RefreshTokenIdleTimeout,
+		&client.TokenPolicy.SessionLifetime,
+		&client.TokenPolicy.IssueRefreshTokens,
+		&client.UserInfoSignedResponseAlg,
+		&client.JWKS,
+		&client.IDTokenEncryptedResponseAlg,
+		&client.IDTokenEncryptedResponseEnc,
+		&client.SubjectType,
+		&client.SectorIdentifierURI,
+		pq.Array(&client.PostLogoutRedirectURIs),
+		&client.BackchannelLogoutURI,
+		&client.BackchannelLogoutSessionRequired,
+		&client.FrontchannelLogoutURI,
+		&client.FrontchannelLogoutSessionRequired,
+		&client.BackchannelTokenDeliveryMode,
+		&client.BackchannelClientNotificationEndpoint,
+		&client.MinimumACR,
+		&client.ApplicationType,
+		pq.Array(&client.RequestURIs),
 	)
 
 	if err != nil {
@@ -97,14 +171,165 @@ func (s *OAuthService) ValidateClient(clientID, clientSecret string) (*models.OA
 	return client, nil
 }
 
+// UpdateGrantTypes sets the grant types a client may use at the token endpoint.
+//
+// Parameters:
+//   - clientID: The client to configure
+//   - grantTypes: The grant types, validated by the caller
+//
+// Returns:
+//   - error: An error if the client does not exist or database operations fail
+//
+// Example:
+//
+//	err := oauthService.UpdateGrantTypes("api-gateway", []string{"urn:ietf:params:oauth:grant-type:token-exchange"})
+func (s *OAuthService) UpdateGrantTypes(clientID string, grantTypes []string) error {
+	result, err := s.db.Exec(`UPDATE oauth_clients SET grant_types = $2 WHERE id = $1`, clientID, pq.Array(grantTypes))
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("client not found")
+	}
+
+	return nil
+}
+
+// UpdateTokenExchangePolicy sets the audiences and scopes a client may request when
+// exchanging tokens (RFC 8693). A client with no audiences may only exchange tokens for itself.
+//
+// Parameters:
+//   - clientID: The client the policy applies to
+//   - audiences: Audiences the client may request exchanged tokens for
+//   - scopes: Scopes exchanged tokens may carry; without scopes the client cannot exchange tokens
+//
+// Returns:
+//   - error: An error if the client does not exist or database operations fail
+//
+// Example:
+//
+//	err := oauthService.UpdateTokenExchangePolicy("api-gateway", []string{"https://orders.example.com"}, []string{"orders:read"})
+func (s *OAuthService) UpdateTokenExchangePolicy(clientID string, audiences, scopes []string) error {
+	result, err := s.db.Exec(`
+		UPDATE oauth_clients SET token_exchange_audiences = $2, token_exchange_scopes = $3 WHERE id = $1
+	`, clientID, pq.Array(audiences), pq.Array(scopes))
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("client not found")
+	}
+
+	return nil
+}
+
+// UpdateAccessTokenFormat sets whether a client receives JWT or opaque reference access tokens.
+//
+// Parameters:
+//   - clientID: The client to configure
+//   - format: AccessTokenFormatJWT or AccessTokenFormatOpaque
+//
+// Returns:
+//   - error: An error if the format is unknown, the client does not exist or database operations fail
+//
+// Example:
+//
+//	err := oauthService.UpdateAccessTokenFormat("partner-app", services.AccessTokenFormatOpaque)
+func (s *OAuthService) UpdateAccessTokenFormat(clientID, format string) error {
+	if format != AccessTokenFormatJWT && format != AccessTokenFormatOpaque {
+		return fmt.Errorf("unsupported access token format %q", format)
+	}
+
+	result, err := s.db.Exec(`UPDATE oauth_clients SET access_token_format = $2 WHERE id = $1`, clientID, format)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("client not found")
+	}
+
+	return nil
+}
+
+// UpdateMinimumACR sets the weakest authentication context class users must have logged in with
+// to authorize a client. Users with a weaker session are asked to authenticate again.
+//
+// Parameters:
+//   - clientID: The client to configure
+//   - acr: One of ACRValues, or empty to accept any login
+//
+// Returns:
+//   - error: An error if the class is unknown, the client does not exist or database operations fail
+//
+// Example:
+//
+//	err := oauthService.UpdateMinimumACR("payments-app", services.ACRPhoneSMSTOTP)
+func (s *OAuthService) UpdateMinimumACR(clientID, acr string) error {
+	if acr != "" && ACRLevel(acr) == 0 {
+		return fmt.Errorf("unsupported authentication context class %q", acr)
+	}
+
+	result, err := s.db.Exec(`UPDATE oauth_clients SET minimum_acr = NULLIF($2, '') WHERE id = $1`, clientID, acr)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("client not found")
+	}
+
+	return nil
+}
+
+// UpdateTokenPolicy sets the token lifetimes and refresh token rules of a client.
+// Settings left nil fall back to the server defaults.
+//
+// Parameters:
+//   - clientID: The client to configure
+//   - policy: The client's overrides of the default token policy
+//
+// Returns:
+//   - error: An error if a setting is invalid, the client does not exist or database operations fail
+//
+// Example:
+//
+//	lifetime := 900
+//	err := oauthService.UpdateTokenPolicy("partner-app", models.ClientTokenPolicy{AccessTokenLifetime: &lifetime})
+func (s *OAuthService) UpdateTokenPolicy(clientID string, policy models.ClientTokenPolicy) error {
+	if err := ValidateClientTokenPolicy(policy); err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE oauth_clients SET
+			access_token_lifetime = $2, id_token_lifetime = $3, refresh_token_lifetime = $4,
+			auth_code_lifetime = $5, refresh_token_idle_timeout = $6, session_lifetime = $7,
+			issue_refresh_tokens = $8
+		WHERE id = $1
+	`, clientID, policy.AccessTokenLifetime, policy.IDTokenLifetime, policy.RefreshTokenLifetime,
+		policy.AuthCodeLifetime, policy.RefreshTokenIdleTimeout, policy.SessionLifetime, policy.IssueRefreshTokens)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("client not found")
+	}
+
+	return nil
+}
+
 // CreateAuthCode generates a new authorization code for the OAuth2 Authorization Code Flow.
-// The authorization code is used to exchange for access tokens and has a 10-minute expiration.
+// The authorization code is used to exchange for access tokens and expires after the client's
+// authorization code lifetime.
 //
 // Parameters:
-//   - clientID: The OAuth2 client identifier requesting the authorization
-//   - userID: The authenticated user's unique identifier
+//   - grant: What the user granted the client, and how the user authenticated
 //   - redirectURI: The URI to redirect to after authorization
-//   - scope: The requested OAuth2 scopes (space-separated)
+//   - lifetime: How long the code is valid
 //
 // Returns:
 //   - *models.AuthCode: The generated authorization code with metadata
@@ -112,24 +337,28 @@ func (s *OAuthService) ValidateClient(clientID, clientSecret string) (*models.OA
 //
 // Example:
 //
-//	authCode, err := oauthService.CreateAuthCode("my-app", 123, "https://app.com/callback", "openid profile")
-func (s *OAuthService) CreateAuthCode(clientID string, userID int, redirectURI, scope string) (*models.AuthCode, error) {
-	code := generateRandomString(32)
-	expiresAt := time.Now().Add(10 * time.Minute) // 授权码10分钟有效期
-
+//	authCode, err := oauthService.CreateAuthCode(&models.GrantContext{
+//		ClientID:       "my-app",
+//		UserID:         123,
+//		Scope:          "openid profile",
+//		Authentication: models.UserAuthentication{Time: time.Now(), ACR: ACRPhoneSMS},
+//	}, "https://app.com/callback", 10*time.Minute)
+func (s *OAuthService) CreateAuthCode(grant *models.GrantContext, redirectURI string, lifetime time.Duration) (*models.AuthCode, error) {
 	authCode := &models.AuthCode{
-		Code:        code,
-		ClientID:    clientID,
-		UserID:      userID,
+		GrantContext: *grant,
+
+		Code:        generateRandomString(32),
 		RedirectURI: redirectURI,
-		Scope:       scope,
-		ExpiresAt:   expiresAt,
+		ExpiresAt:   time.Now().Add(lifetime),
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO auth_codes (code, client_id, user_id, redirect_uri, scope, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, authCode.Code, authCode.ClientID, authCode.UserID, authCode.RedirectURI, authCode.Scope, authCode.ExpiresAt)
+		INSERT INTO auth_codes (code, client_id, user_id, redirect_uri, scope, expires_at, resources, auth_time, acr, session_id, claims,
+			authorization_details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''))
+	`, authCode.Code, authCode.ClientID, authCode.UserID, authCode.RedirectURI, authCode.Scope, authCode.ExpiresAt,
+		pq.Array(authCode.Resources), authCode.Authentication.Time, authCode.Authentication.ACR, authCode.Authentication.SessionID,
+		authCode.Claims, authCode.AuthorizationDetails)
 
 	if err != nil {
 		return nil, err
@@ -157,7 +386,9 @@ func (s *OAuthService) CreateAuthCode(clientID string, userID int, redirectURI,
 func (s *OAuthService) ExchangeAuthCode(code, clientID, redirectURI string) (*models.AuthCode, error) {
 	authCode := &models.AuthCode{}
 	err := s.db.QueryRow(`
-		SELECT code, client_id, user_id, redirect_uri, scope, expires_at, created_at
+		SELECT code, client_id, user_id, redirect_uri, scope, expires_at, created_at, resources,
+			COALESCE(auth_time, created_at), COALESCE(acr, ''), COALESCE(session_id, ''), COALESCE(claims, ''),
+			COALESCE(authorization_details, '')
 		FROM auth_codes 
 		WHERE code = $1 AND client_id = $2 AND redirect_uri = $3
 	`, code, clientID, redirectURI).Scan(
@@ -168,6 +399,12 @@ func (s *OAuthService) ExchangeAuthCode(code, clientID, redirectURI string) (*mo
 		&authCode.Scope,
 		&authCode.ExpiresAt,
 		&authCode.CreatedAt,
+		pq.Array(&authCode.Resources),
+		&authCode.Authentication.Time,
+		&authCode.Authentication.ACR,
+		&authCode.Authentication.SessionID,
+		&authCode.Claims,
+		&authCode.AuthorizationDetails,
 	)
 
 	if err != nil {
@@ -190,13 +427,16 @@ func (s *OAuthService) ExchangeAuthCode(code, clientID, redirectURI string) (*mo
 	return authCode, nil
 }
 
-// CreateAccessToken generates a new OAuth2 access token for authenticated API access.
-// Access tokens have a 1-hour expiration and are used to authorize API requests.
+// CreateAccessToken issues an opaque reference access token for a signed JWT access token.
+// Only a hash of the reference token is stored; the JWT stays on the server and is returned
+// when the reference token is validated, so the client never sees the token's claims.
 //
 // Parameters:
 //   - clientID: The OAuth2 client identifier that requested the token
 //   - userID: The authenticated user's unique identifier
 //   - scope: The granted OAuth2 scopes for this token
+//   - jwt: The signed JWT access token the reference token stands for
+//   - lifetime: How long the token is valid, matching the expiration of the JWT
 //
 // Returns:
 //   - *models.AccessToken: The generated access token with metadata
@@ -204,10 +444,10 @@ func (s *OAuthService) ExchangeAuthCode(code, clientID, redirectURI string) (*mo
 //
 // Example:
 //
-//	token, err := oauthService.CreateAccessToken("my-app", 123, "openid profile")
-func (s *OAuthService) CreateAccessToken(clientID string, userID int, scope string) (*models.AccessToken, error) {
+//	token, err := oauthService.CreateAccessToken("my-app", 123, "openid profile", signedJWT, time.Hour)
+func (s *OAuthService) CreateAccessToken(clientID string, userID int, scope, jwt string, lifetime time.Duration) (*models.AccessToken, error) {
 	token := generateRandomString(64)
-	expiresAt := time.Now().Add(1 * time.Hour) // 访问令牌1小时有效期
+	expiresAt := time.Now().Add(lifetime)
 
 	accessToken := &models.AccessToken{
 		Token:     token,
@@ -215,12 +455,15 @@ func (s *OAuthService) CreateAccessToken(clientID string, userID int, scope stri
 		UserID:    userID,
 		Scope:     scope,
 		ExpiresAt: expiresAt,
+
+		JWT: jwt,
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO access_tokens (token, client_id, user_id, scope, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, accessToken.Token, accessToken.ClientID, accessToken.UserID, accessToken.Scope, accessToken.ExpiresAt)
+		INSERT INTO access_tokens (token, client_id, user_id, scope, expires_at, jwt)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, hashToken(accessToken.Token), accessToken.ClientID, accessToken.UserID, accessToken.Scope, accessToken.ExpiresAt,
+		accessToken.JWT)
 
 	if err != nil {
 		return nil, err
@@ -230,13 +473,13 @@ func (s *OAuthService) CreateAccessToken(clientID string, userID int, scope stri
 }
 
 // CreateRefreshToken generates a new OAuth2 refresh token for token renewal.
-// Refresh tokens have a 30-day expiration and are used to obtain new access tokens
-// without requiring user re-authentication.
+// Refresh tokens are used to obtain new access tokens without requiring user
+// re-authentication until they expire.
 //
 // Parameters:
-//   - clientID: The OAuth2 client identifier that requested the token
-//   - userID: The authenticated user's unique identifier
-//   - scope: The granted OAuth2 scopes for this token
+//   - grant: The grant the token renews, such as that of the exchanged authorization code
+//   - expiresAt: When the refresh token expires
+//   - offline: Whether the token outlives the user's SSO session (offline_access was granted)
 //
 // Returns:
 //   - *models.RefreshToken: The generated refresh token with metadata
@@ -244,23 +487,24 @@ func (s *OAuthService) CreateAccessToken(clientID string, userID int, scope stri
 //
 // Example:
 //
-//	refreshToken, err := oauthService.CreateRefreshToken("my-app", 123, "openid profile")
-func (s *OAuthService) CreateRefreshToken(clientID string, userID int, scope string) (*models.RefreshToken, error) {
-	token := generateRandomString(64)
-	expiresAt := time.Now().Add(30 * 24 * time.Hour) // 刷新令牌30天有效期
-
+//	refreshToken, err := oauthService.CreateRefreshToken(&authCode.GrantContext, time.Now().Add(30*24*time.Hour), true)
+func (s *OAuthService) CreateRefreshToken(grant *models.GrantContext, expiresAt time.Time, offline bool) (*models.RefreshToken, error) {
 	refreshToken := &models.RefreshToken{
-		Token:     token,
-		ClientID:  clientID,
-		UserID:    userID,
-		Scope:     scope,
+		GrantContext: *grant,
+
+		Token:     generateRandomString(64),
 		ExpiresAt: expiresAt,
+
+		Offline: offline,
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO refresh_tokens (token, client_id, user_id, scope, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, refreshToken.Token, refreshToken.ClientID, refreshToken.UserID, refreshToken.Scope, refreshToken.ExpiresAt)
+		INSERT INTO refresh_tokens (token, client_id, user_id, scope, expires_at, resources, auth_time, acr, session_id, offline, claims,
+			authorization_details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NULLIF($11, ''), NULLIF($12, ''))
+	`, refreshToken.Token, refreshToken.ClientID, refreshToken.UserID, refreshToken.Scope, refreshToken.ExpiresAt,
+		pq.Array(refreshToken.Resources), refreshToken.Authentication.Time, refreshToken.Authentication.ACR,
+		refreshToken.Authentication.SessionID, refreshToken.Offline, refreshToken.Claims, refreshToken.AuthorizationDetails)
 
 	if err != nil {
 		return nil, err
@@ -269,32 +513,34 @@ func (s *OAuthService) CreateRefreshToken(clientID string, userID int, scope str
 	return refreshToken, nil
 }
 
-// ValidateAccessToken verifies an OAuth2 access token and returns its metadata.
-// This method checks if the token exists and hasn't expired, used for API authentication.
+// ValidateAccessToken verifies an opaque reference access token and returns its metadata.
+// This method checks if the token exists and hasn't expired or been revoked.
 //
 // Parameters:
-//   - token: The access token string to validate
+//   - token: The reference token string to validate
 //
 // Returns:
-//   - *models.AccessToken: The token metadata if valid
-//   - error: An error if the token is invalid, expired, or not found
+//   - *models.AccessToken: The token metadata, including the JWT it stands for, if valid
+//   - error: An error if the token is invalid, expired, revoked, or not found
 //
 // Example:
 //
 //	accessToken, err := oauthService.ValidateAccessToken("abc123token")
 func (s *OAuthService) ValidateAccessToken(token string) (*models.AccessToken, error) {
-	accessToken := &models.AccessToken{}
+	tokenHash := hashToken(token)
+
+	accessToken := &models.AccessToken{Token: token}
 	err := s.db.QueryRow(`
-		SELECT token, client_id, user_id, scope, expires_at, created_at
+		SELECT client_id, user_id, scope, expires_at, created_at, COALESCE(jwt, '')
 		FROM access_tokens 
 		WHERE token = $1
-	`, token).Scan(
-		&accessToken.Token,
+	`, tokenHash).Scan(
 		&accessToken.ClientID,
 		&accessToken.UserID,
 		&accessToken.Scope,
 		&accessToken.ExpiresAt,
 		&accessToken.CreatedAt,
+		&accessToken.JWT,
 	)
 
 	if err != nil {
@@ -304,41 +550,118 @@ func (s *OAuthService) ValidateAccessToken(token string) (*models.AccessToken, e
 	// 检查令牌是否过期
 	if time.Now().After(accessToken.ExpiresAt) {
 		// 删除过期的令牌
-		s.db.Exec("DELETE FROM access_tokens WHERE token = $1", token)
+		s.db.Exec("DELETE FROM access_tokens WHERE token = $1", tokenHash)
 		return nil, fmt.Errorf("access token expired")
 	}
 
 	return accessToken, nil
 }
 
+// RevokeAccessToken revokes an opaque reference access token issued to a client.
+// Tokens of other clients and unknown tokens are left untouched.
+//
+// Parameters:
+//   - token: The reference token to revoke
+//   - clientID: The client revoking the token
+//
+// Returns:
+//   - bool: Whether a token was revoked
+//   - error: An error if database operations fail
+func (s *OAuthService) RevokeAccessToken(token, clientID string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM access_tokens WHERE token = $1 AND client_id = $2", hashToken(token), clientID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// RevokeRefreshToken revokes a refresh token issued to a client.
+// Tokens of other clients and unknown tokens are left untouched.
+//
+// Parameters:
+//   - token: The refresh token to revoke
+//   - clientID: The client revoking the token
+//
+// Returns:
+//   - bool: Whether a token was revoked
+//   - error: An error if database operations fail
+func (s *OAuthService) RevokeRefreshToken(token, clientID string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM refresh_tokens WHERE token = $1 AND client_id = $2", token, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// RevokeSessionRefreshTokens revokes the online refresh tokens issued within an SSO session,
+// once the user logged out. Offline refresh tokens (offline_access) are kept.
+//
+// Parameters:
+//   - sessionID: The SSO session that ended
+//
+// Returns:
+//   - int64: The number of revoked refresh tokens
+//   - error: An error if database operations fail
+func (s *OAuthService) RevokeSessionRefreshTokens(sessionID string) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM refresh_tokens WHERE session_id = $1 AND NOT COALESCE(offline, FALSE)", sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 // RefreshAccessToken validates a refresh token and returns its metadata for token renewal.
 // This method is used in the refresh token flow to obtain new access tokens.
+// Only tokens issued to the client are found, so clients cannot expire or keep alive the
+// tokens of other clients. Tokens left unused for longer than the client's idle timeout
+// expire; otherwise the time of use is recorded.
 //
 // Parameters:
 //   - refreshToken: The refresh token string to validate
+//   - clientID: The authenticated client presenting the token
+//   - idleTimeout: How long the client's tokens may stay unused, 0 for no limit
 //
 // Returns:
 //   - *models.RefreshToken: The refresh token metadata if valid
-//   - error: An error if the token is invalid, expired, or not found
+//   - error: An error if the token is invalid, expired, idle for too long, or not issued to the client
 //
 // Example:
 //
-//	refreshToken, err := oauthService.RefreshAccessToken("refresh123token")
-func (s *OAuthService) RefreshAccessToken(refreshToken string) (*models.RefreshToken, error) {
+//	refreshToken, err := oauthService.RefreshAccessToken("refresh123token", "my-app", 0)
+func (s *OAuthService) RefreshAccessToken(refreshToken, clientID string, idleTimeout time.Duration) (*models.RefreshToken, error) {
 	token := &models.RefreshToken{}
 	err := s.db.QueryRow(`
-		SELECT token, client_id, user_id, scope, expires_at, created_at
+		SELECT token, client_id, user_id, scope, expires_at, created_at, resources,
+			COALESCE(auth_time, created_at), COALESCE(acr, ''), last_used_at,
+			COALESCE(session_id, ''), COALESCE(offline, FALSE), COALESCE(claims, ''),
+			COALESCE(authorization_details, '')
 		FROM refresh_tokens 
-		WHERE token = $1
-	`, refreshToken).Scan(
+		WHERE token = $1 AND client_id = $2
+	`, refreshToken, clientID).Scan(
 		&token.Token,
 		&token.ClientID,
 		&token.UserID,
 		&token.Scope,
 		&token.ExpiresAt,
 		&token.CreatedAt,
+		pq.Array(&token.Resources),
+		&token.Authentication.Time,
+		&token.Authentication.ACR,
+		&token.LastUsedAt,
+		&token.Authentication.SessionID,
+		&token.Offline,
+		&token.Claims,
+		&token.AuthorizationDetails,
 	)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -346,10 +669,24 @@ func (s *OAuthService) RefreshAccessToken(refreshToken string) (*models.RefreshT
 	// 检查刷新令牌是否过期
 	if time.Now().After(token.ExpiresAt) {
 		// 删除过期的刷新令牌
-		s.db.Exec("DELETE FROM refresh_tokens WHERE token = $1", refreshToken)
+		s.db.Exec("DELETE FROM refresh_tokens WHERE token = $1 AND client_id = $2", refreshToken, clientID)
 		return nil, fmt.Errorf("refresh token expired")
 	}
 
+	// 检查刷新令牌闲置时间
+	lastUsedAt := token.CreatedAt
+	if token.LastUsedAt != nil {
+		lastUsedAt = *token.LastUsedAt
+	}
+	if idleTimeout > 0 && time.Since(lastUsedAt) > idleTimeout {
+		s.db.Exec("DELETE FROM refresh_tokens WHERE token = $1 AND client_id = $2", refreshToken, clientID)
+		return nil, fmt.Errorf("refresh token expired after inactivity")
+	}
+
+	if _, err := s.db.Exec("UPDATE refresh_tokens SET last_used_at = $3 WHERE token = $1 AND client_id = $2", refreshToken, clientID, time.Now()); err != nil {
+		return nil, err
+	}
+
 	return token, nil
 }
 
@@ -0,0 +1,122 @@
+// Package services provides pushed authorization request storage.
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PARRequestURIPrefix is the URN prefix of request_uri values issued by the PAR endpoint.
+const PARRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// PARLifetime is how long a pushed authorization request can be referenced.
+const PARLifetime = 90 * time.Second
+
+// PARInteractionLifetime is how long a pushed authorization request stays valid once the
+// authorization endpoint shows the user a login or consent screen for it.
+const PARInteractionLifetime = 10 * time.Minute
+
+// PARService stores pushed authorization requests (RFC 9126) in Redis and resolves
+// the request_uri references handed out for them.
+type PARService struct {
+	redis *redis.Client // Redis client for short-lived pushed requests
+}
+
+// NewPARService creates a new PARService instance backed by Redis.
+//
+// Parameters:
+//   - redis: Redis client for pushed request storage
+//
+// Returns:
+//   - *PARService: Configured PAR service instance
+func NewPARService(redis *redis.Client) *PARService {
+	return &PARService{
+		redis: redis,
+	}
+}
+
+// Push stores the authorization request parameters of a client and returns the
+// request_uri that refers to them. The reference expires after PARLifetime.
+//
+// Parameters:
+//   - clientID: The authenticated client that pushed the request
+//   - params: The authorization request parameters
+//
+// Returns:
+//   - string: The request_uri referencing the stored request
+//   - error: An error if Redis operations fail
+//
+// Example:
+//
+//	requestURI, err := parService.Push("my-app", url.Values{"response_type": {"code"}})
+func (s *PARService) Push(clientID string, params url.Values) (string, error) {
+	ctx := context.Background()
+
+	stored := url.Values{}
+	for name, values := range params {
+		stored[name] = values
+	}
+	stored.Set("client_id", clientID)
+
+	requestURI := PARRequestURIPrefix + generateRandomString(32)
+	err := s.redis.Set(ctx, parKey(requestURI), stored.Encode(), PARLifetime).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return requestURI, nil
+}
+
+// Get resolves a request_uri to the pushed authorization request parameters.
+// The request must have been pushed by the same client.
+//
+// Parameters:
+//   - clientID: The client presenting the request_uri at the authorization endpoint
+//   - requestURI: The request_uri returned by Push
+//
+// Returns:
+//   - url.Values: The pushed authorization request parameters
+//   - error: An error if the request_uri is unknown, expired or belongs to another client
+func (s *PARService) Get(clientID, requestURI string) (url.Values, error) {
+	if !strings.HasPrefix(requestURI, PARRequestURIPrefix) {
+		return nil, fmt.Errorf("request_uri was not issued by this server")
+	}
+
+	encoded, err := s.redis.Get(context.Background(), parKey(requestURI)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("request_uri not found or expired")
+	}
+
+	params, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Get("client_id") != clientID {
+		return nil, fmt.Errorf("request_uri was issued to a different client")
+	}
+
+	return params, nil
+}
+
+// Extend keeps a pushed authorization request valid for PARInteractionLifetime, so that users
+// who take longer than PARLifetime to log in or consent can still complete it.
+func (s *PARService) Extend(requestURI string) error {
+	return s.redis.Expire(context.Background(), parKey(requestURI), PARInteractionLifetime).Err()
+}
+
+// Consume removes a pushed authorization request once it has been used,
+// so that each request_uri can only complete one authorization.
+func (s *PARService) Consume(requestURI string) error {
+	return s.redis.Del(context.Background(), parKey(requestURI)).Err()
+}
+
+// parKey returns the Redis key of a pushed authorization request.
+func parKey(requestURI string) string {
+	return fmt.Sprintf("par_request:%s", strings.TrimPrefix(requestURI, PARRequestURIPrefix))
+}
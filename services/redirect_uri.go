@@ -0,0 +1,123 @@
+// Package services provides the redirect URI rules for web and native clients.
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Client application types (OpenID Connect Dynamic Client Registration 1.0 Section 2).
+const (
+	ApplicationTypeWeb    = "web"    // Server-side or browser-based clients, redirecting to https URLs
+	ApplicationTypeNative = "native" // Desktop, mobile and CLI apps (RFC 8252)
+)
+
+// ApplicationTypes lists the application types clients can register.
+var ApplicationTypes = []string{ApplicationTypeWeb, ApplicationTypeNative}
+
+// ValidateRedirectURI checks a redirect URI submitted for registration. Every redirect URI must
+// be absolute, without a fragment (RFC 6749 Section 3.1.2) and without wildcards. Web clients
+// must use https. Native clients may also use a loopback http URI, whose port is chosen when
+// the app starts (RFC 8252 Section 7.3), or a private-use scheme in reverse domain name
+// notation such as com.example.app:/callback (RFC 8252 Section 7.1).
+//
+// Parameters:
+//   - applicationType: The client's application type, web or native
+//   - redirectURI: The redirect URI to check
+//
+// Returns:
+//   - error: Why the redirect URI cannot be registered, or nil
+//
+// Example:
+//
+//	err := services.ValidateRedirectURI(services.ApplicationTypeNative, "http://127.0.0.1/callback")
+func ValidateRedirectURI(applicationType, redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be an absolute URI", redirectURI)
+	}
+	if u.Fragment != "" || strings.Contains(redirectURI, "#") {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", redirectURI)
+	}
+	if strings.Contains(redirectURI, "*") {
+		return fmt.Errorf("redirect URI %q must not contain wildcards", redirectURI)
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return fmt.Errorf("redirect URI %q must include a host", redirectURI)
+	}
+
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case applicationType != ApplicationTypeNative:
+		return fmt.Errorf("redirect URI %q of a web client must use https", redirectURI)
+	case u.Scheme == "http":
+		// 原生应用仅可使用回环地址接收HTTP重定向
+		if !isLoopbackHost(u.Hostname()) {
+			return fmt.Errorf("http redirect URI %q of a native client must use a loopback address", redirectURI)
+		}
+		return nil
+	case !strings.Contains(u.Scheme, "."):
+		// 私有URI方案须采用反向域名形式，避免与其他应用冲突
+		return fmt.Errorf("private-use scheme of redirect URI %q must be a reverse domain name, such as com.example.app", redirectURI)
+	}
+	return nil
+}
+
+// MatchRedirectURI reports whether a redirect URI of an authorization request is one the client
+// registered. URIs must match exactly, except that the port of a loopback redirect URI of a
+// native client is not compared, since the app listens on whatever port is free when it
+// starts (RFC 8252 Section 7.3).
+//
+// Parameters:
+//   - applicationType: The client's application type, web or native
+//   - registered: The client's registered redirect URIs
+//   - redirectURI: The redirect URI of the request
+//
+// Returns:
+//   - bool: Whether the redirect URI may be used
+//
+// Example:
+//
+//	// true: "http://127.0.0.1/callback" is registered
+//	ok := services.MatchRedirectURI(client.ApplicationType, client.RedirectURIs, "http://127.0.0.1:51004/callback")
+func MatchRedirectURI(applicationType string, registered []string, redirectURI string) bool {
+	for _, uri := range registered {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	if applicationType != ApplicationTypeNative {
+		return false
+	}
+
+	requested, err := url.Parse(redirectURI)
+	if err != nil || requested.Scheme != "http" || requested.User != nil || requested.Fragment != "" ||
+		!isLoopbackHost(requested.Hostname()) {
+		return false
+	}
+
+	for _, uri := range registered {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme != "http" || u.User != nil {
+			continue
+		}
+		if u.Hostname() == requested.Hostname() && u.EscapedPath() == requested.EscapedPath() &&
+			u.RawQuery == requested.RawQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackHost reports whether a host names the loopback interface: an IPv4 or IPv6
+// loopback literal, or localhost.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
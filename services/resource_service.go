@@ -0,0 +1,205 @@
+// Package services provides the API resource registry used for resource indicators (RFC 8707).
+package services
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"flash-oauth2/models"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ResourceService manages the registry of protected APIs that clients can request
+// audience-restricted access tokens for with the resource parameter (RFC 8707).
+type ResourceService struct {
+	db *sql.DB // Database connection for the resource registry
+}
+
+// NewResourceService creates a new ResourceService instance.
+//
+// Parameters:
+//   - db: Database connection for resource storage
+//
+// Returns:
+//   - *ResourceService: Configured resource service instance
+func NewResourceService(db *sql.DB) *ResourceService {
+	return &ResourceService{
+		db: db,
+	}
+}
+
+// CreateResource registers a protected API, or replaces the registration of an existing one.
+// A new secret is generated for the resource server to authenticate with at the introspection
+// endpoint; it is returned in resource.Secret and only its hash is stored.
+//
+// Parameters:
+//   - resource: The resource to register; the identifier must be an absolute URI without a fragment
+//
+// Returns:
+//   - error: An error if the resource is invalid or database operations fail
+//
+// Example:
+//
+//	err := resourceService.CreateResource(&models.APIResource{
+//		Identifier: "https://orders.example.com", Name: "Orders API",
+//		Scopes: []string{"orders:read"}, AccessTokenLifetime: 900,
+//	})
+func (s *ResourceService) CreateResource(resource *models.APIResource) error {
+	if err := ValidateResourceIdentifier(resource.Identifier); err != nil {
+		return err
+	}
+	if resource.AccessTokenLifetime <= 0 {
+		resource.AccessTokenLifetime = int(time.Hour / time.Second)
+	}
+	resource.CreatedAt = time.Now()
+	resource.Secret = generateRandomString(32)
+
+	_, err := s.db.Exec(`
+		INSERT INTO api_resources (identifier, name, scopes, access_token_lifetime, created_at, secret_hash)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (identifier) DO UPDATE SET
+			name = EXCLUDED.name,
+			scopes = EXCLUDED.scopes,
+			access_token_lifetime = EXCLUDED.access_token_lifetime,
+			secret_hash = EXCLUDED.secret_hash
+	`, resource.Identifier, resource.Name, pq.Array(resource.Scopes), resource.AccessTokenLifetime, resource.CreatedAt,
+		hashToken(resource.Secret))
+	if err != nil {
+		return fmt.Errorf("failed to register resource: %w", err)
+	}
+
+	return nil
+}
+
+// GetResource retrieves a registered resource by its identifier.
+//
+// Parameters:
+//   - identifier: The resource identifier
+//
+// Returns:
+//   - *models.APIResource: The registered resource
+//   - error: An error if the resource is not registered
+func (s *ResourceService) GetResource(identifier string) (*models.APIResource, error) {
+	resource := &models.APIResource{}
+	err := s.db.QueryRow(`
+		SELECT identifier, name, scopes, access_token_lifetime, created_at
+		FROM api_resources
+		WHERE identifier = $1
+	`, identifier).Scan(&resource.Identifier, &resource.Name, pq.Array(&resource.Scopes),
+		&resource.AccessTokenLifetime, &resource.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown resource %s", identifier)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// ListResources returns all registered resources.
+//
+// Returns:
+//   - []*models.APIResource: The registered resources ordered by identifier
+//   - error: An error if database operations fail
+func (s *ResourceService) ListResources() ([]*models.APIResource, error) {
+	rows, err := s.db.Query(`
+		SELECT identifier, name, scopes, access_token_lifetime, created_at
+		FROM api_resources
+		ORDER BY identifier
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []*models.APIResource{}
+	for rows.Next() {
+		resource := &models.APIResource{}
+		if err := rows.Scan(&resource.Identifier, &resource.Name, pq.Array(&resource.Scopes),
+			&resource.AccessTokenLifetime, &resource.CreatedAt); err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, rows.Err()
+}
+
+// DeleteResource removes a resource from the registry. Tokens already issued for it stay valid
+// until they expire, but no new tokens can be requested for it.
+//
+// Parameters:
+//   - identifier: The resource identifier
+//
+// Returns:
+//   - error: An error if the resource is not registered or database operations fail
+func (s *ResourceService) DeleteResource(identifier string) error {
+	result, err := s.db.Exec("DELETE FROM api_resources WHERE identifier = $1", identifier)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("unknown resource %s", identifier)
+	}
+
+	return nil
+}
+
+// AuthenticateResource verifies the credentials of a resource server.
+//
+// Parameters:
+//   - identifier: The resource identifier
+//   - secret: The secret issued when the resource was registered
+//
+// Returns:
+//   - *models.APIResource: The authenticated resource
+//   - error: An error if the resource is unknown or the secret does not match
+func (s *ResourceService) AuthenticateResource(identifier, secret string) (*models.APIResource, error) {
+	var secretHash string
+	err := s.db.QueryRow(`SELECT COALESCE(secret_hash, '') FROM api_resources WHERE identifier = $1`, identifier).Scan(&secretHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource credentials")
+	}
+	if secretHash == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(hashToken(secret))) != 1 {
+		return nil, fmt.Errorf("invalid resource credentials")
+	}
+
+	return s.GetResource(identifier)
+}
+
+// ValidateResources checks that every requested resource indicator is a registered resource.
+//
+// Parameters:
+//   - identifiers: The resource parameter values of a request
+//
+// Returns:
+//   - error: An error naming the first invalid or unknown resource
+func (s *ResourceService) ValidateResources(identifiers []string) error {
+	for _, identifier := range identifiers {
+		if err := ValidateResourceIdentifier(identifier); err != nil {
+			return err
+		}
+		if _, err := s.GetResource(identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateResourceIdentifier checks that a resource indicator is an absolute URI
+// without a fragment (RFC 8707 Section 2).
+func ValidateResourceIdentifier(identifier string) error {
+	u, err := url.Parse(identifier)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("resource %q must be an absolute URI", identifier)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("resource %q must not contain a fragment", identifier)
+	}
+	return nil
+}
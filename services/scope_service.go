@@ -0,0 +1,235 @@
+// Package services provides the scope catalogue and the validation of requested scopes.
+package services
+
+import (
+	"database/sql"
+	"flash-oauth2/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScopeService manages the catalogue of scopes clients can request. Each scope has a
+// description shown on the consent screen, a sensitive flag requiring the user's consent,
+// and the user claims it unlocks.
+type ScopeService struct {
+	db *sql.DB // Database connection for the scope catalogue
+}
+
+// NewScopeService creates a new ScopeService instance.
+//
+// Parameters:
+//   - db: Database connection for scope storage
+//
+// Returns:
+//   - *ScopeService: Configured scope service instance
+func NewScopeService(db *sql.DB) *ScopeService {
+	return &ScopeService{
+		db: db,
+	}
+}
+
+// CreateScope registers a scope, or replaces the registration of an existing one.
+//
+// Parameters:
+//   - scope: The scope to register; the name must be a valid scope token (RFC 6749 Section 3.3)
+//
+// Returns:
+//   - error: An error if the scope name or minimum authentication context class is invalid, or database operations fail
+//
+// Example:
+//
+//	err := scopeService.CreateScope(&models.Scope{
+//		Name: "orders:read", Description: "读取您的订单", Claims: []string{},
+//	})
+func (s *ScopeService) CreateScope(scope *models.Scope) error {
+	if err := ValidateScopeName(scope.Name); err != nil {
+		return err
+	}
+	if scope.MinimumACR != "" && ACRLevel(scope.MinimumACR) == 0 {
+		return fmt.Errorf("unsupported authentication context class %q", scope.MinimumACR)
+	}
+	if scope.Claims == nil {
+		scope.Claims = []string{}
+	}
+	scope.CreatedAt = time.Now()
+
+	_, err := s.db.Exec(`
+		INSERT INTO scopes (name, description, sensitive, claims, minimum_acr, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			sensitive = EXCLUDED.sensitive,
+			claims = EXCLUDED.claims,
+			minimum_acr = EXCLUDED.minimum_acr
+	`, scope.Name, scope.Description, scope.Sensitive, pq.Array(scope.Claims), scope.MinimumACR, scope.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to register scope: %w", err)
+	}
+
+	return nil
+}
+
+// ListScopes returns the scope catalogue.
+//
+// Returns:
+//   - []*models.Scope: The registered scopes ordered by name
+//   - error: An error if database operations fail
+func (s *ScopeService) ListScopes() ([]*models.Scope, error) {
+	rows, err := s.db.Query(`
+		SELECT name, description, sensitive, COALESCE(claims, '{}'), COALESCE(minimum_acr, ''), created_at
+		FROM scopes
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scopes := []*models.Scope{}
+	for rows.Next() {
+		scope := &models.Scope{}
+		if err := rows.Scan(&scope.Name, &scope.Description, &scope.Sensitive, pq.Array(&scope.Claims),
+			&scope.MinimumACR, &scope.CreatedAt); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, rows.Err()
+}
+
+// DeleteScope removes a scope from the catalogue. Tokens already issued with it stay valid
+// until they expire, but clients can no longer request it.
+//
+// Parameters:
+//   - name: The scope name
+//
+// Returns:
+//   - error: An error if the scope is not registered or database operations fail
+func (s *ScopeService) DeleteScope(name string) error {
+	result, err := s.db.Exec("DELETE FROM scopes WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("unknown scope %s", name)
+	}
+
+	return nil
+}
+
+// GetScopes returns the registered scopes among the given names, in the order given.
+// Unregistered names are skipped.
+//
+// Parameters:
+//   - names: The scope names to look up
+//
+// Returns:
+//   - []*models.Scope: The registered scopes
+//   - error: An error if database operations fail
+func (s *ScopeService) GetScopes(names []string) ([]*models.Scope, error) {
+	catalogue, err := s.catalogue()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []*models.Scope{}
+	for _, name := range names {
+		if scope, ok := catalogue[name]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// ValidateScopes checks that every scope is registered in the catalogue.
+//
+// Parameters:
+//   - names: The scope names to check
+//
+// Returns:
+//   - error: An error naming the first unknown scope
+func (s *ScopeService) ValidateScopes(names []string) error {
+	catalogue, err := s.catalogue()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, ok := catalogue[name]; !ok {
+			return fmt.Errorf("unknown scope %s", name)
+		}
+	}
+	return nil
+}
+
+// ResolveScope validates a scope request against the catalogue and downscopes it to the scopes
+// the client may be granted. Unknown scopes are rejected; registered scopes outside the allowed
+// set are dropped, and the request is rejected when none remains.
+//
+// Parameters:
+//   - requested: The requested scope names
+//   - allowed: The scope names the client may be granted
+//
+// Returns:
+//   - string: The granted scope (space-separated, in request order)
+//   - error: An error if a scope is unknown or no requested scope is allowed
+//
+// Example:
+//
+//	scope, err := scopeService.ResolveScope([]string{"openid", "profile", "email"}, []string{"openid", "profile"})
+//	// scope == "openid profile"
+func (s *ScopeService) ResolveScope(requested, allowed []string) (string, error) {
+	if err := s.ValidateScopes(requested); err != nil {
+		return "", err
+	}
+
+	permitted := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		permitted[name] = true
+	}
+
+	var granted []string
+	for _, name := range requested {
+		if permitted[name] {
+			granted = append(granted, name)
+		}
+	}
+	if len(granted) == 0 {
+		return "", fmt.Errorf("none of the requested scopes is allowed for the client")
+	}
+
+	return strings.Join(granted, " "), nil
+}
+
+// catalogue returns the registered scopes by name.
+func (s *ScopeService) catalogue() (map[string]*models.Scope, error) {
+	scopes, err := s.ListScopes()
+	if err != nil {
+		return nil, err
+	}
+
+	catalogue := make(map[string]*models.Scope, len(scopes))
+	for _, scope := range scopes {
+		catalogue[scope.Name] = scope
+	}
+	return catalogue, nil
+}
+
+// ValidateScopeName checks that a scope name is a scope token (RFC 6749 Section 3.3):
+// printable ASCII without spaces, double quotes or backslashes.
+func ValidateScopeName(name string) error {
+	if name == "" {
+		return fmt.Errorf("scope name is required")
+	}
+	for _, r := range name {
+		if r < 0x21 || r > 0x7e || r == '"' || r == '\\' {
+			return fmt.Errorf("scope %q contains invalid characters", name)
+		}
+	}
+	return nil
+}
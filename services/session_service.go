@@ -0,0 +1,164 @@
+// Package services provides single sign-on sessions of users at the authorization server.
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flash-oauth2/models"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionService stores the SSO sessions of users in Redis. A session is created when a
+// user logs in and lets later authorization requests skip the login until it expires or the
+// user logs out. Online refresh tokens are bound to the session they were issued in, and the
+// clients the user signed in to during the session are recorded so they can be told about logout.
+type SessionService struct {
+	redis    *redis.Client // Redis client for session storage
+	lifetime time.Duration // How long a session lasts after login
+}
+
+// NewSessionService creates a new SessionService instance backed by Redis.
+//
+// Parameters:
+//   - redis: Redis client for session storage
+//   - lifetime: How long a session lasts after login
+//
+// Returns:
+//   - *SessionService: Configured session service instance
+func NewSessionService(redis *redis.Client, lifetime time.Duration) *SessionService {
+	return &SessionService{
+		redis:    redis,
+		lifetime: lifetime,
+	}
+}
+
+// CreateSession starts an SSO session for a user who just logged in.
+//
+// Parameters:
+//   - userID: The user who logged in
+//   - acr: How the user logged in
+//
+// Returns:
+//   - *models.UserSession: The new session
+//   - error: An error if Redis operations fail
+//
+// Example:
+//
+//	session, err := sessionService.CreateSession(user.ID, ACRPhoneSMS)
+func (s *SessionService) CreateSession(userID int, acr string) (*models.UserSession, error) {
+	now := time.Now()
+	session := &models.UserSession{
+		ID:        generateRandomString(32),
+		UserID:    userID,
+		AuthTime:  now,
+		ACR:       acr,
+		ExpiresAt: now.Add(s.lifetime),
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redis.Set(context.Background(), sessionKey(session.ID), data, s.lifetime).Err(); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSession returns an active SSO session.
+//
+// Parameters:
+//   - sessionID: The session identifier from the session cookie
+//
+// Returns:
+//   - *models.UserSession: The session
+//   - error: An error if the session does not exist, expired or ended
+func (s *SessionService) GetSession(sessionID string) (*models.UserSession, error) {
+	data, err := s.redis.Get(context.Background(), sessionKey(sessionID)).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+
+	var session models.UserSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// EndSession ends an SSO session when the user logs out.
+//
+// Parameters:
+//   - sessionID: The session to end
+//
+// Returns:
+//   - error: An error if Redis operations fail
+func (s *SessionService) EndSession(sessionID string) error {
+	return s.redis.Del(context.Background(), sessionKey(sessionID), sessionClientsKey(sessionID)).Err()
+}
+
+// AddClient records that the user of a session signed in to a client, which takes part in the
+// session until it ends.
+//
+// Parameters:
+//   - session: The user's SSO session
+//   - clientID: The client an authorization was issued to
+//
+// Returns:
+//   - error: An error if Redis operations fail
+func (s *SessionService) AddClient(session *models.UserSession, clientID string) error {
+	ctx := context.Background()
+	key := sessionClientsKey(session.ID)
+
+	if err := s.redis.SAdd(ctx, key, clientID).Err(); err != nil {
+		return err
+	}
+	return s.redis.ExpireAt(ctx, key, session.ExpiresAt).Err()
+}
+
+// SessionClients returns the clients the user signed in to during a session.
+//
+// Parameters:
+//   - sessionID: The session identifier
+//
+// Returns:
+//   - []string: The client IDs
+//   - error: An error if Redis operations fail
+func (s *SessionService) SessionClients(sessionID string) ([]string, error) {
+	return s.redis.SMembers(context.Background(), sessionClientsKey(sessionID)).Result()
+}
+
+// SessionSID returns the session identifier disclosed to clients in the "sid" claim of ID and
+// logout tokens. It is derived from the session ID, which is never disclosed since it is the
+// value of the session cookie.
+//
+// Parameters:
+//   - sessionID: The session identifier
+//
+// Returns:
+//   - string: The "sid" value, empty for an empty session ID
+func SessionSID(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("sid:" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// sessionKey returns the Redis key of an SSO session.
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("sso_session:%s", sessionID)
+}
+
+// sessionClientsKey returns the Redis key of the clients taking part in an SSO session.
+func sessionClientsKey(sessionID string) string {
+	return fmt.Sprintf("sso_session_clients:%s", sessionID)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 
@@ -15,6 +16,8 @@ import (
 // SMSService defines the interface for SMS operations
 type SMSService interface {
 	SendVerificationCode(phone, code string) error
+	// SendApprovalRequest sends a link the user opens to approve a backchannel authentication request
+	SendApprovalRequest(phone, link, bindingMessage string) error
 }
 
 // MockSMSService is used for testing and development
@@ -25,13 +28,16 @@ type MockSMSService struct {
 	TestMode bool
 	// TestCodes maps phone numbers to predefined verification codes for testing
 	TestCodes map[string]string
+	// LastApprovalLink stores the last sent approval link for testing
+	LastApprovalLink map[string]string
 }
 
 // NewMockSMSService creates a new mock SMS service
 func NewMockSMSService() *MockSMSService {
 	return &MockSMSService{
-		LastCode: make(map[string]string),
-		TestMode: true, // Default to test mode
+		LastCode:         make(map[string]string),
+		LastApprovalLink: make(map[string]string),
+		TestMode:         true, // Default to test mode
 		TestCodes: map[string]string{
 			"13800138000": "123456", // Standard test user
 			"13800138001": "654321", // Premium test user
@@ -59,6 +65,18 @@ func (m *MockSMSService) SendVerificationCode(phone, code string) error {
 	return nil
 }
 
+// SendApprovalRequest simulates sending an approval link for testing
+func (m *MockSMSService) SendApprovalRequest(phone, link, bindingMessage string) error {
+	log.Printf("MOCK SMS: Sending approval link %s (%s) to %s", link, bindingMessage, phone)
+	m.LastApprovalLink[phone] = link
+	return nil
+}
+
+// GetLastApprovalLink returns the last approval link sent to a phone number (for testing)
+func (m *MockSMSService) GetLastApprovalLink(phone string) string {
+	return m.LastApprovalLink[phone]
+}
+
 // GetLastCode returns the last verification code sent to a phone number (for testing)
 func (m *MockSMSService) GetLastCode(phone string) string {
 	return m.LastCode[phone]
@@ -84,9 +102,10 @@ func (m *MockSMSService) ClearTestCodes() {
 
 // AlibabaSMSService implements real SMS sending using Alibaba Cloud
 type AlibabaSMSService struct {
-	client     *dysmsapi20170525.Client
-	signName   string
-	templateId string
+	client             *dysmsapi20170525.Client
+	signName           string
+	templateId         string
+	approvalTemplateId string
 }
 
 // NewAlibabaSMSService creates a new Alibaba Cloud SMS service
@@ -103,19 +122,36 @@ func NewAlibabaSMSService(cfg *config.SMSConfig) (*AlibabaSMSService, error) {
 	}
 
 	return &AlibabaSMSService{
-		client:     client,
-		signName:   cfg.SignName,
-		templateId: cfg.TemplateCode,
+		client:             client,
+		signName:           cfg.SignName,
+		templateId:         cfg.TemplateCode,
+		approvalTemplateId: cfg.ApprovalTemplateCode,
 	}, nil
 }
 
 // SendVerificationCode sends SMS verification code using Alibaba Cloud
 func (a *AlibabaSMSService) SendVerificationCode(phone, code string) error {
+	templateParam, _ := json.Marshal(map[string]string{"code": code})
+	return a.send(phone, a.templateId, string(templateParam))
+}
+
+// SendApprovalRequest sends a backchannel authentication approval link using Alibaba Cloud
+func (a *AlibabaSMSService) SendApprovalRequest(phone, link, bindingMessage string) error {
+	if a.approvalTemplateId == "" {
+		return fmt.Errorf("SMS approval template is not configured")
+	}
+
+	templateParam, _ := json.Marshal(map[string]string{"link": link, "message": bindingMessage})
+	return a.send(phone, a.approvalTemplateId, string(templateParam))
+}
+
+// send sends an SMS with a template and its JSON-encoded parameters
+func (a *AlibabaSMSService) send(phone, templateId, templateParam string) error {
 	request := &dysmsapi20170525.SendSmsRequest{
 		PhoneNumbers:  tea.String(phone),
 		SignName:      tea.String(a.signName),
-		TemplateCode:  tea.String(a.templateId),
-		TemplateParam: tea.String(fmt.Sprintf("{\"code\":\"%s\"}", code)),
+		TemplateCode:  tea.String(templateId),
+		TemplateParam: tea.String(templateParam),
 	}
 
 	runtime := &util.RuntimeOptions{}
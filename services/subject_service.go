@@ -0,0 +1,187 @@
+// Package services provides the subject identifiers under which users are known to clients.
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"flash-oauth2/models"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Subject identifier types (OpenID Connect Core 1.0 Section 8).
+const (
+	SubjectTypePublic   = "public"   // The same "sub" for every client: the user ID
+	SubjectTypePairwise = "pairwise" // A different "sub" for each sector, unlinkable across sectors
+)
+
+// SubjectTypes lists the subject types clients can register when a pairwise subject salt is
+// configured.
+var SubjectTypes = []string{SubjectTypePublic, SubjectTypePairwise}
+
+// SubjectService derives the "sub" value of a user for a client and resolves it back to the
+// user. Pairwise subjects are an HMAC of the client's sector identifier and the user ID keyed
+// with a secret salt, so they are stable for a sector but reveal neither the user ID nor the
+// number of users; they are recorded so tokens presented later can be mapped to their user.
+type SubjectService struct {
+	db   *sql.DB // Database connection for pairwise subject records
+	salt []byte  // Secret salt of pairwise subjects
+}
+
+// NewSubjectService creates a new SubjectService instance.
+//
+// Parameters:
+//   - db: Database connection for pairwise subject storage
+//   - salt: Secret salt of pairwise subjects; changing it changes every pairwise subject, and
+//     without it only public subjects are supported
+//
+// Returns:
+//   - *SubjectService: Configured subject service instance
+func NewSubjectService(db *sql.DB, salt string) *SubjectService {
+	return &SubjectService{
+		db:   db,
+		salt: []byte(salt),
+	}
+}
+
+// SupportedSubjectTypes returns the subject types clients can register: public and, when a
+// salt is configured, pairwise.
+func (s *SubjectService) SupportedSubjectTypes() []string {
+	if len(s.salt) == 0 {
+		return []string{SubjectTypePublic}
+	}
+	return SubjectTypes
+}
+
+// CheckSalt verifies that pairwise subjects can be derived: when no salt is configured, no
+// client may use pairwise subjects, since their subjects would be lost.
+//
+// Returns:
+//   - error: An error if pairwise clients are registered without a salt, or the check fails
+func (s *SubjectService) CheckSalt() error {
+	if len(s.salt) > 0 {
+		return nil
+	}
+
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM oauth_clients WHERE subject_type = $1", SubjectTypePairwise).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count pairwise clients: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d clients use pairwise subjects, which require a salt", count)
+	}
+
+	return nil
+}
+
+// Subject returns the "sub" value identifying a user to a client: the user ID for public
+// clients, or the user's pairwise subject in the client's sector.
+//
+// Parameters:
+//   - client: The client the subject is issued to
+//   - userID: The user's unique identifier
+//
+// Returns:
+//   - string: The subject identifier
+//   - error: An error if the pairwise subject cannot be recorded
+//
+// Example:
+//
+//	sub, err := subjectService.Subject(client, 123)
+func (s *SubjectService) Subject(client *models.OAuthClient, userID int) (string, error) {
+	if client.SubjectType != SubjectTypePairwise {
+		return strconv.Itoa(userID), nil
+	}
+
+	if len(s.salt) == 0 {
+		return "", fmt.Errorf("pairwise subjects require a salt")
+	}
+
+	sector := SectorIdentifier(client)
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(sector + " " + strconv.Itoa(userID)))
+	subject := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	// 记录成对标识符，以便后续解析回用户
+	_, err := s.db.Exec(`
+		INSERT INTO pairwise_subjects (sector_identifier, subject, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sector_identifier, subject) DO NOTHING
+	`, sector, subject, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to record pairwise subject: %w", err)
+	}
+
+	return subject, nil
+}
+
+// ResolveSubject returns the user identified by a "sub" value issued to a client.
+//
+// Parameters:
+//   - client: The client the subject was issued to
+//   - subject: The subject identifier
+//
+// Returns:
+//   - int: The user's unique identifier
+//   - error: An error if the subject is unknown in the client's sector
+func (s *SubjectService) ResolveSubject(client *models.OAuthClient, subject string) (int, error) {
+	if client.SubjectType != SubjectTypePairwise {
+		userID, err := strconv.Atoi(subject)
+		if err != nil {
+			return 0, fmt.Errorf("invalid subject")
+		}
+		return userID, nil
+	}
+
+	var userID int
+	err := s.db.QueryRow(`
+		SELECT user_id FROM pairwise_subjects WHERE sector_identifier = $1 AND subject = $2
+	`, SectorIdentifier(client), subject).Scan(&userID)
+	if err != nil {
+		return 0, fmt.Errorf("unknown subject")
+	}
+
+	return userID, nil
+}
+
+// SectorIdentifier returns the sector a client's pairwise subjects are derived for: the host of
+// its sector_identifier_uri, or else the host of its redirect URIs. Clients without either use
+// their client ID, so their subjects are not shared with any other client.
+func SectorIdentifier(client *models.OAuthClient) string {
+	if uri, err := url.Parse(client.SectorIdentifierURI); err == nil && uri.Host != "" {
+		return uri.Host
+	}
+	if len(client.RedirectURIs) > 0 {
+		if uri, err := url.Parse(client.RedirectURIs[0]); err == nil && uri.Host != "" {
+			return uri.Host
+		}
+	}
+	return client.ID
+}
+
+// FetchSectorRedirectURIs downloads the JSON array of redirect URIs published at a
+// sector_identifier_uri (OpenID Connect Dynamic Client Registration 1.0 Section 5).
+//
+// Parameters:
+//   - sectorIdentifierURI: The HTTPS URL of the document
+//
+// Returns:
+//   - []string: The redirect URIs of the sector
+//   - error: An error if the URL is not HTTPS, the download fails or the document is invalid
+func FetchSectorRedirectURIs(sectorIdentifierURI string) ([]string, error) {
+	body, err := fetchRemoteDocument(sectorIdentifierURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var redirectURIs []string
+	if err := json.Unmarshal(body, &redirectURIs); err != nil {
+		return nil, fmt.Errorf("invalid sector identifier document: %w", err)
+	}
+
+	return redirectURIs, nil
+}
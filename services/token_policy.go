@@ -0,0 +1,125 @@
+// Package services provides the token lifetimes and refresh token rules applied to clients.
+package services
+
+import (
+	"flash-oauth2/config"
+	"flash-oauth2/models"
+	"fmt"
+	"time"
+)
+
+// TokenPolicy holds the token lifetimes and refresh token rules in effect for a client:
+// the server defaults, overridden by the client's own policy.
+type TokenPolicy struct {
+	AccessTokenLifetime     time.Duration // Access token lifetime
+	IDTokenLifetime         time.Duration // ID token lifetime
+	RefreshTokenLifetime    time.Duration // Refresh token lifetime
+	AuthCodeLifetime        time.Duration // Authorization code lifetime
+	RefreshTokenIdleTimeout time.Duration // Refresh tokens unused for this long expire (0 disables)
+	SessionLifetime         time.Duration // Refresh tokens cannot be used this long after login (0 disables)
+	IssueRefreshTokens      bool          // Whether refresh tokens are issued
+}
+
+// DefaultTokenPolicy returns the server-wide token policy from the configuration.
+//
+// Parameters:
+//   - cfg: Server configuration holding the default lifetimes
+//
+// Returns:
+//   - TokenPolicy: The default token policy
+func DefaultTokenPolicy(cfg *config.Config) TokenPolicy {
+	return TokenPolicy{
+		AccessTokenLifetime:     cfg.AccessTokenLifetime,
+		IDTokenLifetime:         cfg.IDTokenLifetime,
+		RefreshTokenLifetime:    cfg.RefreshTokenLifetime,
+		AuthCodeLifetime:        cfg.AuthCodeLifetime,
+		RefreshTokenIdleTimeout: cfg.RefreshTokenIdleTimeout,
+		SessionLifetime:         cfg.SessionLifetime,
+		IssueRefreshTokens:      cfg.IssueRefreshTokens,
+	}
+}
+
+// ForClient returns the policy with the overrides of a client applied.
+//
+// Parameters:
+//   - client: The client whose token policy overrides the defaults
+//
+// Returns:
+//   - TokenPolicy: The token policy in effect for the client
+//
+// Example:
+//
+//	policy := services.DefaultTokenPolicy(cfg).ForClient(client)
+//	expiresIn := int(policy.AccessTokenLifetime.Seconds())
+func (p TokenPolicy) ForClient(client *models.OAuthClient) TokenPolicy {
+	overrides := client.TokenPolicy
+	override := func(target *time.Duration, seconds *int) {
+		if seconds != nil {
+			*target = time.Duration(*seconds) * time.Second
+		}
+	}
+
+	override(&p.AccessTokenLifetime, overrides.AccessTokenLifetime)
+	override(&p.IDTokenLifetime, overrides.IDTokenLifetime)
+	override(&p.RefreshTokenLifetime, overrides.RefreshTokenLifetime)
+	override(&p.AuthCodeLifetime, overrides.AuthCodeLifetime)
+	override(&p.RefreshTokenIdleTimeout, overrides.RefreshTokenIdleTimeout)
+	override(&p.SessionLifetime, overrides.SessionLifetime)
+	if overrides.IssueRefreshTokens != nil {
+		p.IssueRefreshTokens = *overrides.IssueRefreshTokens
+	}
+
+	return p
+}
+
+// RefreshTokenExpiry returns when a refresh token issued now expires: after the refresh token
+// lifetime, but no later than the end of the session started when the user logged in.
+//
+// Parameters:
+//   - authn: When and how the user authenticated for the grant
+//
+// Returns:
+//   - time.Time: The expiration time of the refresh token
+func (p TokenPolicy) RefreshTokenExpiry(authn models.UserAuthentication) time.Time {
+	expiresAt := time.Now().Add(p.RefreshTokenLifetime)
+	if p.SessionLifetime > 0 && !authn.Time.IsZero() {
+		if sessionEnd := authn.Time.Add(p.SessionLifetime); sessionEnd.Before(expiresAt) {
+			expiresAt = sessionEnd
+		}
+	}
+	return expiresAt
+}
+
+// ValidateClientTokenPolicy checks the overrides of a client token policy: lifetimes must be
+// positive, while the idle timeout and session lifetime may be 0 to disable them.
+//
+// Parameters:
+//   - policy: The client token policy to validate
+//
+// Returns:
+//   - error: An error naming an invalid setting
+func ValidateClientTokenPolicy(policy models.ClientTokenPolicy) error {
+	lifetimes := map[string]*int{
+		"access_token_lifetime":  policy.AccessTokenLifetime,
+		"id_token_lifetime":      policy.IDTokenLifetime,
+		"refresh_token_lifetime": policy.RefreshTokenLifetime,
+		"auth_code_lifetime":     policy.AuthCodeLifetime,
+	}
+	for name, seconds := range lifetimes {
+		if seconds != nil && *seconds <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+
+	timeouts := map[string]*int{
+		"refresh_token_idle_timeout": policy.RefreshTokenIdleTimeout,
+		"session_lifetime":           policy.SessionLifetime,
+	}
+	for name, seconds := range timeouts {
+		if seconds != nil && *seconds < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+
+	return nil
+}
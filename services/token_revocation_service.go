@@ -0,0 +1,73 @@
+// Package services provides revocation of self-contained JWT access tokens.
+package services
+
+import (
+	"context"
+	"flash-oauth2/models"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenRevocationService keeps track of revoked JWT access tokens (RFC 7009).
+// A JWT cannot be withdrawn once issued, so the IDs of revoked tokens are kept in Redis
+// until the tokens would have expired, and checked whenever a token is validated.
+type TokenRevocationService struct {
+	redis *redis.Client // Redis client for the revoked token IDs
+}
+
+// NewTokenRevocationService creates a new TokenRevocationService instance.
+//
+// Parameters:
+//   - redis: Redis client for revoked token storage
+//
+// Returns:
+//   - *TokenRevocationService: Configured token revocation service instance
+func NewTokenRevocationService(redis *redis.Client) *TokenRevocationService {
+	return &TokenRevocationService{
+		redis: redis,
+	}
+}
+
+// RevokeAccessToken revokes a JWT access token until it expires.
+//
+// Parameters:
+//   - claims: The claims of the token to revoke
+//
+// Returns:
+//   - error: An error if Redis operations fail
+//
+// Example:
+//
+//	err := revocationService.RevokeAccessToken(claims)
+func (s *TokenRevocationService) RevokeAccessToken(claims *models.AccessTokenClaims) error {
+	ttl := time.Until(time.Unix(claims.Exp, 0))
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.redis.Set(context.Background(), revokedTokenKey(claims.JTI), "1", ttl).Err()
+}
+
+// IsRevoked reports whether a JWT access token has been revoked.
+//
+// Parameters:
+//   - jti: The token's "jti" claim
+//
+// Returns:
+//   - bool: Whether the token was revoked
+//   - error: An error if Redis operations fail
+func (s *TokenRevocationService) IsRevoked(jti string) (bool, error) {
+	n, err := s.redis.Exists(context.Background(), revokedTokenKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// revokedTokenKey returns the Redis key marking a token ID as revoked.
+func revokedTokenKey(jti string) string {
+	return fmt.Sprintf("revoked_jti:%s", jti)
+}
@@ -13,6 +13,25 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// userColumns lists the users columns read into a models.User, in the order scanUser expects.
+const userColumns = `id, phone, role, created_at, updated_at,
+	COALESCE(name, ''), COALESCE(nickname, ''), COALESCE(picture, ''), COALESCE(locale, ''),
+	COALESCE(email, ''), COALESCE(email_verified, FALSE), COALESCE(phone_number_verified, FALSE),
+	totp_secret IS NOT NULL`
+
+// scanUser reads a users row selected with userColumns.
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Phone, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Name, &user.Nickname, &user.Picture, &user.Locale,
+		&user.Email, &user.EmailVerified, &user.PhoneNumberVerified,
+		&user.TOTPEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // UserService handles user authentication and management operations.
 // It provides methods for verification code handling and user account management.
 type UserService struct {
@@ -128,14 +147,13 @@ func (s *UserService) VerifyCode(phone, code string) (*models.User, error) {
 //   - *models.User: The found or newly created user
 //   - error: An error if database operations fail
 func (s *UserService) findOrCreateUser(phone string) (*models.User, error) {
-	// 首先尝试查找用户
-	user := &models.User{}
-	err := s.db.QueryRow("SELECT id, phone, role, created_at, updated_at FROM users WHERE phone = $1", phone).
-		Scan(&user.ID, &user.Phone, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	// 首先尝试查找用户，短信验证通过即确认手机号
+	user, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE phone = $1", phone))
 
 	if err == nil {
 		// 用户存在，更新最后登录时间
-		_, err = s.db.Exec("UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", user.ID)
+		_, err = s.db.Exec("UPDATE users SET updated_at = CURRENT_TIMESTAMP, phone_number_verified = TRUE WHERE id = $1", user.ID)
+		user.PhoneNumberVerified = true
 		return user, err
 	}
 
@@ -144,12 +162,10 @@ func (s *UserService) findOrCreateUser(phone string) (*models.User, error) {
 	}
 
 	// 用户不存在，创建新用户（默认角色为普通用户）
-	err = s.db.QueryRow(
-		"INSERT INTO users (phone, role) VALUES ($1, 'user') RETURNING id, phone, role, created_at, updated_at",
+	return scanUser(s.db.QueryRow(
+		"INSERT INTO users (phone, role, phone_number_verified) VALUES ($1, 'user', TRUE) RETURNING "+userColumns,
 		phone,
-	).Scan(&user.ID, &user.Phone, &user.Role, &user.CreatedAt, &user.UpdatedAt)
-
-	return user, err
+	))
 }
 
 // GetUserByID retrieves a user from the database by their unique ID.
@@ -165,15 +181,103 @@ func (s *UserService) findOrCreateUser(phone string) (*models.User, error) {
 //
 //	user, err := userService.GetUserByID(123)
 func (s *UserService) GetUserByID(userID int) (*models.User, error) {
-	user := &models.User{}
-	err := s.db.QueryRow("SELECT id, phone, role, created_at, updated_at FROM users WHERE id = $1", userID).
-		Scan(&user.ID, &user.Phone, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", userID))
+}
 
-	if err != nil {
-		return nil, err
+// GetUserByPhone retrieves a registered user by phone number. Unlike VerifyCode it never
+// registers the user.
+//
+// Parameters:
+//   - phone: The user's phone number
+//
+// Returns:
+//   - *models.User: The user if found
+//   - error: An error if no user has the phone number or database operations fail
+//
+// Example:
+//
+//	user, err := userService.GetUserByPhone("13800138000")
+func (s *UserService) GetUserByPhone(phone string) (*models.User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE phone = $1", phone))
+}
+
+// UpdateProfile replaces the profile attributes of a user. email_verified can only be set
+// together with an email address. The phone number and its verification are managed by SMS login.
+//
+// Parameters:
+//   - userID: The unique identifier of the user
+//   - profile: The profile attributes (Name, Nickname, Picture, Locale, Email, EmailVerified)
+//
+// Returns:
+//   - *models.User: The updated user
+//   - error: An error if the user is not found or database operations fail
+//
+// Example:
+//
+//	user, err := userService.UpdateProfile(123, &models.User{Name: "张三", Email: "zhangsan@example.com"})
+func (s *UserService) UpdateProfile(userID int, profile *models.User) (*models.User, error) {
+	return scanUser(s.db.QueryRow(`
+		UPDATE users SET
+			name = $2, nickname = $3, picture = $4, locale = $5, email = $6,
+			email_verified = $7 AND $6 <> '',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+userColumns,
+		userID, profile.Name, profile.Nickname, profile.Picture, profile.Locale, profile.Email, profile.EmailVerified))
+}
+
+// UserClaims returns the OpenID Connect claims of a user released by the granted scopes:
+// the sub claim with the user's subject identifier for the client, plus each profile claim unlocked by a scope's catalogue entry or requested
+// individually with the claims parameter, whose value is set. The result is used for both the
+// UserInfo response and the ID token.
+//
+// Parameters:
+//   - user: The user the claims describe
+//   - subject: The user's subject identifier for the client (see SubjectService)
+//   - scopes: The granted scopes, as registered in the scope catalogue
+//   - requested: Claims requested individually (OpenID Connect Core 1.0 Section 5.5), may be empty
+//
+// Returns:
+//   - map[string]any: The claims by name
+//
+// Example:
+//
+//	scopes, _ := scopeService.GetScopes([]string{"openid", "email"})
+//	claims := services.UserClaims(user, "123", scopes, []string{"name"})
+//	// claims == {"sub": "123", "name": "张三", "email": "zhangsan@example.com", "email_verified": true}
+func UserClaims(user *models.User, subject string, scopes []*models.Scope, requested []string) map[string]any {
+	available := map[string]any{}
+	for name, value := range map[string]string{
+		"name":     user.Name,
+		"nickname": user.Nickname,
+		"picture":  user.Picture,
+		"locale":   user.Locale,
+	} {
+		if value != "" {
+			available[name] = value
+		}
+	}
+	if user.Email != "" {
+		available["email"] = user.Email
+		available["email_verified"] = user.EmailVerified
+	}
+	if user.Phone != "" {
+		available["phone_number"] = user.Phone
+		available["phone_number_verified"] = user.PhoneNumberVerified
 	}
 
-	return user, nil
+	names := append([]string{}, requested...)
+	for _, scope := range scopes {
+		names = append(names, scope.Claims...)
+	}
+
+	claims := map[string]any{"sub": subject}
+	for _, name := range names {
+		if value, ok := available[name]; ok {
+			claims[name] = value
+		}
+	}
+	return claims
 }
 
 // generateVerificationCode creates a random 6-digit numeric verification code.
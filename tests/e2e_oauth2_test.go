@@ -1,10 +1,40 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"flash-oauth2/handlers"
+	"flash-oauth2/models"
+	"flash-oauth2/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -118,6 +148,3677 @@ func TestPhoneAuthenticationFlow(t *testing.T) {
 	})
 }
 
+// TestAuthorizationResponseModes tests returning authorization responses with different response modes
+func TestAuthorizationResponseModes(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+	state := "state with spaces&symbols"
+
+	authorize := func(t *testing.T, responseMode string) *httptest.ResponseRecorder {
+		return ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {state},
+			"response_mode": {responseMode},
+		})
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		w := authorize(t, "")
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, location.Query().Get("code"))
+		assert.Equal(t, state, location.Query().Get("state"), "State should be URL encoded and round-trip")
+	})
+
+	t.Run("Fragment", func(t *testing.T) {
+		w := authorize(t, "fragment")
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Empty(t, location.RawQuery)
+
+		fragment, err := url.ParseQuery(location.Fragment)
+		require.NoError(t, err)
+		assert.NotEmpty(t, fragment.Get("code"))
+		assert.Equal(t, state, fragment.Get("state"))
+	})
+
+	t.Run("Form Post", func(t *testing.T) {
+		w := authorize(t, "form_post")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), `name="code"`)
+		assert.Contains(t, w.Body.String(), `action="`+redirectURI+`"`)
+	})
+
+	t.Run("JWT Secured Query", func(t *testing.T) {
+		w := authorize(t, "query.jwt")
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Empty(t, location.Query().Get("code"), "Code should only be inside the signed response")
+
+		token, err := jwt.Parse(location.Query().Get("response"), func(token *jwt.Token) (any, error) {
+			return ts.Config.JWTPublicKey, nil
+		})
+		require.NoError(t, err)
+
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, client.ID, claims["aud"])
+		assert.Equal(t, state, claims["state"])
+		assert.NotEmpty(t, claims["code"])
+	})
+
+	t.Run("Unsupported Mode", func(t *testing.T) {
+		authURL := "/authorize?" + url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"response_mode": {"bogus"},
+		}.Encode()
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, httptest.NewRequest("GET", authURL, nil))
+		require.Equal(t, http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	})
+}
+
+// TestPushedAuthorizationRequests tests the pushed authorization request flow (RFC 9126)
+func TestPushedAuthorizationRequests(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	push := func(t *testing.T, secret string) *httptest.ResponseRecorder {
+		data := url.Values{
+			"response_type": {"code"},
+			"redirect_uri":  {redirectURI},
+			"scope":         {"openid profile"},
+			"state":         {"pushed-state"},
+		}
+		req := httptest.NewRequest("POST", "/par", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(client.ID, secret)
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Push And Authorize", func(t *testing.T) {
+		w := push(t, client.Secret)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		requestURI := response["request_uri"].(string)
+		assert.True(t, strings.HasPrefix(requestURI, "urn:ietf:params:oauth:request_uri:"))
+		assert.Equal(t, float64(90), response["expires_in"])
+
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":   {client.ID},
+			"request_uri": {requestURI},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, location.Query().Get("code"))
+		assert.Equal(t, "pushed-state", location.Query().Get("state"))
+
+		// request_uri只能使用一次
+		reuse := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":   {client.ID},
+			"request_uri": {requestURI},
+		})
+		assert.Equal(t, http.StatusBadRequest, reuse.Code)
+	})
+
+	t.Run("Login Screen Extends Request", func(t *testing.T) {
+		w := push(t, client.Secret)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		requestURI := response["request_uri"].(string)
+
+		// 显示登录页面后，推送的请求在用户登录期间保持有效
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, httptest.NewRequest("GET", "/authorize?"+url.Values{
+			"client_id":   {client.ID},
+			"request_uri": {requestURI},
+		}.Encode(), nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		key := "par_request:" + strings.TrimPrefix(requestURI, services.PARRequestURIPrefix)
+		ttl, err := ts.Redis.TTL(context.Background(), key).Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, services.PARLifetime)
+	})
+
+	t.Run("Invalid Client Credentials", func(t *testing.T) {
+		w := push(t, "wrong-secret")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Client Requires PAR", func(t *testing.T) {
+		client := ts.CreateTestClient(t, ClientOverrides{"require_pushed_authorization_requests": true})
+
+		authURL := "/authorize?" + url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+		}.Encode()
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, httptest.NewRequest("GET", authURL, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestSignedRequestObjects tests JWT-secured authorization requests (RFC 9101)
+func TestSignedRequestObjects(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	// 将客户端关联到外部应用并签发密钥对
+	developer := ts.RegisterTestDeveloper(t)
+	app := ts.RegisterTestExternalApp(t, developer.ID)
+	keyPair := ts.GenerateTestKeyPair(t, app.ID)
+	client := ts.CreateTestClient(t, ClientOverrides{"app_id": app.ID})
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(keyPair.PrivateKey))
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(keyPair.Algorithm), claims)
+		token.Header["kid"] = keyPair.KeyID
+		signed, err := token.SignedString(privateKey)
+		require.NoError(t, err)
+		return signed
+	}
+
+	requestClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":           client.ID,
+			"aud":           "flash-oauth2",
+			"client_id":     client.ID,
+			"response_type": "code",
+			"redirect_uri":  redirectURI,
+			"scope":         "openid profile",
+			"state":         "signed-state",
+			"exp":           time.Now().Add(5 * time.Minute).Unix(),
+		}
+	}
+
+	t.Run("Request By Value", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id": {client.ID},
+			"request":   {sign(t, requestClaims())},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, location.Query().Get("code"))
+		assert.Equal(t, "signed-state", location.Query().Get("state"))
+	})
+
+	t.Run("Wrong Audience", func(t *testing.T) {
+		claims := requestClaims()
+		claims["aud"] = "another-server"
+
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id": {client.ID},
+			"request":   {sign(t, claims)},
+		})
+		assert.Equal(t, http.StatusBadRequest, login.Code)
+		assert.Contains(t, login.Body.String(), "invalid_request_object")
+	})
+
+	t.Run("Expiry Required", func(t *testing.T) {
+		for _, exp := range []any{nil, time.Now().Add(24 * time.Hour).Unix()} {
+			claims := requestClaims()
+			delete(claims, "exp")
+			if exp != nil {
+				claims["exp"] = exp
+			}
+
+			login := ts.LoginForAuthorization(t, phone, url.Values{
+				"client_id": {client.ID},
+				"request":   {sign(t, claims)},
+			})
+			assert.Equal(t, http.StatusBadRequest, login.Code)
+			assert.Contains(t, login.Body.String(), "invalid_request_object")
+		}
+	})
+
+	t.Run("Unregistered Request URI", func(t *testing.T) {
+		for _, requestURI := range []string{"https://169.254.169.254/latest/meta-data", "https://partner.example.com/request.jwt"} {
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, httptest.NewRequest("GET", "/authorize?"+url.Values{
+				"client_id":   {client.ID},
+				"request_uri": {requestURI},
+			}.Encode(), nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "not registered")
+		}
+	})
+
+	t.Run("Mismatched Outside Parameter", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id": {client.ID},
+			"request":   {sign(t, requestClaims())},
+			"state":     {"tampered"},
+		})
+		assert.Equal(t, http.StatusBadRequest, login.Code)
+	})
+
+	t.Run("Revoked Key", func(t *testing.T) {
+		ts.RevokeTestKey(t, keyPair.KeyID)
+
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id": {client.ID},
+			"request":   {sign(t, requestClaims())},
+		})
+		assert.Equal(t, http.StatusBadRequest, login.Code)
+	})
+
+	t.Run("Client Requires Signed Request Object", func(t *testing.T) {
+		client := ts.CreateTestClient(t, ClientOverrides{"require_signed_request_object": true})
+
+		authURL := "/authorize?" + url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+		}.Encode()
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, httptest.NewRequest("GET", authURL, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestDPoPBoundTokens tests DPoP sender-constrained access tokens (RFC 9449)
+func TestDPoPBoundTokens(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	proof := func(t *testing.T, method, path, accessToken string) string {
+		claims := jwt.MapClaims{
+			"jti": uuid.New().String(),
+			"htm": method,
+			"htu": ts.Config.BaseURL + path,
+			"iat": time.Now().Unix(),
+		}
+		if accessToken != "" {
+			sum := sha256.Sum256([]byte(accessToken))
+			claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["typ"] = "dpop+jwt"
+		token.Header["jwk"] = map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	userInfo := func(scheme, accessToken, dpopProof string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", scheme+" "+accessToken)
+		if dpopProof != "" {
+			req.Header.Set("DPoP", dpopProof)
+		}
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 获取授权码并用DPoP证明换取令牌
+	login := ts.LoginForAuthorization(t, phone, url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+	})
+	require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ID},
+		"client_secret": {client.Secret},
+	}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("DPoP", proof(t, "POST", "/token", ""))
+
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("DPoP-Nonce"))
+
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	assert.Equal(t, "DPoP", tokens["token_type"])
+	accessToken := tokens["access_token"].(string)
+
+	t.Run("UserInfo With Proof", func(t *testing.T) {
+		w := userInfo("DPoP", accessToken, proof(t, "GET", "/userinfo", accessToken))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Bearer Scheme Rejected", func(t *testing.T) {
+		w := userInfo("Bearer", accessToken, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "DPoP")
+	})
+
+	t.Run("Replayed Proof", func(t *testing.T) {
+		replayed := proof(t, "GET", "/userinfo", accessToken)
+		assert.Equal(t, http.StatusOK, userInfo("DPoP", accessToken, replayed).Code)
+		assert.Equal(t, http.StatusUnauthorized, userInfo("DPoP", accessToken, replayed).Code)
+	})
+
+	t.Run("Proof For Another URL", func(t *testing.T) {
+		w := userInfo("DPoP", accessToken, proof(t, "GET", "/introspect", accessToken))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_dpop_proof")
+	})
+}
+
+// TestMutualTLSClientAuthentication tests tls_client_auth and certificate-bound access tokens (RFC 8705)
+func TestMutualTLSClientAuthentication(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"token_endpoint_auth_method":                 "tls_client_auth",
+		"tls_client_auth_subject_dn":                 "CN=partner",
+		"tls_client_certificate_bound_access_tokens": true,
+	})
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	// 创建受信任的CA并签发客户端证书
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Partner CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	ts.Config.MTLSClientCAs.AddCert(caCert)
+
+	issue := func(t *testing.T, commonName string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: commonName},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+
+	exchange := func(t *testing.T, client *TestClient, cert *x509.Certificate) *httptest.ResponseRecorder {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {client.RedirectURIs[0]},
+			"response_type": {"code"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		data := url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"redirect_uri": {client.RedirectURIs[0]},
+			"client_id":    {client.ID},
+		}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Certificate Bound Token", func(t *testing.T) {
+		cert := issue(t, "partner")
+		w := exchange(t, client, cert)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		accessToken := tokens["access_token"].(string)
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(accessToken, claims)
+		require.NoError(t, err)
+		sum := sha256.Sum256(cert.Raw)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), claims["cnf"].(map[string]any)["x5t#S256"])
+
+		// 使用令牌时必须出示同一证书
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Wrong Subject", func(t *testing.T) {
+		w := exchange(t, client, issue(t, "someone-else"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing Certificate", func(t *testing.T) {
+		w := exchange(t, client, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Self-Signed Certificate In JWKS", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: "self-signed-partner"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+
+		// 自签名证书按值注册在jwks中，无需jwks_uri
+		jwks, err := json.Marshal(map[string]any{"keys": []map[string]any{{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			"x5c": []string{base64.StdEncoding.EncodeToString(der)},
+		}}})
+		require.NoError(t, err)
+		selfSigned := ts.CreateTestClient(t, ClientOverrides{
+			"token_endpoint_auth_method": "self_signed_tls_client_auth",
+			"jwks":                       string(jwks),
+		})
+
+		w := exchange(t, selfSigned, cert)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = exchange(t, selfSigned, issue(t, "partner"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// TestTokenExchange tests the token exchange grant (RFC 8693)
+func TestTokenExchange(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"grant_types": []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"},
+	})
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	// 通过管理API设置交换策略
+	policy, _ := json.Marshal(map[string]any{
+		"audiences": []string{"https://orders.example.com"},
+		"scopes":    []string{"openid", "profile"},
+	})
+	req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/token-exchange-policy", policy)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	login := ts.LoginForAuthorization(t, phone, url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid profile"},
+	})
+	require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+
+	token := func(data url.Values) *httptest.ResponseRecorder {
+		data.Set("client_id", client.ID)
+		data.Set("client_secret", client.Secret)
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = token(url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {location.Query().Get("code")},
+		"redirect_uri": {redirectURI},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	subjectToken := tokens["access_token"].(string)
+
+	exchange := func(extra url.Values) *httptest.ResponseRecorder {
+		data := url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {subjectToken},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		}
+		for name, values := range extra {
+			data[name] = values
+		}
+		return token(data)
+	}
+
+	t.Run("Delegation To Downstream Service", func(t *testing.T) {
+		w := exchange(url.Values{
+			"audience":         {"https://orders.example.com"},
+			"scope":            {"profile"},
+			"actor_token":      {subjectToken},
+			"actor_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", response["issued_token_type"])
+		assert.Equal(t, "profile", response["scope"])
+		assert.Nil(t, response["refresh_token"])
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(response["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.Equal(t, "https://orders.example.com", claims["aud"])
+		assert.Equal(t, client.ID, claims["act"].(map[string]any)["client_id"])
+
+		req := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{
+			"token":         {response["access_token"].(string)},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		var introspection map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &introspection))
+		assert.Equal(t, true, introspection["active"])
+		assert.NotNil(t, introspection["act"])
+	})
+
+	t.Run("Audience Not Allowed", func(t *testing.T) {
+		w := exchange(url.Values{"audience": {"https://payments.example.com"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_target")
+	})
+
+	t.Run("Scope Exceeds Subject Token", func(t *testing.T) {
+		w := exchange(url.Values{"scope": {"openid email"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_scope")
+	})
+
+	// 为指定客户端执行授权码流程获取访问令牌，给定密钥时绑定DPoP
+	dpopProof := func(t *testing.T, key *ecdsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"jti": uuid.New().String(),
+			"htm": "POST",
+			"htu": ts.Config.BaseURL + "/token",
+			"iat": time.Now().Unix(),
+		})
+		token.Header["typ"] = "dpop+jwt"
+		token.Header["jwk"] = map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	issueToken := func(t *testing.T, tokenClient *TestClient, key *ecdsa.PrivateKey) string {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {tokenClient.ID},
+			"redirect_uri":  {tokenClient.RedirectURIs[0]},
+			"response_type": {"code"},
+			"scope":         {"openid profile"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {tokenClient.RedirectURIs[0]},
+			"client_id":     {tokenClient.ID},
+			"client_secret": {tokenClient.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if key != nil {
+			req.Header.Set("DPoP", dpopProof(t, key))
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["access_token"].(string)
+	}
+
+	t.Run("Subject Token Of Another Client", func(t *testing.T) {
+		other := ts.CreateTestClient(t)
+
+		w := token(url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {issueToken(t, other, nil)},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+
+	t.Run("DPoP-Bound Subject Token Requires Proof", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		boundToken := issueToken(t, client, key)
+
+		data := url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {boundToken},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		}
+		w := token(data)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+
+		data.Set("client_id", client.ID)
+		data.Set("client_secret", client.Secret)
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("DPoP", dpopProof(t, key))
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"token_type":"DPoP"`)
+	})
+
+	t.Run("Policy Without Scopes", func(t *testing.T) {
+		unconfigured := ts.CreateTestClient(t, ClientOverrides{
+			"grant_types": []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"},
+		})
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {issueToken(t, unconfigured, nil)},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+			"client_id":          {unconfigured.ID},
+			"client_secret":      {unconfigured.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_scope")
+	})
+
+	t.Run("Invalid Subject Token", func(t *testing.T) {
+		w := token(url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {"not-a-token"},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+}
+
+// TestJWTBearerGrant tests the JWT bearer authorization grant (RFC 7523)
+func TestJWTBearerGrant(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	// 将客户端关联到外部应用并签发密钥对
+	developer := ts.RegisterTestDeveloper(t)
+	app := ts.RegisterTestExternalApp(t, developer.ID)
+	keyPair := ts.GenerateTestKeyPair(t, app.ID)
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"app_id":      app.ID,
+		"grant_types": []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:jwt-bearer"},
+	})
+
+	policy, _ := json.Marshal(map[string]any{"subjects": []string{strconv.Itoa(user.ID)}})
+	req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/apps/"+app.ID+"/assertion-subjects", policy)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(keyPair.PrivateKey))
+	require.NoError(t, err)
+
+	assertion := func(t *testing.T, kid, subject string) string {
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(keyPair.Algorithm), jwt.MapClaims{
+			"iss": client.ID,
+			"sub": subject,
+			"aud": ts.Config.BaseURL + "/token",
+			"exp": time.Now().Add(5 * time.Minute).Unix(),
+			"iat": time.Now().Unix(),
+			"jti": uuid.New().String(),
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(privateKey)
+		require.NoError(t, err)
+		return signed
+	}
+
+	exchange := func(assertion, scope string) *httptest.ResponseRecorder {
+		data := url.Values{
+			"grant_type":    {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":     {assertion},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}
+		if scope != "" {
+			data.Set("scope", scope)
+		}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Valid Assertion", func(t *testing.T) {
+		signed := assertion(t, keyPair.KeyID, strconv.Itoa(user.ID))
+		w := exchange(signed, "profile")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Equal(t, "profile", tokens["scope"])
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(tokens["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(user.ID), claims["sub"])
+		assert.NotContains(t, claims, "auth_time")
+
+		// 断言不可重放
+		w = exchange(signed, "profile")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+
+	t.Run("Subject Not Allowed", func(t *testing.T) {
+		w := exchange(assertion(t, keyPair.KeyID, strconv.Itoa(user.ID+1000)), "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+
+	t.Run("Scope Not Allowed", func(t *testing.T) {
+		w := exchange(assertion(t, keyPair.KeyID, strconv.Itoa(user.ID)), "admin")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_scope")
+	})
+
+	t.Run("Revoked Key", func(t *testing.T) {
+		ts.RevokeTestKey(t, keyPair.KeyID)
+		w := exchange(assertion(t, keyPair.KeyID, strconv.Itoa(user.ID)), "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "revoked")
+	})
+}
+
+// TestResourceIndicators tests audience-restricted tokens for registered resources (RFC 8707)
+func TestResourceIndicators(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+	resource := "https://orders.example.com"
+
+	// 通过管理API注册资源
+	payload, _ := json.Marshal(map[string]any{
+		"identifier":            resource,
+		"name":                  "Orders API",
+		"scopes":                []string{"profile"},
+		"access_token_lifetime": 900,
+	})
+	req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/resources", payload)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	defer ts.DB.Exec("DELETE FROM api_resources WHERE identifier = $1", resource)
+
+	authorize := func(t *testing.T, resources ...string) *httptest.ResponseRecorder {
+		return ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"profile"},
+			"resource":      resources,
+		})
+	}
+
+	token := func(data url.Values) *httptest.ResponseRecorder {
+		data.Set("client_id", client.ID)
+		data.Set("client_secret", client.Secret)
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Audience Restricted Token", func(t *testing.T) {
+		login := authorize(t, resource)
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		w := token(url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"redirect_uri": {redirectURI},
+			"resource":     {resource},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Equal(t, "profile", tokens["scope"])
+		assert.Equal(t, float64(900), tokens["expires_in"])
+
+		claims := jwt.MapClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(tokens["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.Equal(t, resource, claims["aud"])
+
+		// 刷新时沿用授权时的资源
+		w = token(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {tokens["refresh_token"].(string)},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		claims = jwt.MapClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(tokens["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.Equal(t, resource, claims["aud"])
+
+		// 不能请求授权之外的资源
+		w = token(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {tokens["refresh_token"].(string)},
+			"resource":      {"https://payments.example.com"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_target")
+	})
+
+	t.Run("Unknown Resource", func(t *testing.T) {
+		login := authorize(t, "https://unknown.example.com")
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "invalid_target", location.Query().Get("error"))
+	})
+}
+
+// TestJWTAccessTokenProfile tests that access tokens follow the JWT access token profile (RFC 9068)
+func TestJWTAccessTokenProfile(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	// OpenID Connect请求需授予离线访问才签发刷新令牌
+	params := url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid profile offline_access"},
+	}
+	login := ts.AnswerConsent(t, ts.LoginForAuthorization(t, phone, params), params, "allow")
+	require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ID},
+		"client_secret": {client.Secret},
+	}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	accessToken := tokens["access_token"].(string)
+
+	introspect := func(token string) map[string]any {
+		req := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{
+			"token":         {token},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("Token Shape", func(t *testing.T) {
+		claims := jwt.MapClaims{}
+		token, _, err := jwt.NewParser().ParseUnverified(accessToken, claims)
+		require.NoError(t, err)
+
+		assert.Equal(t, "at+jwt", token.Header["typ"])
+		assert.Equal(t, "default", token.Header["kid"])
+		assert.IsType(t, "", claims["sub"])
+		assert.Equal(t, client.ID, claims["client_id"])
+		assert.Equal(t, "urn:flash-oauth2:acr:sms", claims["acr"])
+		assert.Contains(t, claims, "auth_time")
+		assert.NotContains(t, claims, "token_type")
+
+		jti, _ := claims["jti"].(string)
+		_, err = uuid.Parse(jti)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unique Token IDs", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {tokens["refresh_token"].(string)},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var refreshed map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+
+		original, renewed := jwt.MapClaims{}, jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(accessToken, original)
+		require.NoError(t, err)
+		_, _, err = jwt.NewParser().ParseUnverified(refreshed["access_token"].(string), renewed)
+		require.NoError(t, err)
+
+		// 刷新后的令牌保留原始认证时间
+		assert.NotEqual(t, original["jti"], renewed["jti"])
+		assert.Equal(t, original["auth_time"], renewed["auth_time"])
+		assert.Equal(t, original["acr"], renewed["acr"])
+	})
+
+	t.Run("Introspection", func(t *testing.T) {
+		response := introspect(accessToken)
+		assert.Equal(t, true, response["active"])
+		assert.IsType(t, "", response["sub"])
+		assert.NotEmpty(t, response["jti"])
+		assert.Equal(t, "urn:flash-oauth2:acr:sms", response["acr"])
+	})
+
+	t.Run("ID Token Rejected", func(t *testing.T) {
+		// ID令牌没有at+jwt类型，不能作为访问令牌使用
+		response := introspect(tokens["id_token"].(string))
+		assert.Equal(t, false, response["active"])
+
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens["id_token"].(string))
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// TestReferenceAccessTokens tests opaque reference access tokens and their revocation (RFC 7009)
+func TestReferenceAccessTokens(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	// 通过管理API切换为引用令牌
+	payload, _ := json.Marshal(map[string]string{"format": "opaque"})
+	req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/access-token-format", payload)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// OpenID Connect请求需授予离线访问才签发刷新令牌
+	params := url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid profile offline_access"},
+	}
+	login := ts.AnswerConsent(t, ts.LoginForAuthorization(t, phone, params), params, "allow")
+	require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+
+	post := func(path string, data url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = post("/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ID},
+		"client_secret": {client.Secret},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	accessToken := tokens["access_token"].(string)
+	refreshToken := tokens["refresh_token"].(string)
+
+	userInfo := func(token string) int {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	introspect := func(token string) map[string]any {
+		w := post("/introspect", url.Values{
+			"token":         {token},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("Opaque Token", func(t *testing.T) {
+		assert.NotContains(t, accessToken, ".")
+
+		// 数据库中只保存令牌的哈希值
+		var count int
+		require.NoError(t, ts.DB.QueryRow(`SELECT COUNT(*) FROM access_tokens WHERE token = $1`, accessToken).Scan(&count))
+		assert.Equal(t, 0, count)
+		require.NoError(t, ts.DB.QueryRow(`SELECT COUNT(*) FROM access_tokens WHERE client_id = $1`, client.ID).Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Introspection", func(t *testing.T) {
+		response := introspect(accessToken)
+		assert.Equal(t, true, response["active"])
+		assert.Equal(t, client.ID, response["client_id"])
+		assert.Equal(t, "openid profile offline_access", response["scope"])
+
+		assert.Equal(t, http.StatusOK, userInfo(accessToken))
+	})
+
+	t.Run("Revocation", func(t *testing.T) {
+		w := post("/revoke", url.Values{
+			"token":         {accessToken},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Equal(t, false, introspect(accessToken)["active"])
+		assert.Equal(t, http.StatusUnauthorized, userInfo(accessToken))
+
+		// 撤销刷新令牌后不能再刷新
+		w = post("/revoke", url.Values{
+			"token":           {refreshToken},
+			"token_type_hint": {"refresh_token"},
+			"client_id":       {client.ID},
+			"client_secret":   {client.Secret},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = post("/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+
+	t.Run("Unauthenticated Revocation", func(t *testing.T) {
+		w := post("/revoke", url.Values{"token": {accessToken}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{"format": "paseto"})
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/access-token-format", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestTokenIntrospection tests authenticated introspection and JWT introspection responses (RFC 9701)
+func TestTokenIntrospection(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	// 注册资源服务器并获取内省凭据
+	register := func(identifier string) string {
+		payload, _ := json.Marshal(map[string]any{
+			"identifier": identifier,
+			"name":       identifier,
+			"scopes":     []string{"profile"},
+		})
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/resources", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		secret, _ := response["resource"].(map[string]any)["secret"].(string)
+		require.NotEmpty(t, secret)
+		return secret
+	}
+
+	orders, payments := "https://orders.example.com", "https://payments.example.com"
+	ordersSecret := register(orders)
+	paymentsSecret := register(payments)
+	defer ts.DB.Exec("DELETE FROM api_resources WHERE identifier = ANY($1)", pq.Array([]string{orders, payments}))
+
+	login := ts.LoginForAuthorization(t, phone, url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid profile"},
+		"resource":      {orders},
+	})
+	require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+	location, err := url.Parse(login.Header().Get("Location"))
+	require.NoError(t, err)
+
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ID},
+		"client_secret": {client.Secret},
+	}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	accessToken := tokens["access_token"].(string)
+
+	introspect := func(identifier, secret, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{"token": {accessToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if identifier != "" {
+			req.SetBasicAuth(url.QueryEscape(identifier), url.QueryEscape(secret))
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	active := func(t *testing.T, w *httptest.ResponseRecorder) any {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["active"]
+	}
+
+	t.Run("Authentication Required", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, introspect("", "", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, introspect(orders, "wrong-secret", "").Code)
+	})
+
+	t.Run("Audience Only", func(t *testing.T) {
+		assert.Equal(t, true, active(t, introspect(orders, ordersSecret, "")))
+		assert.Equal(t, true, active(t, introspect(client.ID, client.Secret, "")))
+
+		// 其他资源服务器看不到令牌内容
+		assert.Equal(t, false, active(t, introspect(payments, paymentsSecret, "")))
+	})
+
+	t.Run("JWT Response", func(t *testing.T) {
+		w := introspect(orders, ordersSecret, "application/token-introspection+jwt")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/token-introspection+jwt")
+
+		token, err := jwt.Parse(w.Body.String(), func(token *jwt.Token) (any, error) {
+			return ts.Config.JWTPublicKey, nil
+		}, jwt.WithoutClaimsValidation())
+		require.NoError(t, err)
+		claims := token.Claims.(jwt.MapClaims)
+
+		assert.Equal(t, "token-introspection+jwt", token.Header["typ"])
+		assert.Equal(t, orders, claims["aud"])
+		introspection := claims["token_introspection"].(map[string]any)
+		assert.Equal(t, true, introspection["active"])
+		assert.Equal(t, orders, introspection["aud"])
+	})
+
+	t.Run("Revoked Token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/revoke", strings.NewReader(url.Values{
+			"token":         {accessToken},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Equal(t, false, active(t, introspect(orders, ordersSecret, "")))
+	})
+}
+
+// TestClientTokenPolicy tests per-client token lifetimes and refresh token rules
+func TestClientTokenPolicy(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	updatePolicy := func(policy map[string]any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(policy)
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/token-policy", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	requestTokens := func() *httptest.ResponseRecorder {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid profile"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		data := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Default Policy", func(t *testing.T) {
+		req := ts.CreateAuthenticatedRequest(t, "GET", "/api/admin/clients/"+client.ID+"/token-policy", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		effective := response["effective"].(map[string]any)
+		assert.Equal(t, float64(3600), effective["access_token_lifetime"])
+		assert.Equal(t, true, effective["issue_refresh_tokens"])
+	})
+
+	t.Run("Refresh Token Of Another Client", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"profile"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		refresh := func(tokenClient *TestClient, data url.Values) *httptest.ResponseRecorder {
+			data.Set("client_id", tokenClient.ID)
+			data.Set("client_secret", tokenClient.Secret)
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := refresh(client, url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"redirect_uri": {redirectURI},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		refreshToken := tokens["refresh_token"].(string)
+
+		// 其他客户端既不能使用也不能改动该刷新令牌
+		other := ts.CreateTestClient(t, ClientOverrides{"refresh_token_idle_timeout": 1})
+		_, err = ts.DB.Exec("UPDATE refresh_tokens SET last_used_at = NOW() - INTERVAL '1 minute' WHERE token = $1", refreshToken)
+		require.NoError(t, err)
+
+		w = refresh(other, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+
+		var idle bool
+		require.NoError(t, ts.DB.QueryRow("SELECT last_used_at < NOW() - INTERVAL '30 seconds' FROM refresh_tokens WHERE token = $1", refreshToken).Scan(&idle))
+		assert.True(t, idle)
+
+		w = refresh(client, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Custom Lifetimes", func(t *testing.T) {
+		w := updatePolicy(map[string]any{"access_token_lifetime": 900, "issue_refresh_tokens": false})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = requestTokens()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Equal(t, float64(900), tokens["expires_in"])
+		assert.NotContains(t, tokens, "refresh_token")
+		assert.NotEmpty(t, tokens["id_token"])
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(tokens["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.InDelta(t, time.Now().Add(15*time.Minute).Unix(), claims["exp"], 5)
+	})
+
+	t.Run("Invalid Policy", func(t *testing.T) {
+		w := updatePolicy(map[string]any{"access_token_lifetime": -1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = updatePolicy(map[string]any{"session_lifetime": -60})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestOfflineAccess tests offline_access consent and refresh tokens bound to the SSO session
+func TestOfflineAccess(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	authorizeParams := func(scope string) url.Values {
+		return url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {scope},
+			"state":         {"offline-state"},
+		}
+	}
+
+	post := func(path string, data url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	exchange := func(t *testing.T, authorization *httptest.ResponseRecorder) map[string]any {
+		require.Equal(t, http.StatusFound, authorization.Code, authorization.Body.String())
+		location, err := url.Parse(authorization.Header().Get("Location"))
+		require.NoError(t, err)
+
+		w := post("/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens
+	}
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		return post("/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		})
+	}
+
+	t.Run("OpenID Connect Without Offline Access", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, authorizeParams("openid profile"))
+		tokens := exchange(t, login)
+		assert.NotEmpty(t, tokens["id_token"])
+		assert.NotContains(t, tokens, "refresh_token")
+	})
+
+	t.Run("Online Refresh Token Ends With Session", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, authorizeParams("profile"))
+		tokens := exchange(t, login)
+		refreshToken, _ := tokens["refresh_token"].(string)
+		require.NotEmpty(t, refreshToken)
+
+		w := refresh(refreshToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = post("/logout", url.Values{}, login.Result().Cookies()...)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = refresh(refreshToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+
+	t.Run("Offline Refresh Token Survives Logout", func(t *testing.T) {
+		params := authorizeParams("openid offline_access")
+		login := ts.LoginForAuthorization(t, phone, params)
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), "offline_access")
+
+		tokens := exchange(t, ts.AnswerConsent(t, login, params, "allow"))
+		refreshToken, _ := tokens["refresh_token"].(string)
+		require.NotEmpty(t, refreshToken)
+
+		w := post("/logout", url.Values{}, login.Result().Cookies()...)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = refresh(refreshToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Consent Denied", func(t *testing.T) {
+		params := authorizeParams("openid offline_access")
+		w := ts.AnswerConsent(t, ts.LoginForAuthorization(t, phone, params), params, "deny")
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "access_denied", location.Query().Get("error"))
+		assert.Equal(t, "offline-state", location.Query().Get("state"))
+	})
+
+	t.Run("Consent Requires Session", func(t *testing.T) {
+		params := authorizeParams("openid offline_access")
+		params.Set("decision", "allow")
+		w := post("/consent", params)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Client Without Refresh Token Grant", func(t *testing.T) {
+		ts.UpdateTestClient(t, client, ClientOverrides{"grant_types": []string{"authorization_code"}})
+
+		// 无法签发刷新令牌时忽略offline_access，不再征求同意
+		login := ts.LoginForAuthorization(t, phone, authorizeParams("openid offline_access"))
+		tokens := exchange(t, login)
+		assert.NotContains(t, tokens, "refresh_token")
+		assert.Equal(t, "openid", tokens["scope"])
+	})
+}
+
+// TestScopeRegistry tests the scope catalogue, scope validation and downscoping
+func TestScopeRegistry(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	register := func(t *testing.T, scope map[string]any) {
+		payload, _ := json.Marshal(scope)
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/scopes", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	register(t, map[string]any{"name": "orders:read", "description": "读取您的订单"})
+	register(t, map[string]any{"name": "payments:write", "description": "代您发起付款", "sensitive": true})
+	defer ts.DB.Exec("DELETE FROM scopes WHERE name = ANY($1)", pq.Array([]string{"orders:read", "payments:write"}))
+
+	authorize := func(scope string) *url.URL {
+		req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {scope},
+			"state":         {"scope-state"},
+		}.Encode(), nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+
+	t.Run("Catalogue", func(t *testing.T) {
+		req := ts.CreateAuthenticatedRequest(t, "GET", "/api/admin/scopes", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Scopes []models.Scope `json:"scopes"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		names := []string{}
+		for _, scope := range response.Scopes {
+			names = append(names, scope.Name)
+			if scope.Name == "email" {
+				assert.Contains(t, scope.Claims, "email")
+			}
+			if scope.Name == "offline_access" {
+				assert.True(t, scope.Sensitive)
+			}
+		}
+		assert.Subset(t, names, []string{"openid", "profile", "email", "phone", "offline_access", "orders:read"})
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+		assert.Contains(t, metadata["scopes_supported"], "openid")
+		assert.Contains(t, metadata["scopes_supported"], "orders:read")
+		assert.Contains(t, metadata["claims_supported"], "email_verified")
+	})
+
+	t.Run("Unknown Scope", func(t *testing.T) {
+		location := authorize("openid unknown:scope")
+		assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+		assert.Equal(t, "scope-state", location.Query().Get("state"))
+	})
+
+	t.Run("No Allowed Scope", func(t *testing.T) {
+		location := authorize("orders:read")
+		assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+	})
+
+	t.Run("Downscoping", func(t *testing.T) {
+		// 客户端未获准的已注册scope被移除
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid orders:read email"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Equal(t, "openid email", tokens["scope"])
+	})
+
+	t.Run("Sensitive Scope Requires Consent", func(t *testing.T) {
+		client := ts.CreateTestClient(t, ClientOverrides{"scope": "openid profile email payments:write"})
+
+		login := ts.LoginForAuthorization(t, phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid payments:write"},
+		})
+		assert.Equal(t, http.StatusOK, login.Code)
+		assert.Contains(t, login.Body.String(), "代您发起付款")
+	})
+
+	t.Run("Invalid Scope Name", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]any{"name": "bad scope"})
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/scopes", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestUserProfileClaims tests the release of profile, email and phone claims from /userinfo
+// and in ID tokens according to the granted scopes
+func TestUserProfileClaims(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t, ClientOverrides{"scope": "openid profile email phone"})
+	redirectURI := client.RedirectURIs[0]
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	profile, _ := json.Marshal(map[string]any{
+		"name":           "张三",
+		"nickname":       "小张",
+		"locale":         "zh-CN",
+		"email":          "zhangsan@example.com",
+		"email_verified": true,
+	})
+	req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/users/"+strconv.Itoa(user.ID)+"/profile", profile)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	defer ts.DB.Exec("UPDATE users SET name = NULL, nickname = NULL, locale = NULL, email = NULL, email_verified = FALSE WHERE id = $1", user.ID)
+
+	authorize := func(t *testing.T, scope string) (idToken jwt.MapClaims, userInfo map[string]any) {
+		login := ts.LoginForAuthorization(t, user.Phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {scope},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+		idToken = jwt.MapClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(tokens["id_token"].(string), idToken)
+		require.NoError(t, err)
+
+		req = httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &userInfo))
+
+		return idToken, userInfo
+	}
+
+	t.Run("Profile And Email Scopes", func(t *testing.T) {
+		idToken, userInfo := authorize(t, "openid profile email")
+
+		for _, claims := range []map[string]any{idToken, userInfo} {
+			assert.Equal(t, strconv.Itoa(user.ID), claims["sub"])
+			assert.Equal(t, "张三", claims["name"])
+			assert.Equal(t, "小张", claims["nickname"])
+			assert.Equal(t, "zh-CN", claims["locale"])
+			assert.Equal(t, "zhangsan@example.com", claims["email"])
+			assert.Equal(t, true, claims["email_verified"])
+			assert.NotContains(t, claims, "picture")
+			assert.NotContains(t, claims, "phone_number")
+		}
+	})
+
+	t.Run("Openid Only", func(t *testing.T) {
+		idToken, userInfo := authorize(t, "openid")
+
+		assert.Equal(t, strconv.Itoa(user.ID), userInfo["sub"])
+		assert.NotContains(t, userInfo, "name")
+		assert.NotContains(t, userInfo, "email")
+		assert.NotContains(t, idToken, "name")
+	})
+
+	t.Run("Phone Scope", func(t *testing.T) {
+		idToken, userInfo := authorize(t, "openid phone")
+
+		// 短信登录即确认手机号
+		for _, claims := range []map[string]any{idToken, userInfo} {
+			assert.Equal(t, user.Phone, claims["phone_number"])
+			assert.Equal(t, true, claims["phone_number_verified"])
+			assert.NotContains(t, claims, "email")
+		}
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/users/999999999/profile", profile)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// TestClaimsParameter tests the claims request parameter, POST requests to /userinfo and
+// signed UserInfo responses
+func TestClaimsParameter(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	_, err := ts.DB.Exec("UPDATE users SET name = '张三', email = 'zhangsan@example.com', email_verified = TRUE WHERE id = $1", user.ID)
+	require.NoError(t, err)
+	defer ts.DB.Exec("UPDATE users SET name = NULL, email = NULL, email_verified = FALSE WHERE id = $1", user.ID)
+
+	authorize := func(t *testing.T, claims string) map[string]any {
+		login := ts.LoginForAuthorization(t, user.Phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"claims":        {claims},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens
+	}
+
+	userInfo := func(t *testing.T, accessToken string) map[string]any {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+		return claims
+	}
+
+	t.Run("Claims Per Response", func(t *testing.T) {
+		tokens := authorize(t, `{"id_token":{"email":{"essential":true}},"userinfo":{"name":null}}`)
+
+		idToken := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(tokens["id_token"].(string), idToken)
+		require.NoError(t, err)
+		assert.Equal(t, "zhangsan@example.com", idToken["email"])
+		assert.NotContains(t, idToken, "name")
+
+		claims := userInfo(t, tokens["access_token"].(string))
+		assert.Equal(t, "张三", claims["name"])
+		assert.NotContains(t, claims, "email")
+	})
+
+	t.Run("Claims Outside Allowed Scopes", func(t *testing.T) {
+		// 客户端未获准phone scope，手机号声明被忽略
+		tokens := authorize(t, `{"userinfo":{"phone_number":{"essential":true},"name":null}}`)
+
+		claims := userInfo(t, tokens["access_token"].(string))
+		assert.Equal(t, "张三", claims["name"])
+		assert.NotContains(t, claims, "phone_number")
+	})
+
+	t.Run("Claims Of Sensitive Scope Need Consent", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]any{"name": "contacts", "description": "读取您的联系方式", "sensitive": true, "claims": []string{"phone_number"}})
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/scopes", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		defer ts.DB.Exec("DELETE FROM scopes WHERE name = 'contacts'")
+
+		sensitive := ts.CreateTestClient(t, ClientOverrides{"scope": "openid contacts"})
+		params := url.Values{
+			"client_id":     {sensitive.ID},
+			"redirect_uri":  {sensitive.RedirectURIs[0]},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"claims":        {`{"userinfo":{"phone_number":null}}`},
+		}
+
+		// 仅请求openid，但声明属于敏感scope，仍需用户同意
+		login := ts.LoginForAuthorization(t, user.Phone, params)
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), "读取您的联系方式")
+
+		consent := ts.AnswerConsent(t, login, params, "deny")
+		require.Equal(t, http.StatusFound, consent.Code, consent.Body.String())
+		location, err := url.Parse(consent.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "access_denied", location.Query().Get("error"))
+	})
+
+	t.Run("Invalid Claims Parameter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"claims":        {"not-json"},
+		}.Encode(), nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	})
+
+	t.Run("POST UserInfo", func(t *testing.T) {
+		tokens := authorize(t, `{"userinfo":{"email":null}}`)
+
+		req := httptest.NewRequest("POST", "/userinfo", strings.NewReader(url.Values{
+			"access_token": {tokens["access_token"].(string)},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+		assert.Equal(t, strconv.Itoa(user.ID), claims["sub"])
+		assert.Equal(t, "zhangsan@example.com", claims["email"])
+	})
+
+	t.Run("Signed UserInfo", func(t *testing.T) {
+		ts.UpdateTestClient(t, client, ClientOverrides{"userinfo_signed_response_alg": "RS256"})
+
+		tokens := authorize(t, `{"userinfo":{"name":null}}`)
+
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/jwt")
+
+		claims := jwt.MapClaims{}
+		token, _, err := jwt.NewParser().ParseUnverified(w.Body.String(), claims)
+		require.NoError(t, err)
+		assert.Equal(t, "RS256", token.Header["alg"])
+		assert.Equal(t, client.ID, claims["aud"])
+		assert.NotEmpty(t, claims["iss"])
+		assert.Equal(t, strconv.Itoa(user.ID), claims["sub"])
+		assert.Equal(t, "张三", claims["name"])
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+		assert.Equal(t, true, metadata["claims_parameter_supported"])
+		assert.Contains(t, metadata["userinfo_signing_alg_values_supported"], "RS256")
+	})
+}
+
+// TestEncryptedIDTokens tests ID tokens encrypted to the client's registered keys (JWE)
+func TestEncryptedIDTokens(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "rsa-enc",
+			"use": "enc",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC",
+			"kid": "ec-enc",
+			"use": "enc",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}})
+	require.NoError(t, err)
+
+	client := ts.CreateTestClient(t, ClientOverrides{"jwks": string(jwks)})
+	redirectURI := client.RedirectURIs[0]
+
+	issueIDToken := func(t *testing.T, alg string) string {
+		ts.UpdateTestClient(t, client, ClientOverrides{
+			"id_token_encrypted_response_alg": alg,
+			"id_token_encrypted_response_enc": "A256GCM",
+		})
+
+		login := ts.LoginForAuthorization(t, user.Phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens["id_token"].(string)
+	}
+
+	jweHeader := func(t *testing.T, token string) map[string]any {
+		parts := strings.Split(token, ".")
+		require.Len(t, parts, 5)
+		decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+		require.NoError(t, err)
+
+		var header map[string]any
+		require.NoError(t, json.Unmarshal(decoded, &header))
+		return header
+	}
+
+	t.Run("RSA-OAEP-256", func(t *testing.T) {
+		token := issueIDToken(t, "RSA-OAEP-256")
+
+		header := jweHeader(t, token)
+		assert.Equal(t, "RSA-OAEP-256", header["alg"])
+		assert.Equal(t, "A256GCM", header["enc"])
+		assert.Equal(t, "JWT", header["cty"])
+		assert.Equal(t, "rsa-enc", header["kid"])
+
+		// 客户端私钥解密内容加密密钥，再解密出签名的ID令牌
+		parts := strings.Split(token, ".")
+		encryptedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		cek, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, rsaKey, encryptedKey, nil)
+		require.NoError(t, err)
+
+		iv, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+		require.NoError(t, err)
+		tag, err := base64.RawURLEncoding.DecodeString(parts[4])
+		require.NoError(t, err)
+
+		block, err := aes.NewCipher(cek)
+		require.NoError(t, err)
+		gcm, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+		signed, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+		require.NoError(t, err)
+
+		claims := jwt.MapClaims{}
+		inner, _, err := jwt.NewParser().ParseUnverified(string(signed), claims)
+		require.NoError(t, err)
+		assert.Equal(t, "RS256", inner.Header["alg"])
+		assert.Equal(t, strconv.Itoa(user.ID), claims["sub"])
+		assert.Equal(t, client.ID, claims["aud"])
+	})
+
+	t.Run("ECDH-ES", func(t *testing.T) {
+		token := issueIDToken(t, "ECDH-ES")
+
+		header := jweHeader(t, token)
+		assert.Equal(t, "ECDH-ES", header["alg"])
+		assert.Equal(t, "ec-enc", header["kid"])
+		epk := header["epk"].(map[string]any)
+		assert.Equal(t, "EC", epk["kty"])
+		assert.Equal(t, "P-256", epk["crv"])
+
+		// 直接密钥协商不携带加密密钥
+		assert.Empty(t, strings.Split(token, ".")[1])
+	})
+
+	t.Run("Registration Validation", func(t *testing.T) {
+		developer := ts.RegisterTestDeveloper(t)
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/developers/"+developer.ID+"/initial-access-tokens", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var issued map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+
+		// 元数据校验失败时初始访问令牌不会被消耗
+		for name, metadata := range map[string]map[string]any{
+			"Unsupported Enc": {
+				"redirect_uris":                   []string{"https://partner.example.com/callback"},
+				"jwks":                            json.RawMessage(jwks),
+				"id_token_encrypted_response_alg": "RSA-OAEP-256",
+				"id_token_encrypted_response_enc": "A128CBC-HS256",
+			},
+			"No Encryption Key": {
+				"redirect_uris":                   []string{"https://partner.example.com/callback"},
+				"id_token_encrypted_response_alg": "ECDH-ES",
+			},
+		} {
+			payload, _ := json.Marshal(metadata)
+			req := httptest.NewRequest("POST", "/register", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+issued["initial_access_token"].(string))
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Contains(t, w.Body.String(), "invalid_client_metadata", name)
+		}
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var discovery map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+		assert.Contains(t, discovery["id_token_encryption_alg_values_supported"], "RSA-OAEP-256")
+		assert.Contains(t, discovery["id_token_encryption_alg_values_supported"], "ECDH-ES")
+		assert.Contains(t, discovery["id_token_encryption_enc_values_supported"], "A256GCM")
+	})
+}
+
+// TestPairwiseSubjects tests pairwise subject identifiers derived per sector (OpenID Connect Core 1.0 Section 8)
+func TestPairwiseSubjects(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	standard := ts.CreateTestClient(t)
+	// 另一扇区的客户端
+	partner := ts.CreateTestClient(t, ClientOverrides{
+		"redirect_uris": []string{"https://app.example.com/callback"},
+		"subject_type":  "pairwise",
+	})
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	authorize := func(t *testing.T, client *TestClient) map[string]any {
+		login := ts.LoginForAuthorization(t, user.Phone, url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {client.RedirectURIs[0]},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+		})
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {client.RedirectURIs[0]},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens
+	}
+
+	subjectOf := func(t *testing.T, token string) string {
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+		require.NoError(t, err)
+		return claims["sub"].(string)
+	}
+
+	t.Run("Public Subject", func(t *testing.T) {
+		tokens := authorize(t, standard)
+		assert.Equal(t, strconv.Itoa(user.ID), subjectOf(t, tokens["id_token"].(string)))
+	})
+
+	ts.UpdateTestClient(t, standard, ClientOverrides{"subject_type": "pairwise"})
+
+	t.Run("Pairwise Subjects Per Sector", func(t *testing.T) {
+		first := authorize(t, standard)
+		sub := subjectOf(t, first["id_token"].(string))
+		assert.NotEqual(t, strconv.Itoa(user.ID), sub)
+		assert.Equal(t, sub, subjectOf(t, first["access_token"].(string)))
+
+		// 同一扇区内稳定
+		second := authorize(t, standard)
+		assert.Equal(t, sub, subjectOf(t, second["id_token"].(string)))
+
+		// 不同扇区无法关联
+		other := authorize(t, partner)
+		assert.NotEqual(t, sub, subjectOf(t, other["id_token"].(string)))
+	})
+
+	t.Run("Resolve Pairwise Subject", func(t *testing.T) {
+		tokens := authorize(t, standard)
+		sub := subjectOf(t, tokens["id_token"].(string))
+
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+		assert.Equal(t, sub, claims["sub"])
+
+		req = httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{
+			"token":         {tokens["access_token"].(string)},
+			"client_id":     {standard.ID},
+			"client_secret": {standard.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var introspection map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &introspection))
+		assert.Equal(t, true, introspection["active"])
+		assert.Equal(t, sub, introspection["sub"])
+	})
+
+	t.Run("Sector Identifier", func(t *testing.T) {
+		tokens := authorize(t, standard)
+		sub := subjectOf(t, tokens["id_token"].(string))
+
+		// 扇区由sector_identifier_uri决定
+		ts.UpdateTestClient(t, standard, ClientOverrides{"sector_identifier_uri": "https://app.example.com/sector.json"})
+
+		shared := authorize(t, standard)
+		assert.NotEqual(t, sub, subjectOf(t, shared["id_token"].(string)))
+		other := authorize(t, partner)
+		assert.Equal(t, subjectOf(t, other["id_token"].(string)), subjectOf(t, shared["id_token"].(string)))
+	})
+
+	t.Run("Registration Validation", func(t *testing.T) {
+		developer := ts.RegisterTestDeveloper(t)
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/developers/"+developer.ID+"/initial-access-tokens", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var issued map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+
+		for name, metadata := range map[string]map[string]any{
+			"Unsupported Subject Type": {
+				"redirect_uris": []string{"https://partner.example.com/callback"},
+				"subject_type":  "random",
+			},
+			"Several Hosts Without Sector": {
+				"redirect_uris": []string{"https://partner.example.com/callback", "https://partner.example.org/callback"},
+				"subject_type":  "pairwise",
+			},
+		} {
+			payload, _ := json.Marshal(metadata)
+			req := httptest.NewRequest("POST", "/register", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+issued["initial_access_token"].(string))
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Contains(t, w.Body.String(), "invalid_client_metadata", name)
+		}
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var discovery map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+		assert.Contains(t, discovery["subject_types_supported"], "pairwise")
+	})
+
+	t.Run("Salt Required", func(t *testing.T) {
+		// 未配置盐值时不支持成对标识符，已有成对客户端时拒绝启动
+		unsalted := services.NewSubjectService(ts.DB, "")
+		assert.Equal(t, []string{services.SubjectTypePublic}, unsalted.SupportedSubjectTypes())
+		assert.Error(t, unsalted.CheckSalt())
+		assert.NoError(t, services.NewSubjectService(ts.DB, "test-pairwise-subject-salt").CheckSalt())
+	})
+}
+
+// TestRPInitiatedLogout tests the end-session endpoint (OpenID Connect RP-Initiated Logout 1.0)
+func TestRPInitiatedLogout(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	postLogoutRedirectURI := "https://app.example.com/logged-out"
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"post_logout_redirect_uris": []string{postLogoutRedirectURI},
+	})
+	redirectURI := client.RedirectURIs[0]
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	authorizeParams := url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+	}
+
+	// 登录并换取ID令牌，返回会话Cookie
+	login := func(t *testing.T) (string, []*http.Cookie) {
+		w := ts.LoginForAuthorization(t, user.Phone, authorizeParams)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		tokenResponse := httptest.NewRecorder()
+		ts.Router.ServeHTTP(tokenResponse, req)
+		require.Equal(t, http.StatusOK, tokenResponse.Code, tokenResponse.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(tokenResponse.Body.Bytes(), &tokens))
+		return tokens["id_token"].(string), w.Result().Cookies()
+	}
+
+	send := func(method string, params url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == "GET" {
+			req = httptest.NewRequest("GET", "/logout?"+params.Encode(), nil)
+		} else {
+			req = httptest.NewRequest("POST", "/logout", strings.NewReader(params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 会话有效时授权请求直接重定向，否则显示登录页面
+	sessionActive := func(cookies []*http.Cookie) bool {
+		req := httptest.NewRequest("GET", "/authorize?"+authorizeParams.Encode(), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w.Code == http.StatusFound
+	}
+
+	t.Run("Logout With ID Token Hint", func(t *testing.T) {
+		idToken, cookies := login(t)
+		require.True(t, sessionActive(cookies))
+
+		w := send("GET", url.Values{
+			"id_token_hint":            {idToken},
+			"post_logout_redirect_uri": {postLogoutRedirectURI},
+			"state":                    {"logout-state"},
+		}, cookies)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		assert.Equal(t, postLogoutRedirectURI+"?state=logout-state", w.Header().Get("Location"))
+		assert.False(t, sessionActive(cookies))
+	})
+
+	t.Run("GET Without ID Token Hint Asks For Confirmation", func(t *testing.T) {
+		_, cookies := login(t)
+
+		w := send("GET", url.Values{"client_id": {client.ID}}, cookies)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `action="/logout"`)
+		assert.True(t, sessionActive(cookies))
+
+		w = send("POST", url.Values{"client_id": {client.ID}}, cookies)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, sessionActive(cookies))
+	})
+
+	t.Run("Unregistered Post Logout Redirect URI", func(t *testing.T) {
+		idToken, cookies := login(t)
+
+		w := send("GET", url.Values{
+			"id_token_hint":            {idToken},
+			"post_logout_redirect_uri": {"https://evil.example.com/"},
+		}, cookies)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+		assert.True(t, sessionActive(cookies))
+	})
+
+	t.Run("Redirect Requires Client", func(t *testing.T) {
+		w := send("GET", url.Values{"post_logout_redirect_uri": {postLogoutRedirectURI}}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Client ID Must Match ID Token Hint", func(t *testing.T) {
+		idToken, _ := login(t)
+
+		w := send("GET", url.Values{"id_token_hint": {idToken}, "client_id": {"another-client"}}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var discovery map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+		assert.Equal(t, ts.Config.BaseURL+"/logout", discovery["end_session_endpoint"])
+	})
+}
+
+// TestBackchannelLogout tests logout notifications to the clients of a session
+// (OpenID Connect Back-Channel Logout 1.0 and Front-Channel Logout 1.0)
+func TestBackchannelLogout(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	// 客户端的后端通道登出端点
+	logoutTokens := make(chan string, 1)
+	rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens <- r.PostFormValue("logout_token")
+	}))
+	defer rp.Close()
+	frontchannelLogoutURI := "https://app.example.com/frontchannel-logout"
+
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"backchannel_logout_uri":  rp.URL + "/backchannel-logout",
+		"frontchannel_logout_uri": frontchannelLogoutURI,
+	})
+	redirectURI := client.RedirectURIs[0]
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	w := ts.LoginForAuthorization(t, user.Phone, url.Values{
+		"client_id":     {client.ID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+	})
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	cookies := w.Result().Cookies()
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ID},
+		"client_secret": {client.Secret},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenResponse := httptest.NewRecorder()
+	ts.Router.ServeHTTP(tokenResponse, req)
+	require.Equal(t, http.StatusOK, tokenResponse.Code, tokenResponse.Body.String())
+
+	var tokens map[string]any
+	require.NoError(t, json.Unmarshal(tokenResponse.Body.Bytes(), &tokens))
+	idTokenClaims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tokens["id_token"].(string), idTokenClaims)
+	require.NoError(t, err)
+	sid, _ := idTokenClaims["sid"].(string)
+
+	t.Run("ID Token Carries Session ID", func(t *testing.T) {
+		assert.NotEmpty(t, sid)
+		for _, cookie := range cookies {
+			assert.NotEqual(t, cookie.Value, sid, "the sid must not reveal the session cookie")
+		}
+	})
+
+	var logoutPage *httptest.ResponseRecorder
+	t.Run("Logout Notifies Clients", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/logout", strings.NewReader(url.Values{
+			"id_token_hint": {tokens["id_token"].(string)},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		logoutPage = httptest.NewRecorder()
+		ts.Router.ServeHTTP(logoutPage, req)
+		require.Equal(t, http.StatusOK, logoutPage.Code, logoutPage.Body.String())
+
+		var logoutToken string
+		select {
+		case logoutToken = <-logoutTokens:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no logout token delivered")
+		}
+
+		token, err := jwt.Parse(logoutToken, func(token *jwt.Token) (any, error) {
+			return ts.Config.JWTPublicKey, nil
+		}, jwt.WithAudience(client.ID), jwt.WithIssuer(ts.Config.Issuer))
+		require.NoError(t, err)
+		claims := token.Claims.(jwt.MapClaims)
+
+		assert.Equal(t, "logout+jwt", token.Header["typ"])
+		assert.Equal(t, sid, claims["sid"])
+		assert.Equal(t, idTokenClaims["sub"], claims["sub"])
+		assert.NotContains(t, claims, "nonce")
+		events, _ := claims["events"].(map[string]any)
+		assert.Contains(t, events, "http://schemas.openid.net/event/backchannel-logout")
+	})
+
+	t.Run("Front-Channel Logout Page", func(t *testing.T) {
+		require.NotNil(t, logoutPage)
+		frontchannelURL := frontchannelLogoutURI + "?" + url.Values{"iss": {ts.Config.Issuer}, "sid": {sid}}.Encode()
+		assert.Contains(t, logoutPage.Body.String(), `<iframe src="`+strings.ReplaceAll(frontchannelURL, "&", "&amp;")+`"`)
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var discovery map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+		assert.Equal(t, true, discovery["backchannel_logout_supported"])
+		assert.Equal(t, true, discovery["backchannel_logout_session_supported"])
+		assert.Equal(t, true, discovery["frontchannel_logout_supported"])
+		assert.Equal(t, true, discovery["frontchannel_logout_session_supported"])
+	})
+}
+
+// TestCIBA tests Client-Initiated Backchannel Authentication in poll and ping mode
+// (OpenID Connect CIBA Core 1.0)
+func TestCIBA(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	grantType := "urn:openid:params:grant-type:ciba"
+	client := ts.CreateTestClient(t, ClientOverrides{
+		"grant_types":                     []string{"authorization_code", "refresh_token", grantType},
+		"backchannel_token_delivery_mode": "poll",
+	})
+	user := ts.CreateTestUserWithType(t, DefaultUserType)
+
+	post := func(path string, params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(client.ID, client.Secret)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 发起认证请求，返回auth_req_id和短信中的确认链接
+	authenticate := func(t *testing.T, params url.Values) (string, string) {
+		w := post("/bc-authorize", params)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, float64(5), response["interval"])
+		assert.NotZero(t, response["expires_in"])
+
+		smsService, ok := ts.Handler.GetSMSService().(*services.MockSMSService)
+		require.True(t, ok)
+		link, err := url.Parse(smsService.GetLastApprovalLink(user.Phone))
+		require.NoError(t, err)
+		assert.Equal(t, "/bc-authorize/approve", link.Path)
+		return response["auth_req_id"].(string), link.Query().Get("token")
+	}
+
+	answer := func(t *testing.T, approvalToken, decision string) {
+		req := httptest.NewRequest("GET", "/bc-authorize/approve?"+url.Values{"token": {approvalToken}}.Encode(), nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `action="/bc-authorize/approve"`)
+
+		req = httptest.NewRequest("POST", "/bc-authorize/approve", strings.NewReader(url.Values{
+			"token":    {approvalToken},
+			"decision": {decision},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	poll := func(authReqID string) (int, map[string]any) {
+		w := post("/token", url.Values{"grant_type": {grantType}, "auth_req_id": {authReqID}})
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Poll Mode", func(t *testing.T) {
+		authReqID, approvalToken := authenticate(t, url.Values{
+			"scope":           {"openid profile"},
+			"login_hint":      {user.Phone},
+			"binding_message": {"W4SCT"},
+		})
+
+		code, response := poll(authReqID)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "authorization_pending", response["error"])
+
+		code, response = poll(authReqID)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "slow_down", response["error"])
+
+		answer(t, approvalToken, "allow")
+
+		code, response = poll(authReqID)
+		require.Equal(t, http.StatusOK, code, response)
+		assert.NotEmpty(t, response["access_token"])
+		assert.Equal(t, "openid profile", response["scope"])
+
+		idTokenClaims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(response["id_token"].(string), idTokenClaims)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(user.ID), idTokenClaims["sub"])
+		assert.Equal(t, client.ID, idTokenClaims["aud"])
+
+		// 通过短信链接确认即为短信认证
+		accessTokenClaims := jwt.MapClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(response["access_token"].(string), accessTokenClaims)
+		require.NoError(t, err)
+		assert.Equal(t, "urn:flash-oauth2:acr:sms", accessTokenClaims["acr"])
+
+		// 令牌只签发一次
+		code, response = poll(authReqID)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid_grant", response["error"])
+	})
+
+	t.Run("User Denies", func(t *testing.T) {
+		authReqID, approvalToken := authenticate(t, url.Values{"scope": {"openid"}, "login_hint": {user.Phone}})
+		answer(t, approvalToken, "deny")
+
+		code, response := poll(authReqID)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "access_denied", response["error"])
+	})
+
+	t.Run("Concurrent Answers", func(t *testing.T) {
+		authReqID, approvalToken := authenticate(t, url.Values{"scope": {"openid"}, "login_hint": {user.Phone}})
+
+		// 同一链接并发提交允许和拒绝，只有一个答复生效
+		codes := make(chan int, 6)
+		var wg sync.WaitGroup
+		for i := 0; i < cap(codes); i++ {
+			decision := "allow"
+			if i%2 == 1 {
+				decision = "deny"
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/bc-authorize/approve", strings.NewReader(url.Values{
+					"token":    {approvalToken},
+					"decision": {decision},
+				}.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				w := httptest.NewRecorder()
+				ts.Router.ServeHTTP(w, req)
+				codes <- w.Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		answered := 0
+		for code := range codes {
+			if code == http.StatusOK {
+				answered++
+			}
+		}
+		assert.Equal(t, 1, answered)
+
+		code, response := poll(authReqID)
+		assert.NotEqual(t, "authorization_pending", response["error"], code)
+	})
+
+	t.Run("Answer While Polling", func(t *testing.T) {
+		authReqID, approvalToken := authenticate(t, url.Values{"scope": {"openid"}, "login_hint": {user.Phone}})
+
+		// 轮询与答复并发，轮询不能覆盖答复
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				poll(authReqID)
+			}()
+		}
+		req := httptest.NewRequest("POST", "/bc-authorize/approve", strings.NewReader(url.Values{
+			"token":    {approvalToken},
+			"decision": {"allow"},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		wg.Wait()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		code, response := poll(authReqID)
+		assert.Equal(t, http.StatusOK, code, response)
+		assert.NotEmpty(t, response["access_token"])
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		w := post("/bc-authorize", url.Values{"scope": {"openid"}, "login_hint": {"19999999999"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown_user_id")
+	})
+
+	t.Run("Scope Must Include OpenID", func(t *testing.T) {
+		w := post("/bc-authorize", url.Values{"scope": {"profile"}, "login_hint": {user.Phone}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+	})
+
+	t.Run("Ping Mode", func(t *testing.T) {
+		pings := make(chan *http.Request, 1)
+		pingBodies := make(chan map[string]any, 1)
+		rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			pings <- r
+			pingBodies <- body
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer rp.Close()
+
+		ts.UpdateTestClient(t, client, ClientOverrides{
+			"backchannel_token_delivery_mode":          "ping",
+			"backchannel_client_notification_endpoint": rp.URL + "/ciba-callback",
+		})
+
+		w := post("/bc-authorize", url.Values{"scope": {"openid"}, "login_hint": {user.Phone}})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "client_notification_token is required in ping mode")
+
+		authReqID, approvalToken := authenticate(t, url.Values{
+			"scope":                     {"openid"},
+			"login_hint":                {user.Phone},
+			"client_notification_token": {"notification-token-123"},
+		})
+		answer(t, approvalToken, "allow")
+
+		select {
+		case ping := <-pings:
+			assert.Equal(t, "Bearer notification-token-123", ping.Header.Get("Authorization"))
+			assert.Equal(t, authReqID, (<-pingBodies)["auth_req_id"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no ping received")
+		}
+
+		code, response := poll(authReqID)
+		require.Equal(t, http.StatusOK, code, response)
+		assert.NotEmpty(t, response["id_token"])
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var discovery map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+		assert.Equal(t, ts.Config.BaseURL+"/bc-authorize", discovery["backchannel_authentication_endpoint"])
+		assert.Contains(t, discovery["grant_types_supported"], grantType)
+		assert.ElementsMatch(t, []any{"poll", "ping"}, discovery["backchannel_token_delivery_modes_supported"])
+	})
+}
+
+// TestRichAuthorizationRequests tests authorization_details (RFC 9396) from consent to introspection
+func TestRichAuthorizationRequests(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t)
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+
+	payload, _ := json.Marshal(map[string]any{
+		"type":        "payment_initiation",
+		"description": "发起付款",
+		"fields":      []string{"instructedAmount", "creditorName", "creditorAccount"},
+	})
+	req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/authorization-details-types", payload)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	defer ts.DB.Exec("DELETE FROM authorization_details_types WHERE type = 'payment_initiation'")
+
+	payment := `[{"type":"payment_initiation","actions":["initiate"],"instructedAmount":{"currency":"CNY","amount":"500.00"},"creditorName":"张三","creditorAccount":{"iban":"DE02100100109307118603"}}]`
+	params := url.Values{
+		"client_id":             {client.ID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid"},
+		"state":                 {"rar-state"},
+		"authorization_details": {payment},
+	}
+
+	// 用户确认授权详情后获取授权码
+	approve := func(t *testing.T) string {
+		login := ts.LoginForAuthorization(t, phone, params)
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), "发起付款")
+		assert.Contains(t, login.Body.String(), "creditorName: 张三")
+
+		consent := ts.AnswerConsent(t, login, params, "allow")
+		require.Equal(t, http.StatusFound, consent.Code, consent.Body.String())
+		location, err := url.Parse(consent.Header().Get("Location"))
+		require.NoError(t, err)
+		require.NotEmpty(t, location.Query().Get("code"))
+		return location.Query().Get("code")
+	}
+
+	token := func(t *testing.T, data url.Values) *httptest.ResponseRecorder {
+		data.Set("client_id", client.ID)
+		data.Set("client_secret", client.Secret)
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(data.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	authorizeError := func(t *testing.T, details string) string {
+		req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+			"client_id":             {client.ID},
+			"redirect_uri":          {redirectURI},
+			"response_type":         {"code"},
+			"scope":                 {"openid"},
+			"authorization_details": {details},
+		}.Encode(), nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location.Query().Get("error")
+	}
+
+	var granted []any
+	require.NoError(t, json.Unmarshal([]byte(payment), &granted))
+
+	t.Run("Consent To Tokens", func(t *testing.T) {
+		w := token(t, url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {approve(t)},
+			"redirect_uri": {redirectURI},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Equal(t, granted, tokens["authorization_details"])
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(tokens["access_token"].(string), claims)
+		require.NoError(t, err)
+		assert.Equal(t, granted, claims["authorization_details"])
+
+		req := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{
+			"token":         {tokens["access_token"].(string)},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		introspection := httptest.NewRecorder()
+		ts.Router.ServeHTTP(introspection, req)
+		require.Equal(t, http.StatusOK, introspection.Code, introspection.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(introspection.Body.Bytes(), &response))
+		assert.Equal(t, true, response["active"])
+		assert.Equal(t, granted, response["authorization_details"])
+	})
+
+	t.Run("Token Request Beyond Grant", func(t *testing.T) {
+		w := token(t, url.Values{
+			"grant_type":            {"authorization_code"},
+			"code":                  {approve(t)},
+			"redirect_uri":          {redirectURI},
+			"authorization_details": {`[{"type":"payment_initiation","instructedAmount":{"currency":"CNY","amount":"5000.00"}}]`},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_authorization_details")
+	})
+
+	t.Run("Unknown Type", func(t *testing.T) {
+		assert.Equal(t, "invalid_authorization_details", authorizeError(t, `[{"type":"account_information"}]`))
+	})
+
+	t.Run("Unregistered Field", func(t *testing.T) {
+		assert.Equal(t, "invalid_authorization_details", authorizeError(t, `[{"type":"payment_initiation","debtorAccount":{"iban":"DE40100100103307118608"}}]`))
+	})
+
+	t.Run("Not An Array", func(t *testing.T) {
+		assert.Equal(t, "invalid_authorization_details", authorizeError(t, `{"type":"payment_initiation"}`))
+	})
+
+	t.Run("Pushed Authorization Request", func(t *testing.T) {
+		push := func(details string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/par", strings.NewReader(url.Values{
+				"response_type":         {"code"},
+				"redirect_uri":          {redirectURI},
+				"scope":                 {"openid"},
+				"authorization_details": {details},
+			}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(client.ID, client.Secret)
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusCreated, push(payment).Code)
+
+		w := push(`[{"type":"account_information"}]`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_authorization_details")
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+		assert.Contains(t, metadata["authorization_details_types_supported"], "payment_initiation")
+	})
+}
+
+// TestStepUpAuthentication tests authentication levels (acr), authenticator app step-up and
+// insufficient_user_authentication at protected APIs (RFC 9470)
+func TestStepUpAuthentication(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	client := ts.CreateTestClient(t, ClientOverrides{"scope": "openid profile email transfers"})
+	redirectURI := client.RedirectURIs[0]
+	phone := ts.DataManager.GetTestUsers()[DefaultUserType].Phone
+	user := ts.CreateTestUser(t, phone)
+
+	payload, _ := json.Marshal(map[string]any{"name": "transfers", "description": "转账", "minimum_acr": services.ACRPhoneSMSTOTP})
+	req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/scopes", payload)
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	defer ts.DB.Exec("DELETE FROM scopes WHERE name = 'transfers'")
+
+	req = ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/users/"+strconv.Itoa(user.ID)+"/totp", nil)
+	w = httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	defer ts.DB.Exec("UPDATE users SET totp_secret = NULL WHERE id = $1", user.ID)
+
+	var enrollment map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+	secret := enrollment["secret"].(string)
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+
+	// 每个动态码只能使用一次，按时间窗口取不同的码
+	totpCode := func(t *testing.T, periods int) string {
+		code, err := services.TOTPCode(secret, time.Now().Add(time.Duration(periods)*services.TOTPPeriod))
+		require.NoError(t, err)
+		return code
+	}
+
+	params := func(scope string) url.Values {
+		return url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"scope":         {scope},
+			"state":         {"step-up-state"},
+		}
+	}
+
+	authorizationCode := func(t *testing.T, login *httptest.ResponseRecorder) string {
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+		require.NotEmpty(t, location.Query().Get("code"), location.String())
+		return location.Query().Get("code")
+	}
+
+	exchange := func(t *testing.T, code string) map[string]any {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"client_id":     {client.ID},
+			"client_secret": {client.Secret},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		return tokens
+	}
+
+	acrClaim := func(t *testing.T, token string) any {
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+		require.NoError(t, err)
+		return claims["acr"]
+	}
+
+	// 受保护的API要求使用身份验证器登录
+	api := gin.New()
+	api.GET("/transfers", ts.Handler.RequireAccessToken("", services.ACRPhoneSMSTOTP), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"sub": handlers.AccessTokenClaims(c).Subject})
+	})
+	callAPI := func(accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/transfers", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("SMS Login Asks For Authenticator Code", func(t *testing.T) {
+		login := ts.LoginForAuthorization(t, phone, params("openid transfers"))
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), `name="totp_code"`)
+	})
+
+	var strongToken string
+	t.Run("Step-Up Login", func(t *testing.T) {
+		data := params("openid transfers")
+		data.Set("totp_code", totpCode(t, 0))
+		tokens := exchange(t, authorizationCode(t, ts.LoginForAuthorization(t, phone, data)))
+		assert.Equal(t, "openid transfers", tokens["scope"])
+		assert.Equal(t, services.ACRPhoneSMSTOTP, acrClaim(t, tokens["id_token"].(string)))
+		assert.Equal(t, services.ACRPhoneSMSTOTP, acrClaim(t, tokens["access_token"].(string)))
+		strongToken = tokens["access_token"].(string)
+	})
+
+	t.Run("Replayed Authenticator Code", func(t *testing.T) {
+		data := params("openid transfers")
+		data.Set("totp_code", totpCode(t, 0))
+		login := ts.LoginForAuthorization(t, phone, data)
+		assert.Equal(t, http.StatusUnauthorized, login.Code)
+		assert.Contains(t, login.Body.String(), "invalid_totp_code")
+	})
+
+	t.Run("ACR Values", func(t *testing.T) {
+		data := params("openid")
+		data.Set("acr_values", services.ACRPhoneSMSTOTP)
+		login := ts.LoginForAuthorization(t, phone, data)
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), `name="totp_code"`)
+	})
+
+	t.Run("Client Minimum ACR", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]any{"minimum_acr": services.ACRPhoneSMSTOTP})
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/minimum-acr", payload)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		defer func() {
+			// 空值接受任何登录
+			payload, _ := json.Marshal(map[string]any{"minimum_acr": ""})
+			req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/minimum-acr", payload)
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}()
+
+		login := ts.LoginForAuthorization(t, phone, params("openid"))
+		require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+		assert.Contains(t, login.Body.String(), `name="totp_code"`)
+
+		payload, _ = json.Marshal(map[string]any{"minimum_acr": "urn:example:unknown"})
+		req = ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+client.ID+"/minimum-acr", payload)
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Insufficient User Authentication", func(t *testing.T) {
+		weak := exchange(t, authorizationCode(t, ts.LoginForAuthorization(t, phone, params("openid"))))
+		assert.Equal(t, services.ACRPhoneSMS, acrClaim(t, weak["access_token"].(string)))
+
+		w := callAPI(weak["access_token"].(string))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_user_authentication"`)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `acr_values="`+services.ACRPhoneSMSTOTP+`"`)
+
+		require.NotEmpty(t, strongToken)
+		w = callAPI(strongToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, strconv.Itoa(user.ID), response["sub"])
+	})
+
+	t.Run("Without Authenticator App", func(t *testing.T) {
+		req := ts.CreateAuthenticatedRequest(t, "DELETE", "/api/admin/users/"+strconv.Itoa(user.ID)+"/totp", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// 强制要求的等级无法满足
+		login := ts.LoginForAuthorization(t, phone, params("openid transfers"))
+		require.Equal(t, http.StatusFound, login.Code, login.Body.String())
+		location, err := url.Parse(login.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "unmet_authentication_requirements", location.Query().Get("error"))
+
+		// acr_values只是期望，照常授权
+		data := params("openid")
+		data.Set("acr_values", services.ACRPhoneSMSTOTP)
+		authorizationCode(t, ts.LoginForAuthorization(t, phone, data))
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+		assert.Equal(t, []any{services.ACRPhoneSMS, services.ACRPhoneSMSTOTP}, metadata["acr_values_supported"])
+	})
+}
+
+// TestNativeAppRedirectURIs tests the redirect URI rules for native apps (RFC 8252)
+func TestNativeAppRedirectURIs(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	developer := ts.RegisterTestDeveloper(t)
+
+	register := func(t *testing.T, metadata map[string]any) *httptest.ResponseRecorder {
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/developers/"+developer.ID+"/initial-access-tokens", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+		payload, _ := json.Marshal(metadata)
+		req = httptest.NewRequest("POST", "/register", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+response["initial_access_token"].(string))
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Registration Rejects Invalid Redirect URIs", func(t *testing.T) {
+		for _, tc := range []struct {
+			applicationType string
+			redirectURI     string
+		}{
+			{"web", "http://partner.example.com/callback"},
+			{"web", "http://127.0.0.1/callback"},
+			{"web", "com.example.cli:/callback"},
+			{"web", "https://*.example.com/callback"},
+			{"native", "https://partner.example.com/callback#fragment"},
+			{"native", "http://partner.example.com/callback"},
+			{"native", "http://127.0.0.1:*/callback"},
+			{"native", "myapp:/callback"},
+		} {
+			w := register(t, map[string]any{
+				"application_type": tc.applicationType,
+				"redirect_uris":    []string{tc.redirectURI},
+			})
+			assert.Equal(t, http.StatusBadRequest, w.Code, tc.redirectURI)
+			assert.Contains(t, w.Body.String(), "invalid_redirect_uri", tc.redirectURI)
+		}
+
+		w := register(t, map[string]any{
+			"application_type": "desktop",
+			"redirect_uris":    []string{"http://127.0.0.1/callback"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client_metadata")
+	})
+
+	w := register(t, map[string]any{
+		"application_type": "native",
+		"client_name":      "Flash CLI",
+		"redirect_uris":    []string{"http://127.0.0.1/callback", "com.example.cli:/callback"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var registered map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	clientID := registered["client_id"].(string)
+	assert.Equal(t, "native", registered["application_type"])
+
+	authorize := func(redirectURI string) *httptest.ResponseRecorder {
+		return ts.LoginForAuthorization(t, ts.DataManager.GetTestUsers()[DefaultUserType].Phone, url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {redirectURI},
+			"response_type": {"code"},
+			"state":         {"native-state"},
+		})
+	}
+
+	t.Run("Loopback Redirect With Any Port", func(t *testing.T) {
+		for _, redirectURI := range []string{"http://127.0.0.1/callback", "http://127.0.0.1:51004/callback", "http://127.0.0.1:8765/callback"} {
+			w := authorize(redirectURI)
+			require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, redirectURI, location.Scheme+"://"+location.Host+location.Path)
+			assert.NotEmpty(t, location.Query().Get("code"))
+			assert.Equal(t, "native-state", location.Query().Get("state"))
+		}
+	})
+
+	t.Run("Loopback Redirect Must Match Host And Path", func(t *testing.T) {
+		for _, redirectURI := range []string{
+			"http://127.0.0.1:51004/other",
+			"http://localhost:51004/callback",
+			"https://127.0.0.1:51004/callback",
+			"http://evil.example.com:51004/callback",
+		} {
+			req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+				"client_id":     {clientID},
+				"redirect_uri":  {redirectURI},
+				"response_type": {"code"},
+			}.Encode(), nil)
+			w := httptest.NewRecorder()
+			ts.Router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, redirectURI)
+			assert.Contains(t, w.Body.String(), "invalid_redirect_uri", redirectURI)
+		}
+	})
+
+	t.Run("Private-Use Scheme Redirect", func(t *testing.T) {
+		w := authorize("com.example.cli:/callback")
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "com.example.cli:/callback?"), w.Header().Get("Location"))
+	})
+
+	t.Run("Web Clients Need An Exact Match", func(t *testing.T) {
+		client := ts.CreateTestClient(t)
+		redirectURI, err := url.Parse(client.RedirectURIs[0])
+		require.NoError(t, err)
+		redirectURI.Host = redirectURI.Hostname() + ":1"
+
+		req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+			"client_id":     {client.ID},
+			"redirect_uri":  {redirectURI.String()},
+			"response_type": {"code"},
+		}.Encode(), nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_redirect_uri")
+	})
+}
+
+// TestDynamicClientRegistration tests client registration (RFC 7591) and management (RFC 7592)
+func TestDynamicClientRegistration(t *testing.T) {
+	ts := getOrCreateTestServer(t)
+	if ts == nil {
+		return
+	}
+
+	developer := ts.RegisterTestDeveloper(t)
+
+	issueToken := func(t *testing.T) string {
+		req := ts.CreateAuthenticatedRequest(t, "POST", "/api/admin/developers/"+developer.ID+"/initial-access-tokens", nil)
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["initial_access_token"].(string)
+	}
+
+	send := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	metadata := map[string]any{
+		"redirect_uris": []string{"https://partner.example.com/callback"},
+		"client_name":   "Partner App",
+		"grant_types":   []string{"authorization_code", "refresh_token"},
+		"contacts":      []string{"ops@partner.example.com"},
+	}
+
+	t.Run("Registration Requires Initial Access Token", func(t *testing.T) {
+		w := send("POST", "/register", "", metadata)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+	})
+
+	t.Run("Invalid Redirect URI", func(t *testing.T) {
+		w := send("POST", "/register", issueToken(t), map[string]any{
+			"redirect_uris": []string{"https://partner.example.com/callback#fragment"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_redirect_uri")
+	})
+
+	t.Run("Logout URIs", func(t *testing.T) {
+		for name, uri := range map[string]string{
+			"backchannel_logout_uri":  "https://127.0.0.1/backchannel-logout",
+			"frontchannel_logout_uri": "http://partner.example.com/frontchannel-logout",
+		} {
+			w := send("POST", "/register", issueToken(t), map[string]any{
+				"redirect_uris": []string{"https://partner.example.com/callback"},
+				name:            uri,
+			})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Contains(t, w.Body.String(), name)
+		}
+	})
+
+	t.Run("Privileged Grant Types Need An Administrator", func(t *testing.T) {
+		w := send("POST", "/register", issueToken(t), map[string]any{
+			"redirect_uris": []string{"https://partner.example.com/callback"},
+			"grant_types":   []string{"authorization_code", "urn:ietf:params:oauth:grant-type:token-exchange"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client_metadata")
+
+		w = send("POST", "/register", issueToken(t), metadata)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var registered map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+		clientID := registered["client_id"].(string)
+		registrationToken := registered["registration_access_token"].(string)
+		defer send("DELETE", "/register/"+clientID, registrationToken, nil)
+
+		grantTypes := []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"}
+		body, _ := json.Marshal(map[string]any{"grant_types": grantTypes})
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+clientID+"/grant-types", body)
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// 开发者更新时可保留管理员授予的类型，但不能新增
+		w = send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+			"client_id":     clientID,
+			"redirect_uris": []string{"https://partner.example.com/callback"},
+			"grant_types":   grantTypes,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+			"client_id":                       clientID,
+			"redirect_uris":                   []string{"https://partner.example.com/callback"},
+			"grant_types":                     append(grantTypes, "urn:openid:params:grant-type:ciba"),
+			"backchannel_token_delivery_mode": "poll",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client_metadata")
+	})
+
+	t.Run("Ping Endpoint Must Be Public", func(t *testing.T) {
+		w := send("POST", "/register", issueToken(t), metadata)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var registered map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+		clientID := registered["client_id"].(string)
+		registrationToken := registered["registration_access_token"].(string)
+		defer send("DELETE", "/register/"+clientID, registrationToken, nil)
+
+		grantTypes := []string{"authorization_code", "refresh_token", "urn:openid:params:grant-type:ciba"}
+		body, _ := json.Marshal(map[string]any{"grant_types": grantTypes})
+		req := ts.CreateAuthenticatedRequest(t, "PUT", "/api/admin/clients/"+clientID+"/grant-types", body)
+		w = httptest.NewRecorder()
+		ts.Router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		update := func(endpoint string) *httptest.ResponseRecorder {
+			return send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+				"client_id":                       clientID,
+				"redirect_uris":                   []string{"https://partner.example.com/callback"},
+				"grant_types":                     grantTypes,
+				"backchannel_token_delivery_mode": "ping",
+				"backchannel_client_notification_endpoint": endpoint,
+			})
+		}
+
+		// 服务器不向内网地址发送通知
+		w = update("https://127.0.0.1/ciba-callback")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "backchannel_client_notification_endpoint")
+
+		w = update("https://partner.example.com/ciba-callback")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Register, Read, Update and Delete", func(t *testing.T) {
+		initialAccessToken := issueToken(t)
+		w := send("POST", "/register", initialAccessToken, metadata)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var registered map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+		clientID := registered["client_id"].(string)
+		clientSecret := registered["client_secret"].(string)
+		registrationToken := registered["registration_access_token"].(string)
+		assert.NotEmpty(t, clientSecret)
+		assert.NotEmpty(t, registrationToken)
+		assert.Equal(t, "Partner App", registered["client_name"])
+		assert.Equal(t, "client_secret_basic", registered["token_endpoint_auth_method"])
+		assert.Equal(t, ts.Config.BaseURL+"/register/"+clientID, registered["registration_client_uri"])
+
+		var developerID string
+		require.NoError(t, ts.DB.QueryRow("SELECT developer_id FROM oauth_clients WHERE id = $1", clientID).Scan(&developerID))
+		assert.Equal(t, developer.ID, developerID)
+
+		// 初始访问令牌只能使用一次
+		w = send("POST", "/register", initialAccessToken, metadata)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// 注册的客户端可以直接用于授权流程
+		login := ts.LoginForAuthorization(t, ts.DataManager.GetTestUsers()[DefaultUserType].Phone, url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {"https://partner.example.com/callback"},
+			"response_type": {"code"},
+		})
+		assert.Equal(t, http.StatusFound, login.Code, login.Body.String())
+
+		w = send("GET", "/register/"+clientID, registrationToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "ops@partner.example.com")
+		assert.NotContains(t, w.Body.String(), "registration_access_token")
+
+		w = send("GET", "/register/"+clientID, "wrong-token", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+			"client_id":     clientID,
+			"client_secret": clientSecret,
+			"redirect_uris": []string{"https://partner.example.com/new-callback"},
+			"client_name":   "Partner App v2",
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var updated map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, "Partner App v2", updated["client_name"])
+		assert.Equal(t, []any{"https://partner.example.com/new-callback"}, updated["redirect_uris"])
+		assert.Equal(t, clientSecret, updated["client_secret"])
+
+		w = send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+			"client_id":     "another-client",
+			"redirect_uris": []string{"https://partner.example.com/callback"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send("DELETE", "/register/"+clientID, registrationToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = send("GET", "/register/"+clientID, registrationToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Subject Type And Sector Fixed", func(t *testing.T) {
+		w := send("POST", "/register", issueToken(t), map[string]any{
+			"redirect_uris": []string{"https://partner.example.com/callback"},
+			"subject_type":  "pairwise",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var registered map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+		clientID := registered["client_id"].(string)
+		registrationToken := registered["registration_access_token"].(string)
+		defer send("DELETE", "/register/"+clientID, registrationToken, nil)
+
+		// 同一主机下的重定向URI不改变扇区
+		w = send("PUT", "/register/"+clientID, registrationToken, map[string]any{
+			"client_id":     clientID,
+			"redirect_uris": []string{"https://partner.example.com/new-callback"},
+			"subject_type":  "pairwise",
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		for name, update := range map[string]map[string]any{
+			"Subject Type": {
+				"client_id":     clientID,
+				"redirect_uris": []string{"https://partner.example.com/new-callback"},
+			},
+			"Sector": {
+				"client_id":     clientID,
+				"redirect_uris": []string{"https://partner.example.org/callback"},
+				"subject_type":  "pairwise",
+			},
+		} {
+			w = send("PUT", "/register/"+clientID, registrationToken, update)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Contains(t, w.Body.String(), "invalid_client_metadata", name)
+		}
+	})
+}
+
 // TestJWKSEndpoint tests the JSON Web Key Set endpoint
 func TestJWKSEndpoint(t *testing.T) {
 	ts := getOrCreateTestServer(t)
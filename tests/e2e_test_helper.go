@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -68,9 +70,22 @@ func SetupTestServer(t *testing.T) *TestServer {
 		Port:        testConfig.TestPort,
 		DatabaseURL: testConfig.DatabaseURL,
 		RedisURL:    testConfig.RedisURL,
+		Issuer:      "flash-oauth2",
+		BaseURL:     "http://" + testConfig.TestServerHost + ":" + testConfig.TestPort,
 		SMS: &config.SMSConfig{
 			Enabled: false, // Disable SMS in tests
 		},
+		MTLSClientCAs: x509.NewCertPool(),
+
+		AccessTokenLifetime:  time.Hour,
+		IDTokenLifetime:      time.Hour,
+		RefreshTokenLifetime: 30 * 24 * time.Hour,
+		AuthCodeLifetime:     10 * time.Minute,
+		IssueRefreshTokens:   true,
+
+		SSOSessionLifetime: 24 * time.Hour,
+
+		PairwiseSubjectSalt: "test-pairwise-subject-salt",
 	}
 
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -98,6 +113,9 @@ func SetupTestServer(t *testing.T) *TestServer {
 	}
 
 	handler := handlers.New(db, redisConn, cfg)
+	// 测试客户端的登出和CIBA通知端点监听回环地址
+	handler.GetBackchannelLogoutService().SetHTTPClient(&http.Client{Timeout: 5 * time.Second})
+	handler.GetCIBAService().SetHTTPClient(&http.Client{Timeout: 5 * time.Second})
 	router := gin.New()
 
 	router.SetHTMLTemplate(template.Must(template.New("").Parse(`
@@ -172,10 +190,11 @@ func (ts *TestServer) cleanupTestData(t *testing.T) {
 		"authorization_codes",
 		"access_tokens",
 		"refresh_tokens",
+		"oauth_clients",
+		"initial_access_tokens",
 		"app_key_pairs",
 		"external_apps",
 		"developers",
-		"oauth_clients",
 		"users",
 	}
 
@@ -201,9 +220,75 @@ func (ts *TestServer) cleanupTestData(t *testing.T) {
 	}
 }
 
-// CreateTestClient creates a test OAuth2 client using predefined data
-func (ts *TestServer) CreateTestClient(t *testing.T) *TestClient {
-	return ts.CreateTestClientWithType(t, DefaultClientType)
+// ClientOverrides sets oauth_clients columns of a test client, e.g.
+// ClientOverrides{"require_pushed_authorization_requests": true}. String slices are stored as arrays.
+type ClientOverrides map[string]any
+
+// CreateTestClient creates an OAuth2 client dedicated to the calling test, with the data of the
+// standard test client under a unique ID and the columns set by the overrides. The client and
+// its codes and tokens are deleted when the test finishes, so its settings never leak into
+// other tests.
+func (ts *TestServer) CreateTestClient(t *testing.T, overrides ...ClientOverrides) *TestClient {
+	clientData := ts.DataManager.GetTestClients()[DefaultClientType]
+
+	client := &TestClient{
+		ID:           generateRandomID("test-client"),
+		Secret:       clientData.Secret,
+		Name:         clientData.Name,
+		RedirectURIs: clientData.RedirectURIs,
+	}
+
+	_, err := ts.DB.Exec(`
+		INSERT INTO oauth_clients (id, secret, name, redirect_uris, grant_types, response_types, scope, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	`, client.ID, client.Secret, client.Name, pq.Array(client.RedirectURIs),
+		pq.Array(clientData.GrantTypes), pq.Array([]string{"code"}),
+		strings.Join(clientData.Scopes, " "))
+	require.NoError(t, err, "Failed to create test client")
+
+	t.Cleanup(func() {
+		for _, table := range []string{"auth_codes", "access_tokens", "refresh_tokens"} {
+			ts.DB.Exec("DELETE FROM "+table+" WHERE client_id = $1", client.ID)
+		}
+		ts.DB.Exec("DELETE FROM oauth_clients WHERE id = $1", client.ID)
+	})
+
+	for _, override := range overrides {
+		ts.UpdateTestClient(t, client, override)
+	}
+
+	return client
+}
+
+// UpdateTestClient changes columns of a client created with CreateTestClient.
+func (ts *TestServer) UpdateTestClient(t *testing.T, client *TestClient, overrides ClientOverrides) {
+	if len(overrides) == 0 {
+		return
+	}
+
+	columns := make([]string, 0, len(overrides))
+	for column := range overrides {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := []any{client.ID}
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		value := overrides[column]
+		if values, ok := value.([]string); ok {
+			value = pq.Array(values)
+		}
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	_, err := ts.DB.Exec("UPDATE oauth_clients SET "+strings.Join(assignments, ", ")+" WHERE id = $1", args...)
+	require.NoError(t, err, "Failed to update test client")
+
+	if redirectURIs, ok := overrides["redirect_uris"].([]string); ok {
+		client.RedirectURIs = redirectURIs
+	}
 }
 
 // CreateTestClientWithType creates a test OAuth2 client of specific type
@@ -521,6 +606,50 @@ func (ts *TestServer) LoginWithCode(t *testing.T, phone, code string) {
 	}
 }
 
+// LoginForAuthorization logs a user in through the login form with OAuth2 authorization parameters
+// and returns the raw authorization response. The verification code is seeded directly in Redis.
+func (ts *TestServer) LoginForAuthorization(t *testing.T, phone string, params url.Values) *httptest.ResponseRecorder {
+	code := ts.DataManager.GetTestUsers()[DefaultUserType].VerifyCode
+	err := ts.Redis.Set(context.Background(), "verification_code:"+phone, code, time.Minute).Err()
+	require.NoError(t, err, "Failed to seed verification code")
+
+	data := url.Values{}
+	for name, values := range params {
+		data[name] = values
+	}
+	data.Set("phone", phone)
+	data.Set("code", code)
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	return w
+}
+
+// AnswerConsent submits the user's decision ("allow" or "deny") on the consent screen returned by
+// a login, sending the SSO session cookie set by the login, and returns the authorization response.
+func (ts *TestServer) AnswerConsent(t *testing.T, login *httptest.ResponseRecorder, params url.Values, decision string) *httptest.ResponseRecorder {
+	require.Equal(t, http.StatusOK, login.Code, "Expected a consent screen: %s", login.Body.String())
+
+	data := url.Values{}
+	for name, values := range params {
+		data[name] = values
+	}
+	data.Set("decision", decision)
+
+	req := httptest.NewRequest("POST", "/consent", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range login.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	ts.Router.ServeHTTP(w, req)
+	return w
+}
+
 // GetAuthorizationCode gets an authorization code through the OAuth2 flow
 func (ts *TestServer) GetAuthorizationCode(t *testing.T, client *TestClient, redirectURI, scope, state string) string {
 	// Build authorization URL